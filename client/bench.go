@@ -0,0 +1,327 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Bench sends the same request many times at once to measure throughput and latency
+type Bench struct {
+	// how many requests to send. 0 sends until Duration is over
+	Requests int
+	// how many requests can be in flight at once
+	Concurrency int
+	// requests per second to send at most. 0 sends as fast as the Concurrency allows
+	Rate float64
+	// stops sending after this long. 0 sends until Requests are sent
+	Duration time.Duration
+	// settings for sending, shared by all workers
+	Transport Transport
+	// session to send cookies and sticky headers from, if any
+	Session *Session
+	// OAuth2 token source, Digest auth and AWSSigV4 signer of the request, set with UseAuth
+	oauth  *oauthSource
+	digest *digestAuth
+	sigv4  *sigV4Signer
+}
+
+// UseAuth has the bench get a new OAuth2 token on a 401, answer the Digest challenge,
+// or sign each request again, for the auth of rset, the same as when it is sent once.
+// rset needs to be prepared.
+func (b *Bench) UseAuth(rset *RequestSet) {
+	b.oauth, b.digest = rset.oauth, rset.digest
+	if rset.AuthType == "AWSSigV4" {
+		b.sigv4 = rset.sigv4
+	}
+}
+
+// BenchReport is the outcome of a Bench
+type BenchReport struct {
+	Requests   int            `json:"requests"`
+	Duration   time.Duration  `json:"-"`
+	Throughput float64        `json:"throughputPerSec"`
+	Latency    LatencyStats   `json:"latencyMs"`
+	Statuses   map[int]int    `json:"statuses"`
+	Errors     map[string]int `json:"errors"`
+	Histogram  []Bucket       `json:"histogram"`
+	latencies  []time.Duration
+}
+
+// LatencyStats are in milliseconds for the json output
+type LatencyStats struct {
+	Min  float64 `json:"min"`
+	Mean float64 `json:"mean"`
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	P99  float64 `json:"p99"`
+	Max  float64 `json:"max"`
+}
+
+// Bucket counts the requests with a latency up to UpToMs
+type Bucket struct {
+	UpToMs float64 `json:"upToMs"`
+	Count  int     `json:"count"`
+}
+
+// ParseRate reads a rate like 50/s, 600/m or 50 (per second) into requests per second
+func ParseRate(s string) (float64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	n, per, _ := strings.Cut(s, "/")
+	r, err := strconv.ParseFloat(n, 64)
+	if err != nil || !(r >= 0) {
+		return 0, fmt.Errorf("rate needs to be like 50/s, 600/m or 50. was given: %s", s)
+	}
+	switch per {
+	case "", "s":
+	case "m":
+		r /= 60
+	case "h":
+		r /= 3600
+	default:
+		return 0, fmt.Errorf("rate needs to be per s|m|h, like 50/s. was given: %s", s)
+	}
+	if err := checkRate(r); err != nil {
+		return 0, fmt.Errorf("%w. was given: %s", err, s)
+	}
+	return r, nil
+}
+
+// bounds of a rate per second, so the interval between requests is between 1ns and an hour
+const (
+	maxRate = 1e9
+	minRate = 1.0 / 3600
+)
+
+// Returns an err when the rate per second r isn't 0, for as fast as possible, or within bounds
+func checkRate(r float64) error {
+	if r != 0 && !(r >= minRate && r <= maxRate) {
+		return fmt.Errorf("rate needs to be between 1/h and %g/s", maxRate)
+	}
+	return nil
+}
+
+// Run sends req per the Bench settings, using one transport shared by all workers
+// so connections are reused.
+func (b *Bench) Run(req *http.Request) (*BenchReport, error) {
+	if b.Requests <= 0 && b.Duration <= 0 {
+		return nil, fmt.Errorf("bench needs a number of requests or a duration")
+	}
+	if err := checkRate(b.Rate); err != nil {
+		return nil, err
+	}
+	conc := b.Concurrency
+	if conc < 1 {
+		conc = 1
+	}
+	c, err := b.Transport.Client(conc)
+	if err != nil {
+		return nil, err
+	}
+	c = c.withSession(b.Session).withOAuth(b.oauth).withDigest(b.digest).withSigV4(b.sigv4)
+	var body []byte
+	if req.Body != nil {
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, fmt.Errorf("err reading request body: %w", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if b.Duration > 0 {
+		ctx, cancel = context.WithTimeout(ctx, b.Duration)
+		defer cancel()
+	}
+	jobs := make(chan struct{})
+	go b.dispatch(ctx, jobs)
+
+	rep := &BenchReport{Statuses: map[int]int{}, Errors: map[string]int{}}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := 0; i < conc; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range jobs {
+				r := req.Clone(ctx)
+				if body != nil {
+					r.Body = io.NopCloser(bytes.NewReader(body))
+					// so it can be sent again after a 401
+					r.GetBody = func() (io.ReadCloser, error) {
+						return io.NopCloser(bytes.NewReader(body)), nil
+					}
+				}
+				t := time.Now()
+				resp, err := c.Do(r)
+				if err == nil {
+					_, err = io.Copy(io.Discard, resp.Body)
+					resp.Body.Close()
+				}
+				lat := time.Since(t)
+				mu.Lock()
+				switch {
+				case err != nil && ctx.Err() != nil && errors.Is(err, ctx.Err()):
+					// cut off by the end of the Duration, so not counted
+				case err != nil:
+					rep.Requests++
+					rep.Errors[benchErr(err)]++
+				default:
+					rep.Requests++
+					rep.Statuses[resp.StatusCode]++
+					rep.latencies = append(rep.latencies, lat)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	rep.Duration = time.Since(start)
+	rep.summarize()
+	return rep, nil
+}
+
+// Sends a job for each request to send, keeping to the Rate, until there are
+// no more Requests or ctx is done.
+func (b *Bench) dispatch(ctx context.Context, jobs chan<- struct{}) {
+	defer close(jobs)
+	var tick <-chan time.Time
+	if b.Rate > 0 {
+		t := time.NewTicker(time.Duration(float64(time.Second) / b.Rate))
+		defer t.Stop()
+		tick = t.C
+	}
+	for i := 0; b.Requests <= 0 || i < b.Requests; i++ {
+		if tick != nil && i > 0 {
+			select {
+			case <-tick:
+			case <-ctx.Done():
+				return
+			}
+		}
+		select {
+		case jobs <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Shortens an error for the breakdown, dropping the method and url that are the same for all
+func benchErr(err error) string {
+	var ue *url.Error
+	if errors.As(err, &ue) {
+		return ue.Err.Error()
+	}
+	return err.Error()
+}
+
+func (rep *BenchReport) summarize() {
+	if rep.Duration > 0 {
+		rep.Throughput = float64(rep.Requests) / rep.Duration.Seconds()
+	}
+	l := rep.latencies
+	if len(l) == 0 {
+		return
+	}
+	sort.Slice(l, func(i, j int) bool { return l[i] < l[j] })
+	var sum time.Duration
+	for _, d := range l {
+		sum += d
+	}
+	pct := func(q float64) float64 {
+		i := int(math.Ceil(q*float64(len(l)))) - 1
+		if i < 0 {
+			i = 0
+		}
+		return ms(l[i])
+	}
+	rep.Latency = LatencyStats{
+		Min: ms(l[0]), Mean: ms(sum / time.Duration(len(l))),
+		P50: pct(0.5), P90: pct(0.9), P99: pct(0.99), Max: ms(l[len(l)-1]),
+	}
+	const buckets = 10
+	step := (l[len(l)-1] - l[0]) / buckets
+	if step <= 0 {
+		rep.Histogram = []Bucket{{UpToMs: ms(l[len(l)-1]), Count: len(l)}}
+		return
+	}
+	rep.Histogram = make([]Bucket, buckets)
+	for i := range rep.Histogram {
+		rep.Histogram[i].UpToMs = ms(l[0] + step*time.Duration(i+1))
+	}
+	rep.Histogram[buckets-1].UpToMs = ms(l[len(l)-1])
+	for _, d := range l {
+		i := int((d - l[0]) / step)
+		if i >= buckets {
+			i = buckets - 1
+		}
+		rep.Histogram[i].Count++
+	}
+}
+
+func ms(d time.Duration) float64 {
+	return math.Round(float64(d)/float64(time.Millisecond)*100) / 100
+}
+
+// WriteBench writes the report as text, with a histogram of latencies
+func WriteBench(w io.Writer, rep *BenchReport) {
+	fmt.Fprintf(w, "Requests:    %d in %v\n", rep.Requests, rep.Duration.Round(time.Millisecond))
+	fmt.Fprintf(w, "Throughput:  %.2f/s\n", rep.Throughput)
+	l := rep.Latency
+	fmt.Fprintf(w, "Latency ms:  min %.2f  mean %.2f  p50 %.2f  p90 %.2f  p99 %.2f  max %.2f\n", l.Min, l.Mean, l.P50, l.P90, l.P99, l.Max)
+	if len(rep.Histogram) > 0 {
+		fmt.Fprintln(w, "\nLatency histogram:")
+		most := 0
+		for _, b := range rep.Histogram {
+			if b.Count > most {
+				most = b.Count
+			}
+		}
+		tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', tabwriter.AlignRight)
+		for _, b := range rep.Histogram {
+			fmt.Fprintf(tw, "  <= %.2fms\t[%d]\t %s\n", b.UpToMs, b.Count, strings.Repeat("#", b.Count*40/most))
+		}
+		tw.Flush()
+	}
+	fmt.Fprintln(w, "\nStatus codes:")
+	codes := make([]int, 0, len(rep.Statuses))
+	for c := range rep.Statuses {
+		codes = append(codes, c)
+	}
+	sort.Ints(codes)
+	for _, c := range codes {
+		fmt.Fprintf(w, "  [%d] %d responses\n", c, rep.Statuses[c])
+	}
+	if len(rep.Errors) > 0 {
+		fmt.Fprintln(w, "\nErrors:")
+		for _, e := range sortedKeys(rep.Errors) {
+			fmt.Fprintf(w, "  [%d] %s\n", rep.Errors[e], e)
+		}
+	}
+}
+
+// WriteBenchJSON writes the report as json, to track results over time
+func WriteBenchJSON(w io.Writer, rep *BenchReport) error {
+	out := struct {
+		*BenchReport
+		DurationMs float64 `json:"durationMs"`
+	}{rep, ms(rep.Duration)}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
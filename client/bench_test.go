@@ -0,0 +1,251 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jerempy/brang/config"
+)
+
+func TestParseRate(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		want    float64
+		wantErr bool
+	}{
+		"per second": {in: "50/s", want: 50},
+		"per minute": {in: "600/m", want: 10},
+		"no unit":    {in: "50", want: 50},
+		"empty":      {in: "", want: 0},
+		"bad number": {in: "fast", wantErr: true},
+		"bad unit":   {in: "50/d", wantErr: true},
+		"below zero": {in: "-5/s", wantErr: true},
+		"per hour":   {in: "1/h", want: 1.0 / 3600},
+		"zero":       {in: "0/s", want: 0},
+		"too fast":   {in: "2e9/s", wantErr: true},
+		"too slow":   {in: "0.5/h", wantErr: true},
+		"infinite":   {in: "inf", wantErr: true},
+		"not number": {in: "NaN/s", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseRate(tc.in)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("%v: got err %v - want err %v", tc.in, err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("%v: got %v - want %v", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestBenchRun(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+	tests := map[string]struct {
+		bench    Bench
+		path     string
+		want     int
+		status   int
+		duration bool
+	}{
+		"count":      {bench: Bench{Requests: 20, Concurrency: 4}, path: "/", want: 20, status: 200},
+		"statuses":   {bench: Bench{Requests: 5, Concurrency: 2}, path: "/missing", want: 5, status: 404},
+		"one worker": {bench: Bench{Requests: 3}, path: "/", want: 3, status: 200},
+		"duration":   {bench: Bench{Duration: 100 * time.Millisecond, Concurrency: 2, Rate: 100}, path: "/", status: 200, duration: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			req, _ := http.NewRequest("POST", ts.URL+tc.path, strings.NewReader(`{"a":1}`))
+			rep, err := tc.bench.Run(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.duration {
+				if rep.Requests < 1 || rep.Requests > 15 {
+					t.Errorf("requests in 100ms at 100/s: got %v - want about 10", rep.Requests)
+				}
+			} else if rep.Requests != tc.want {
+				t.Errorf("requests: got %v - want %v", rep.Requests, tc.want)
+			}
+			if rep.Statuses[tc.status] != rep.Requests {
+				t.Errorf("statuses: got %v - want all %v to be %v", rep.Statuses, rep.Requests, tc.status)
+			}
+			n := 0
+			for _, b := range rep.Histogram {
+				n += b.Count
+			}
+			if n != rep.Requests {
+				t.Errorf("histogram count: got %v - want %v", n, rep.Requests)
+			}
+			if rep.Latency.P50 > rep.Latency.P99 || rep.Latency.Min > rep.Latency.Max {
+				t.Errorf("latency stats out of order: %+v", rep.Latency)
+			}
+		})
+	}
+}
+
+func TestBenchErrors(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := ts.URL
+	ts.Close()
+	req, _ := http.NewRequest("GET", url, nil)
+	rep, err := (&Bench{Requests: 3, Concurrency: 3}).Run(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Requests != 3 || len(rep.Statuses) != 0 {
+		t.Errorf("got %v requests %v - want 3 requests with no statuses", rep.Requests, rep.Statuses)
+	}
+	for e, n := range rep.Errors {
+		if strings.Contains(e, url) || n != 3 {
+			t.Errorf("errors: got %v - want one without the url counted 3 times", rep.Errors)
+		}
+	}
+	if _, err := (&Bench{}).Run(req); err == nil {
+		t.Error("should err with no requests or duration")
+	}
+	if _, err := (&Bench{Requests: 1, Rate: -1}).Run(req); err == nil {
+		t.Error("should err with a rate below zero")
+	}
+}
+
+func TestWriteBench(t *testing.T) {
+	rep := &BenchReport{
+		Requests: 4, Duration: 2 * time.Second,
+		Statuses: map[int]int{200: 3, 500: 1},
+		Errors:   map[string]int{},
+		latencies: []time.Duration{
+			4 * time.Millisecond, time.Millisecond, 2 * time.Millisecond, 3 * time.Millisecond,
+		},
+	}
+	rep.summarize()
+	if rep.Throughput != 2 || rep.Latency.P50 != 2 || rep.Latency.Max != 4 || rep.Latency.Mean != 2.5 {
+		t.Errorf("summary: got %v %+v - want 2/s with p50 2, max 4 and mean 2.5", rep.Throughput, rep.Latency)
+	}
+	var b bytes.Buffer
+	WriteBench(&b, rep)
+	for _, want := range []string{"Requests:    4 in 2s", "Throughput:  2.00/s", "p50 2.00", "[200] 3 responses", "[500] 1 responses"} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("missing %q in:\n%s", want, b.String())
+		}
+	}
+	b.Reset()
+	if err := WriteBenchJSON(&b, rep); err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(b.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["durationMs"] != 2000.0 || got["statuses"].(map[string]interface{})["500"] != 1.0 {
+		t.Errorf("got json %s - want durationMs 2000 and 1 500", b.String())
+	}
+}
+
+func TestBenchOAuth2(t *testing.T) {
+	defer func(old string) { config.TokensPath = old }(config.TokensPath)
+	config.TokensPath = t.TempDir()
+	tokenSrv, api, forms := oauthServers(t)
+	src, err := newOAuthSource(Auth{AuthType: "OAuth2", TokenURL: tokenSrv.URL, RefreshToken: "rt-0"}, "testspace", "", Vars{}, http.DefaultClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := src.Header(""); err != nil {
+		t.Fatal(err)
+	}
+	// the cached token is revoked, so the first 401 gets a new one for the rest
+	tokens, _ := loadTokens("testspace")
+	tokens[src.key].AccessToken = "revoked"
+	saveTokens("testspace", tokens)
+	req, _ := http.NewRequest("POST", api.URL, strings.NewReader(`{"a":1}`))
+	req.Header.Set("Authorization", "Bearer revoked")
+	b := &Bench{Requests: 10, Concurrency: 2}
+	b.UseAuth(&RequestSet{oauth: src})
+	rep, err := b.Run(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Statuses[200] != 10 {
+		t.Errorf("statuses: got %v - want all 10 sent again with a new token", rep.Statuses)
+	}
+	if len(*forms) != 2 {
+		t.Errorf("token requests: got %v - want 2, one new token for all of them", len(*forms))
+	}
+}
+
+func TestBenchDigest(t *testing.T) {
+	digestChallenges = map[string]*digestChallenge{}
+	challenge := &digestChallenge{realm: "device", nonce: "abc", algorithm: "MD5", qop: "auth"}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := r.Header.Get("Authorization")
+		params := map[string]string{}
+		if l := parseChallenges(h); len(l) == 1 {
+			params = l[0]
+		}
+		c := *challenge
+		fmt.Sscanf(params["nc"], "%x", &c.nc)
+		if b, _ := io.ReadAll(r.Body); string(b) != `{"a":1}` || h != c.authorization("admin", "pa55", r.Method, params["uri"], params["cnonce"]) {
+			w.Header().Set("WWW-Authenticate", `Digest realm="device", nonce="abc", qop="auth"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer ts.Close()
+	req, _ := http.NewRequest("POST", ts.URL, strings.NewReader(`{"a":1}`))
+	b := &Bench{Requests: 10, Concurrency: 2}
+	b.UseAuth(&RequestSet{digest: newDigestAuth("admin:pa55", "", req.URL.Host)})
+	rep, err := b.Run(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Statuses[200] != 10 {
+		t.Errorf("statuses: got %v %v - want all 10 to answer the challenge", rep.Statuses, rep.Errors)
+	}
+}
+
+func TestBenchSigV4(t *testing.T) {
+	s := &sigV4Signer{accessKey: "minio", secretKey: "s3cret", region: "us-east-1", service: "s3"}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// sign what was signed again, at the date sent, to check the signature
+		body, _ := io.ReadAll(r.Body)
+		at, err := time.Parse(sigV4TimeFormat, r.Header.Get("X-Amz-Date"))
+		_, signed, _ := strings.Cut(r.Header.Get("Authorization"), "SignedHeaders=")
+		signed, _, _ = strings.Cut(signed, ",")
+		want, _ := http.NewRequest(r.Method, "http://"+r.Host+r.URL.RequestURI(), nil)
+		for _, k := range strings.Split(signed, ";") {
+			if k != "host" {
+				want.Header[http.CanonicalHeaderKey(k)] = r.Header.Values(k)
+			}
+		}
+		s.sign(want, body, at)
+		if err != nil || time.Since(at) > 5*time.Minute || want.Header.Get("Authorization") != r.Header.Get("Authorization") {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer ts.Close()
+	req, _ := http.NewRequest("PUT", ts.URL+"/bucket/a.txt", strings.NewReader("hello"))
+	// signed long before the bench sends it
+	s.sign(req, []byte("hello"), time.Now().Add(-time.Hour))
+	b := &Bench{Requests: 5, Concurrency: 2}
+	b.UseAuth(&RequestSet{AuthType: "AWSSigV4", sigv4: s})
+	rep, err := b.Run(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Statuses[200] != 5 {
+		t.Errorf("statuses: got %v %v - want all 5 signed again", rep.Statuses, rep.Errors)
+	}
+}
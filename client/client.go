@@ -2,7 +2,6 @@ package client
 
 import (
 	"net/http"
-	"time"
 )
 
 type brangClient struct{ *http.Client }
@@ -10,12 +9,27 @@ type brangClient struct{ *http.Client }
 // Returns a new *brangClient, which is a wrapper of *http.Client
 func NewClient() *brangClient {
 	return &brangClient{
-		&http.Client{Timeout: time.Second * 10},
+		&http.Client{Timeout: defaultTimeout, CheckRedirect: Transport{}.checkRedirect},
 	}
 }
 
 // Runs http.Client.Do(*http.Request) and prints to console results
 func (c *brangClient) DoRequest(r *http.Request, brw BResponseHandler) {
-	brw.CaptureResponse(c.Do(r))
+	brw.CaptureResponse(c.do(r))
 	brw.WriteResponse()
 }
+
+// Runs http.Client.Do, logging the hops of any redirects and tracing the timing. When the request fails, the
+// response is an empty one of the request so it can still be written out with the error.
+func (c *brangClient) do(r *http.Request) (*http.Response, error) {
+	r = withTracer(withRedirectLog(r))
+	resp, err := c.Do(r)
+	switch {
+	case resp == nil:
+		resp = &http.Response{Request: r, Header: http.Header{}, Body: http.NoBody}
+	case err != nil:
+		// the last redirect when there were too many. its body is already closed
+		resp.Body = http.NoBody
+	}
+	return resp, err
+}
@@ -61,7 +61,6 @@ testspace:
   requests:
     site: %v
 `, ts.URL))
-	config.Requests.SetConfigType("yaml")
 	config.Requests.ReadConfig(bytes.NewBuffer(testDoReqYml))
 	sreq, err := LoadSavedRequest(mockRSet("testspace.site"))
 	if err != nil {
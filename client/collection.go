@@ -0,0 +1,311 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jerempy/brang/config"
+)
+
+// ImportedGroup is a group read from the collection of another tool, such as Postman,
+// ready to be saved to requests.yaml
+type ImportedGroup struct {
+	Name         string
+	BaseURL      string
+	Auth         *Auth
+	Vars         Vars
+	Environments map[string]Environment
+	Requests     []ImportedRequest
+	// how parts were mapped, or why they were left out
+	Notes []string
+	// env variables creds were saved as, to set before sending
+	Envs []string
+}
+
+// ImportedRequest is a saved request of an ImportedGroup, at Path under the group's requests:
+type ImportedRequest struct {
+	Path    []string
+	Request SavedRequestSet
+}
+
+func newImportedGroup(name string) *ImportedGroup {
+	return &ImportedGroup{Name: name, Vars: Vars{}, Environments: map[string]Environment{}}
+}
+
+func (g *ImportedGroup) note(format string, a ...interface{}) {
+	g.Notes = append(g.Notes, fmt.Sprintf(format, a...))
+}
+
+// Adds a request with its own auth. The first auth seen becomes the group's when it has none.
+// A different auth is kept as an Authorization header when it is made of {{vars}}, as creds
+// aren't saved as plain text.
+func (g *ImportedGroup) addRequest(path []string, sr SavedRequestSet, auth *Auth) {
+	name := strings.Join(path, ".")
+	switch {
+	case auth == nil || g.Auth != nil && *auth == *g.Auth:
+	case g.Auth == nil:
+		g.Auth = auth
+		g.note("%s: its %s auth is used as the auth of %s", name, auth.AuthType, g.Name)
+	case (auth.AuthType == "Bearer" || auth.AuthType == "Token") && isRef(auth.Token):
+		if sr.Header == nil {
+			sr.Header = map[string]string{}
+		}
+		sr.Header["Authorization"] = auth.AuthType + " " + auth.Token
+	default:
+		g.note("%s: its %s auth isn't imported as %s has other auth. set it with -a and -c when sending", name, auth.AuthType, g.Name)
+	}
+	g.Requests = append(g.Requests, ImportedRequest{Path: path, Request: sr})
+}
+
+// Tells if v refers to a value kept elsewhere: a {{var}} or $ENV
+func isRef(v string) bool {
+	return strings.HasPrefix(v, "$") || placeholder.MatchString(v)
+}
+
+// Replaces the plain text creds of auth with $ENV references
+func (g *ImportedGroup) envAuth(a *Auth) {
+	for _, f := range []struct {
+		v   *string
+		key string
+	}{{&a.Token, "TOKEN"}, {&a.Username, "USERNAME"}, {&a.Password, "PASSWORD"}} {
+		if *f.v == "" || isRef(*f.v) {
+			continue
+		}
+		env := envName(g.Name, f.key)
+		*f.v = "$" + env
+		g.addEnv(env)
+	}
+}
+
+// Adds a var. Vars named like creds, such as token or apiKey, are saved as ${ENV} references.
+func (g *ImportedGroup) addVar(vars Vars, k, v string) {
+	if isSecretName(k) {
+		v = g.secretRef(k, v)
+	}
+	vars[k] = v
+}
+
+// Saves the creds in the headers of a request, such as X-Api-Key, as ${ENV} references. A plain
+// text Authorization header becomes the auth of the request instead, when it has none.
+func (g *ImportedGroup) secretHeaders(header map[string]string, auth *Auth) *Auth {
+	for k, v := range header {
+		if !isSecretName(k) || isRef(v) {
+			continue
+		}
+		if auth == nil && http.CanonicalHeaderKey(k) == "Authorization" {
+			if authType, cred, err := authFromHeader(v); err == nil {
+				auth = &Auth{AuthType: authType, Token: cred}
+				if authType == "Password" {
+					user, pass, _ := strings.Cut(cred, ":")
+					auth = &Auth{AuthType: authType, Username: user, Password: pass}
+				}
+				delete(header, k)
+				continue
+			}
+		}
+		header[k] = g.secretRef(k, v)
+	}
+	return auth
+}
+
+// Returns a ${ENV} reference to save in place of the plain text cred v, named after key
+func (g *ImportedGroup) secretRef(key, v string) string {
+	if v == "" || isRef(v) {
+		return v
+	}
+	return g.envRef(key)
+}
+
+// Returns a ${ENV} reference for a cred named key, such as ${MYSITE_API_KEY} for api-key
+func (g *ImportedGroup) envRef(key string) string {
+	env := envName(g.Name, strings.Trim(notEnvChars.ReplaceAllString(strings.ToUpper(key), "_"), "_"))
+	g.addEnv(env)
+	return "${" + env + "}"
+}
+
+func (g *ImportedGroup) addEnv(env string) {
+	for _, e := range g.Envs {
+		if e == env {
+			return
+		}
+	}
+	g.Envs = append(g.Envs, env)
+}
+
+// Readies the group to save once all of it is read: creds of its auth become
+// $ENV references and {{baseUrl}} the group's baseUrl
+func (g *ImportedGroup) finish() {
+	if g.Auth != nil {
+		g.envAuth(g.Auth)
+	}
+	g.useBaseURL()
+}
+
+// Uses the var baseUrl as the baseUrl of the group and its environments, so urls of
+// {{baseUrl}}/path are saved as /path
+func (g *ImportedGroup) useBaseURL() {
+	for _, k := range []string{"baseUrl", "baseURL", "base_url"} {
+		v, ok := g.Vars[k]
+		if !ok || !isHttp(v) {
+			continue
+		}
+		g.BaseURL = v
+		delete(g.Vars, k)
+		for name, env := range g.Environments {
+			if ev, ok := env.Vars[k]; ok && isHttp(ev) {
+				env.BaseURL = ev
+				delete(env.Vars, k)
+				g.Environments[name] = env
+			}
+		}
+		prefix := "{{" + k + "}}"
+		for i, r := range g.Requests {
+			if u, ok := strings.CutPrefix(r.Request.URL, prefix); ok && (u == "" || strings.HasPrefix(u, "/") || strings.HasPrefix(u, "?")) {
+				g.Requests[i].Request.URL = "/" + strings.TrimPrefix(u, "/")
+			}
+		}
+		return
+	}
+}
+
+var anyPlaceholder = regexp.MustCompile(`\{\{([^{}]*)\}\}`)
+
+// Notes the {{placeholders}} in s that brang can't fill in, such as {{$guid}} or {{my var}}
+func (g *ImportedGroup) checkVars(name, s string) {
+	for _, m := range anyPlaceholder.FindAllString(s, -1) {
+		if !placeholder.MatchString(m) {
+			g.note("%s: %s isn't a brang var and is sent as is. vars are {{name}} of letters, digits and _.-", name, m)
+		}
+	}
+}
+
+// {{var}} or ${ENV} in a value, to leave as is when encoding it
+var valueRef = regexp.MustCompile(placeholder.String() + "|" + envRef.String())
+
+// Encodes fields as application/x-www-form-urlencoded, leaving {{vars}} and ${ENV} as they
+// are so they are filled in when sent
+func formEncode(fields [][2]string) string {
+	esc := func(s string) string {
+		var b strings.Builder
+		last := 0
+		for _, m := range valueRef.FindAllStringIndex(s, -1) {
+			b.WriteString(url.QueryEscape(s[last:m[0]]) + s[m[0]:m[1]])
+			last = m[1]
+		}
+		return b.String() + url.QueryEscape(s[last:])
+	}
+	parts := make([]string, 0, len(fields))
+	for _, f := range fields {
+		parts = append(parts, esc(f[0])+"="+esc(f[1]))
+	}
+	return strings.Join(parts, "&")
+}
+
+// Adds encoded query params to u
+func addQuery(u, q string) string {
+	if q == "" {
+		return u
+	}
+	if strings.Contains(u, "?") {
+		return u + "&" + q
+	}
+	return u + "?" + q
+}
+
+// Sets the Content-Type of header to ct, unless it has one and not replace
+func setContentType(header map[string]string, ct string, replace bool) {
+	for k := range header {
+		if strings.EqualFold(k, "Content-Type") {
+			if !replace {
+				return
+			}
+			delete(header, k)
+		}
+	}
+	header["Content-Type"] = ct
+}
+
+// Save writes the group to requests.yaml. Settings and requests of the group that are
+// already there and not in the import are kept.
+func (g *ImportedGroup) Save() error {
+	if err := config.LoadRequests(); err != nil {
+		return err
+	}
+	e := config.Requests
+	if g.BaseURL != "" {
+		if err := e.Set(g.BaseURL, g.Name, "baseUrl"); err != nil {
+			return err
+		}
+	}
+	for _, k := range sortedKeys(g.Vars) {
+		if err := e.Set(g.Vars[k], g.Name, "vars", k); err != nil {
+			return err
+		}
+	}
+	if g.Auth != nil {
+		if err := e.Set(g.Auth, g.Name, "auth"); err != nil {
+			return err
+		}
+	}
+	names := make([]string, 0, len(g.Environments))
+	for k := range g.Environments {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		if err := e.Set(g.Environments[k], g.Name, "environments", k); err != nil {
+			return err
+		}
+	}
+	for _, r := range g.Requests {
+		if err := e.Set(savedValue(r.Request), append([]string{g.Name, "requests"}, r.Path...)...); err != nil {
+			return err
+		}
+	}
+	return e.WriteConfig()
+}
+
+// Returns what to save for the request: only its url when it is a plain GET
+func savedValue(sr SavedRequestSet) interface{} {
+	if sr.Method == "" && sr.Body == "" && len(sr.Header) == 0 && len(sr.Capture) == 0 && sr.Expect == nil {
+		return sr.URL
+	}
+	return sr
+}
+
+var notKeyChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Makes a name like "Get all users" into a key like get-all-users, unique among used
+func importKey(name string, used map[string]bool) string {
+	k := strings.Trim(notKeyChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if k == "" {
+		k = "request"
+	}
+	key := k
+	for i := 2; used[key]; i++ {
+		key = fmt.Sprintf("%s-%d", k, i)
+	}
+	used[key] = true
+	return key
+}
+
+// Builds a multipart/form-data body of text fields, returning it and its Content-Type
+func multipartBody(fields [][2]string) (string, string, error) {
+	var b bytes.Buffer
+	w := multipart.NewWriter(&b)
+	for _, f := range fields {
+		if err := w.WriteField(f[0], f[1]); err != nil {
+			return "", "", err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return "", "", err
+	}
+	return b.String(), w.FormDataContentType(), nil
+}
@@ -0,0 +1,39 @@
+package client
+
+import "testing"
+
+func TestImportKey(t *testing.T) {
+	used := map[string]bool{}
+	tests := []struct {
+		in, want string
+	}{
+		{"Get all users", "get-all-users"},
+		{"  Get ALL users!! ", "get-all-users-2"},
+		{"Get all users", "get-all-users-3"},
+		{"✨", "request"},
+		{"v2/Orders", "v2-orders"},
+	}
+	for _, tc := range tests {
+		if got := importKey(tc.in, used); got != tc.want {
+			t.Errorf("%q: got %v - want %v", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestFormEncode(t *testing.T) {
+	tests := map[string]struct {
+		in   [][2]string
+		want string
+	}{
+		"plain":  {in: [][2]string{{"a", "1"}, {"b", "2"}}, want: "a=1&b=2"},
+		"escape": {in: [][2]string{{"grant type", "a&b=c"}}, want: "grant+type=a%26b%3Dc"},
+		"vars":   {in: [][2]string{{"user", "x {{name}} y"}, {"{{key}}", "{{ value }}"}}, want: "user=x+{{name}}+y&{{key}}={{ value }}"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := formEncode(tc.in); got != tc.want {
+				t.Errorf("got %v - want %v", got, tc.want)
+			}
+		})
+	}
+}
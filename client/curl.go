@@ -0,0 +1,382 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// CurlImport is a request read from a curl command line
+type CurlImport struct {
+	*RequestSet
+	// how flags were mapped, or why they were left out
+	Notes []string
+	// flags that can't be saved, such as reading the body from a file with -d @file
+	Unsupported []string
+}
+
+// curl flags that take a value, by their long name. short names map to long ones in curlShort
+var curlValueFlags = map[string]bool{
+	"request": true, "header": true, "data": true, "data-raw": true, "data-binary": true,
+	"data-ascii": true, "data-urlencode": true, "user": true, "url": true, "form": true,
+	"form-string": true, "user-agent": true, "referer": true, "cookie": true,
+	// take a value but don't change the request, so they are noted
+	"output": true, "max-time": true, "connect-timeout": true, "proxy": true, "cacert": true,
+	"cert": true, "key": true, "max-redirs": true, "write-out": true, "retry": true,
+	"dump-header": true, "trace": true, "trace-ascii": true, "stderr": true, "output-dir": true, "libcurl": true,
+	// take a value but can't be saved, so they are listed as unsupported, without their value
+	// as it can be a cred
+	"json": true, "upload-file": true, "resolve": true, "connect-to": true, "oauth2-bearer": true,
+	"aws-sigv4": true, "url-query": true, "variable": true, "config": true,
+	"range": true, "cookie-jar": true, "time-cond": true, "continue-at": true, "limit-rate": true,
+	"max-filesize": true, "interface": true, "local-port": true, "dns-servers": true, "doh-url": true,
+	"unix-socket": true, "abstract-unix-socket": true, "request-target": true, "proxy-user": true,
+	"proxy-header": true, "proxy-cacert": true, "proxy-cert": true, "proxy-key": true, "preproxy": true,
+	"noproxy": true, "socks4": true, "socks4a": true, "socks5": true, "socks5-hostname": true,
+	"capath": true, "cert-type": true, "key-type": true, "pass": true, "ciphers": true, "tls-max": true,
+	"pinnedpubkey": true, "crlfile": true, "netrc-file": true, "retry-delay": true, "retry-max-time": true,
+	"keepalive-time": true, "expect100-timeout": true, "speed-limit": true, "speed-time": true,
+	"proto": true, "proto-redir": true, "proto-default": true, "alt-svc": true, "hsts": true,
+	"etag-save": true, "etag-compare": true, "create-file-mode": true, "quote": true, "telnet-option": true,
+	"mail-from": true, "mail-rcpt": true, "mail-auth": true, "login-options": true, "sasl-authzid": true,
+	"service-name": true, "delegation": true, "krb": true, "engine": true, "happy-eyeballs-timeout-ms": true,
+}
+
+var curlShort = map[byte]string{
+	'X': "request", 'H': "header", 'd': "data", 'u': "user", 'F': "form", 'A': "user-agent",
+	'e': "referer", 'b': "cookie", 'k': "insecure", 'G': "get", 'I': "head", 'o': "output",
+	'm': "max-time", 'x': "proxy", 'E': "cert", 'w': "write-out", 'D': "dump-header",
+	's': "silent", 'S': "show-error", 'v': "verbose", 'i': "include", 'L': "location",
+	'T': "upload-file", 'K': "config", 'r': "range", 'c': "cookie-jar", 'z': "time-cond",
+	'C': "continue-at", 'U': "proxy-user", 'Q': "quote", 't': "telnet-option", 'Y': "speed-limit", 'y': "speed-time",
+}
+
+// flags that only change what curl prints, safe to leave out
+var curlOutputFlags = map[string]bool{
+	"silent": true, "show-error": true, "verbose": true, "include": true, "output": true,
+	"write-out": true, "fail": true, "no-progress-meter": true, "dump-header": true, "trace": true,
+	"trace-ascii": true, "stderr": true, "output-dir": true, "libcurl": true,
+}
+
+// ParseCurl reads a curl command line, such as from "Copy as cURL" in browser devtools,
+// into a request that can be saved. It handles -X, -H, -d|--data-raw|--data-binary,
+// -u, --url, -F, --compressed, -k, -G and query strings.
+func ParseCurl(cmd string) (*CurlImport, error) {
+	args, err := splitShellWords(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCurlArgs(args)
+}
+
+// ParseCurlArgs is ParseCurl for a command line already split into words
+func ParseCurlArgs(args []string) (*CurlImport, error) {
+	if len(args) > 0 && args[0] == "curl" {
+		args = args[1:]
+	}
+	ci := &CurlImport{RequestSet: &RequestSet{}}
+	var data []string
+	var form []string
+	get, head, compressed := false, false, false
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		var name, val string
+		hasVal := false
+		switch {
+		case strings.HasPrefix(a, "--"):
+			name = a[2:]
+		case strings.HasPrefix(a, "-") && len(a) > 1:
+			// short flags can be combined, like -sSL, and take a value attached, like -XPOST
+			for j := 1; j < len(a); j++ {
+				long, ok := curlShort[a[j]]
+				if !ok {
+					ci.Unsupported = append(ci.Unsupported, "-"+string(a[j]))
+					continue
+				}
+				if curlValueFlags[long] && j+1 < len(a) {
+					name, val, hasVal = long, a[j+1:], true
+					break
+				}
+				if curlValueFlags[long] || j == len(a)-1 {
+					name = long
+					break
+				}
+				ci.flag(long, "", &get, &head, &compressed)
+			}
+			if name == "" {
+				continue
+			}
+		default:
+			if ci.URL != "" {
+				return nil, fmt.Errorf("more than one url given: %s and %s. import one request at a time", ci.URL, a)
+			}
+			ci.URL = a
+			continue
+		}
+		if curlValueFlags[name] && !hasVal {
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("--%s needs a value", name)
+			}
+			i++
+			val = args[i]
+		}
+		switch name {
+		case "data", "data-ascii", "data-binary":
+			if strings.HasPrefix(val, "@") {
+				ci.Unsupported = append(ci.Unsupported, fmt.Sprintf("--%s %s (body from a file. save it with 'brang save -f')", name, val))
+				continue
+			}
+			data = append(data, val)
+		case "data-raw":
+			data = append(data, val)
+		case "data-urlencode":
+			data = append(data, curlURLEncode(val))
+		case "form", "form-string":
+			if k, v, _ := strings.Cut(val, "="); name == "form" && (strings.HasPrefix(v, "@") || strings.HasPrefix(v, "<")) {
+				ci.Unsupported = append(ci.Unsupported, fmt.Sprintf("-F %s (file upload of %s)", val, k))
+				continue
+			}
+			form = append(form, val)
+		default:
+			ci.flag(name, val, &get, &head, &compressed)
+		}
+	}
+	if ci.URL == "" {
+		return nil, fmt.Errorf("no url found in the curl command")
+	}
+	if !isHttp(ci.URL) {
+		// curl assumes http:// for a url without one
+		ci.URL = "http://" + ci.URL
+	}
+	if _, err := url.Parse(ci.URL); err != nil {
+		return nil, fmt.Errorf("err reading url: %w", err)
+	}
+	if err := ci.setBody(data, form, get); err != nil {
+		return nil, err
+	}
+	if head && ci.Method == "" {
+		ci.Method = http.MethodHead
+	}
+	if ci.Method == "" {
+		ci.Method = http.MethodGet
+	}
+	if compressed {
+		// Go asks for gzip and decodes it itself, but not when Accept-Encoding is set
+		ci.dropHeader("Accept-Encoding")
+		ci.Notes = append(ci.Notes, "--compressed: responses are decompressed without it, so Accept-Encoding is left out")
+	}
+	return ci, nil
+}
+
+// Maps a flag without a body to the request
+func (ci *CurlImport) flag(name, val string, get, head, compressed *bool) {
+	switch name {
+	case "request":
+		ci.Method = strings.ToUpper(val)
+	case "header":
+		k, v, ok := strings.Cut(val, ":")
+		if !ok {
+			ci.Unsupported = append(ci.Unsupported, fmt.Sprintf("-H %s (removes a header)", val))
+			return
+		}
+		ci.HeaderSlice = append(ci.HeaderSlice, strings.TrimSpace(k)+":"+strings.TrimSpace(v))
+	case "user":
+		ci.AuthType, ci.Cred = "Password", val
+	case "url":
+		ci.URL = val
+	case "user-agent":
+		ci.HeaderSlice = append(ci.HeaderSlice, "User-Agent:"+val)
+	case "referer":
+		ci.HeaderSlice = append(ci.HeaderSlice, "Referer:"+val)
+	case "cookie":
+		if !strings.Contains(val, "=") {
+			ci.Unsupported = append(ci.Unsupported, fmt.Sprintf("-b %s (cookies from a file. use --session)", val))
+			return
+		}
+		ci.HeaderSlice = append(ci.HeaderSlice, "Cookie:"+val)
+	case "get":
+		*get = true
+	case "head":
+		*head = true
+	case "compressed":
+		*compressed = true
+	case "insecure":
+		ci.Notes = append(ci.Notes, "-k: not saved with the request. send it with --insecure, or set insecure: true in transport: of the group")
+	case "location":
+		ci.Notes = append(ci.Notes, "-L: redirects are followed by default")
+	case "max-time", "connect-timeout", "proxy", "cacert", "cert", "key", "max-redirs":
+		ci.Notes = append(ci.Notes, fmt.Sprintf("--%s %s: not saved with the request. set it in transport: of the group, or with the flag of the same name", name, val))
+	default:
+		if !curlOutputFlags[name] {
+			ci.Unsupported = append(ci.Unsupported, "--"+name)
+		}
+	}
+}
+
+// Sets the body from -d and -F values, as curl would send them
+func (ci *CurlImport) setBody(data, form []string, get bool) error {
+	switch {
+	case len(data) > 0 && len(form) > 0:
+		return fmt.Errorf("can't use -d and -F together")
+	case len(data) > 0 && get:
+		// -G sends the data as the query string
+		sep := "?"
+		if strings.Contains(ci.URL, "?") {
+			sep = "&"
+		}
+		ci.URL += sep + strings.Join(data, "&")
+	case len(data) > 0:
+		ci.Body = strings.Join(data, "&")
+		if ci.header("Content-Type") == "" {
+			ci.HeaderSlice = append(ci.HeaderSlice, "Content-Type:application/x-www-form-urlencoded")
+		}
+	case len(form) > 0:
+		fields := make([][2]string, 0, len(form))
+		for _, f := range form {
+			k, v, _ := strings.Cut(f, "=")
+			fields = append(fields, [2]string{k, v})
+		}
+		body, ct, err := multipartBody(fields)
+		if err != nil {
+			return err
+		}
+		ci.Body = body
+		ci.dropHeader("Content-Type")
+		ci.HeaderSlice = append(ci.HeaderSlice, "Content-Type:"+ct)
+	default:
+		return nil
+	}
+	if ci.Method == "" && !get {
+		ci.Method = http.MethodPost
+	}
+	return nil
+}
+
+func (ci *CurlImport) header(k string) string {
+	for _, h := range ci.HeaderSlice {
+		if hk, v, _ := strings.Cut(h, ":"); strings.EqualFold(hk, k) {
+			return v
+		}
+	}
+	return ""
+}
+
+func (ci *CurlImport) dropHeader(k string) {
+	kept := ci.HeaderSlice[:0]
+	for _, h := range ci.HeaderSlice {
+		if hk, _, _ := strings.Cut(h, ":"); !strings.EqualFold(hk, k) {
+			kept = append(kept, h)
+		}
+	}
+	ci.HeaderSlice = kept
+}
+
+// Encodes a --data-urlencode value: name=content encodes only the content
+func curlURLEncode(v string) string {
+	if k, c, ok := strings.Cut(v, "="); ok {
+		if k == "" {
+			return url.QueryEscape(c)
+		}
+		return k + "=" + url.QueryEscape(c)
+	}
+	return url.QueryEscape(v)
+}
+
+// Splits a command line into words as a POSIX shell would, handling 'single', "double"
+// and $'ansi-c' quotes, backslash escapes and line continuations.
+func splitShellWords(s string) ([]string, error) {
+	var words []string
+	var w strings.Builder
+	inWord := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s):
+			i++
+			if s[i] != '\n' && s[i] != '\r' {
+				w.WriteByte(s[i])
+				inWord = true
+			} else if s[i] == '\r' && i+1 < len(s) && s[i+1] == '\n' {
+				i++
+			}
+		case c == '\'':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("missing closing ' in curl command")
+			}
+			w.WriteString(s[i+1 : i+1+end])
+			i += end + 1
+			inWord = true
+		case c == '$' && i+1 < len(s) && s[i+1] == '\'':
+			n, err := ansiCQuote(s[i+2:], &w)
+			if err != nil {
+				return nil, err
+			}
+			i += n + 2
+			inWord = true
+		case c == '"':
+			i++
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) && strings.IndexByte("\"\\$`\n", s[i+1]) >= 0 {
+					i++
+					if s[i] == '\n' {
+						continue
+					}
+				}
+				w.WriteByte(s[i])
+			}
+			if i >= len(s) {
+				return nil, fmt.Errorf(`missing closing " in curl command`)
+			}
+			inWord = true
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inWord {
+				words = append(words, w.String())
+				w.Reset()
+				inWord = false
+			}
+		default:
+			w.WriteByte(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, w.String())
+	}
+	return words, nil
+}
+
+// Writes the content of a $'...' quote to w, and returns how many bytes of s it took up to the closing '
+func ansiCQuote(s string, w *strings.Builder) (int, error) {
+	esc := map[byte]byte{'n': '\n', 't': '\t', 'r': '\r', '\\': '\\', '\'': '\'', '"': '"', '0': 0}
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\'':
+			return i, nil
+		case s[i] == '\\' && i+1 < len(s):
+			i++
+			if e, ok := esc[s[i]]; ok {
+				w.WriteByte(e)
+			} else if n := map[byte]int{'x': 2, 'u': 4, 'U': 8}[s[i]]; n > 0 && i+n < len(s) {
+				r, err := strconv.ParseUint(s[i+1:i+1+n], 16, 32)
+				if err != nil {
+					return 0, fmt.Errorf("bad \\%c escape in curl command: %w", s[i], err)
+				}
+				if s[i] == 'x' {
+					w.WriteByte(byte(r))
+				} else {
+					w.WriteRune(rune(r))
+				}
+				i += n
+			} else {
+				w.WriteByte('\\')
+				w.WriteByte(s[i])
+			}
+		default:
+			w.WriteByte(s[i])
+		}
+	}
+	return 0, fmt.Errorf("missing closing ' in curl command")
+}
@@ -0,0 +1,155 @@
+package client
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jerempy/brang/config"
+)
+
+func TestSplitShellWords(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		want    []string
+		wantErr bool
+	}{
+		"plain":        {in: "curl https://mysite.com", want: []string{"curl", "https://mysite.com"}},
+		"single":       {in: `-H 'a: "b"'`, want: []string{"-H", `a: "b"`}},
+		"double":       {in: `-d "{\"id\": 1}"`, want: []string{"-d", `{"id": 1}`}},
+		"continuation": {in: "curl \\\n  -X POST", want: []string{"curl", "-X", "POST"}},
+		"ansi c":       {in: `--data-raw $'{"a":"b\ncé"}'`, want: []string{"--data-raw", "{\"a\":\"b\ncé\"}"}},
+		"joined":       {in: `a'b'"c"`, want: []string{"abc"}},
+		"empty quote":  {in: `-d ''`, want: []string{"-d", ""}},
+		"unclosed":     {in: `-d 'abc`, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := splitShellWords(tc.in)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("%v: got err %v - want err %v", tc.in, err, tc.wantErr)
+			}
+			if !tc.wantErr && !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("%v: got %q - want %q", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseCurl(t *testing.T) {
+	tests := map[string]struct {
+		in              string
+		wantMethod      string
+		wantURL         string
+		wantBody        string
+		wantHeaders     []string
+		wantCred        string
+		wantUnsupported int
+		wantNotes       int
+		wantErr         bool
+	}{
+		"get": {
+			in: "curl 'https://mysite.com/users?page=2' -H 'Accept: application/json'", wantMethod: "GET",
+			wantURL: "https://mysite.com/users?page=2", wantHeaders: []string{"Accept:application/json"},
+		},
+		"post json": {
+			in:         `curl -X POST https://mysite.com/orders -H 'Content-Type: application/json' --data-raw '{"id": 1}'`,
+			wantMethod: "POST", wantURL: "https://mysite.com/orders", wantBody: `{"id": 1}`,
+			wantHeaders: []string{"Content-Type:application/json"},
+		},
+		"data is a post form": {
+			in: "curl https://mysite.com/login -d user=joe -d 'pass=a b'", wantMethod: "POST", wantURL: "https://mysite.com/login",
+			wantBody: "user=joe&pass=a b", wantHeaders: []string{"Content-Type:application/x-www-form-urlencoded"},
+		},
+		"get with data": {
+			in: "curl -G https://mysite.com/search?x=1 -d q=shoes", wantMethod: "GET", wantURL: "https://mysite.com/search?x=1&q=shoes",
+		},
+		"user and insecure": {
+			in: "curl -sSk -u joe:secret --url mysite.com", wantMethod: "GET", wantURL: "http://mysite.com",
+			wantCred: "joe:secret", wantNotes: 1,
+		},
+		"attached value": {
+			in: "curl -XPUT https://mysite.com -d'a=1'", wantMethod: "PUT", wantURL: "https://mysite.com", wantBody: "a=1",
+			wantHeaders: []string{"Content-Type:application/x-www-form-urlencoded"},
+		},
+		"compressed drops accept encoding": {
+			in: "curl https://mysite.com -H 'accept-encoding: gzip, br' -H 'x-a: 1' --compressed", wantMethod: "GET",
+			wantURL: "https://mysite.com", wantHeaders: []string{"x-a:1"}, wantNotes: 1,
+		},
+		"unsupported": {
+			in: "curl https://mysite.com -d @body.json --netrc -F file=@a.png", wantMethod: "GET", wantURL: "https://mysite.com",
+			wantUnsupported: 3,
+		},
+		"unsupported with values": {
+			in:         `curl --json '{"a": 1}' -T up.txt --resolve mysite.com:443:127.0.0.1 https://mysite.com --oauth2-bearer tok --aws-sigv4 aws:amz:us-east-1:s3`,
+			wantMethod: "GET", wantURL: "https://mysite.com", wantUnsupported: 5,
+		},
+		"output with values": {
+			in: "curl -D headers.txt --trace-ascii - https://mysite.com -o out.json", wantMethod: "GET", wantURL: "https://mysite.com",
+		},
+		"no url":        {in: "curl -X POST", wantErr: true},
+		"two urls":      {in: "curl https://a.com https://b.com", wantErr: true},
+		"missing value": {in: "curl https://a.com -H", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseCurl(tc.in)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("%v: got err %v - want err %v", tc.in, err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			if got.Method != tc.wantMethod || got.URL != tc.wantURL || got.Body != tc.wantBody || got.Cred != tc.wantCred {
+				t.Errorf("got %s %s %q cred %q - want %s %s %q cred %q",
+					got.Method, got.URL, got.Body, got.Cred, tc.wantMethod, tc.wantURL, tc.wantBody, tc.wantCred)
+			}
+			if len(got.HeaderSlice) != len(tc.wantHeaders) || len(tc.wantHeaders) > 0 && !reflect.DeepEqual(got.HeaderSlice, tc.wantHeaders) {
+				t.Errorf("headers: got %q - want %q", got.HeaderSlice, tc.wantHeaders)
+			}
+			if len(got.Unsupported) != tc.wantUnsupported || len(got.Notes) != tc.wantNotes {
+				t.Errorf("unsupported and notes: got %q %q - want %d and %d", got.Unsupported, got.Notes, tc.wantUnsupported, tc.wantNotes)
+			}
+		})
+	}
+}
+
+func TestParseCurlForm(t *testing.T) {
+	got, err := ParseCurl("curl https://mysite.com/upload -F name=joe -F 'note=hi there'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Method != "POST" || !strings.Contains(got.Body, `name="note"`) || !strings.Contains(got.Body, "hi there") {
+		t.Fatalf("got %s %s - want a POST of the multipart form", got.Method, got.Body)
+	}
+	boundary := strings.TrimPrefix(got.HeaderSlice[0], "Content-Type:multipart/form-data; boundary=")
+	if boundary == got.HeaderSlice[0] || !strings.Contains(got.Body, boundary) {
+		t.Errorf("got %s - want the content type to have the boundary of the body", got.HeaderSlice[0])
+	}
+}
+
+func TestImportCurl(t *testing.T) {
+	config.Requests.SetConfigFile(t.TempDir() + "/requests.yaml")
+	defer config.Requests.SetConfigFile("")
+	ci, err := ParseCurl(`curl https://mysite.com/orders -H 'Authorization: Bearer abc' --data-raw '{"id": 1}'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	envs, _, err := SaveRequest(ci.RequestSet, "testspace.orders.create")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(envs) != 1 || envs[0] != "TESTSPACE_TOKEN" {
+		t.Errorf("envs: got %v - want [TESTSPACE_TOKEN]", envs)
+	}
+	var sr SavedRequestSet
+	if err := decodeNode(config.Requests.Lookup("testspace", "requests", "orders", "create"), &sr); err != nil {
+		t.Fatal(err)
+	}
+	if sr.Method != "POST" || sr.Body != `{"id": 1}` || sr.URL != "https://mysite.com/orders" || len(sr.Header) != 1 {
+		t.Errorf("got %+v - want a POST of the body to https://mysite.com/orders with one header", sr)
+	}
+	if a, _ := savedAuth(config.Requests, "testspace"); a == nil || a.AuthType != "Bearer" || a.Token != "$TESTSPACE_TOKEN" {
+		t.Errorf("group auth: got %+v - want Bearer of $TESTSPACE_TOKEN", a)
+	}
+}
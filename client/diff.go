@@ -0,0 +1,440 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jerempy/brang/config"
+)
+
+// DiffSide is a response to compare with 'brang diff': from a history entry, a file, or a
+// saved request sent live. Status is 0 and Header nil when the side doesn't have them.
+type DiffSide struct {
+	// where the response came from, such as history #42
+	Source string
+	Status int
+	Header http.Header
+	Body   string
+}
+
+// LoadDiffSide gets the response of arg: a file, a history id, or a saved request to send
+// with base, as name or name@env. A file holds the output of -o json or 'history show --json',
+// or only a body.
+func LoadDiffSide(arg string, base *RequestSet) (*DiffSide, error) {
+	if fi, err := os.Stat(arg); err == nil && !fi.IsDir() {
+		return diffSideFile(arg)
+	} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if id, err := strconv.Atoi(arg); err == nil {
+		e, err := LoadHistory(id)
+		if err != nil {
+			return nil, err
+		}
+		return &DiffSide{Source: fmt.Sprintf("history #%d", id), Status: e.Status, Header: e.ResponseHeader, Body: e.ResponseBody}, nil
+	}
+	rset := base.clone()
+	name, env, ok := strings.Cut(arg, "@")
+	if ok {
+		rset.Env = env
+	}
+	rn := &Runner{Base: rset, Quiet: true}
+	r := rn.Run([]string{name})[0]
+	if r.Err != nil {
+		return nil, fmt.Errorf("err sending %s: %w", name, r.Err)
+	}
+	src := name + " (live)"
+	if r.Response.Env != "" {
+		src = fmt.Sprintf("%s@%s (live)", name, r.Response.Env)
+	}
+	return &DiffSide{Source: src, Status: r.Status, Header: redactSecretHeaders(r.Response.Header), Body: r.Response.StringResponseBody()}, nil
+}
+
+// Reads a side from a file of -o json output, 'history show --json', or a body
+func diffSideFile(file string) (*DiffSide, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("err reading %s: %w", file, err)
+	}
+	side := &DiffSide{Source: file, Body: string(b)}
+	var f struct {
+		Response *struct {
+			StatusCode int             `json:"statusCode"`
+			Header     http.Header     `json:"header"`
+			Body       json.RawMessage `json:"body"`
+		} `json:"response"`
+		Status         *int        `json:"status"`
+		ResponseHeader http.Header `json:"responseHeader"`
+		ResponseBody   *string     `json:"responseBody"`
+	}
+	if json.Unmarshal(b, &f) != nil {
+		return side, nil
+	}
+	switch {
+	case f.Response != nil && f.Response.StatusCode != 0:
+		side.Status, side.Header, side.Body = f.Response.StatusCode, f.Response.Header, string(f.Response.Body)
+		// a body that isn't json is written as a json string
+		var s string
+		if json.Unmarshal(f.Response.Body, &s) == nil {
+			side.Body = s
+		}
+	case f.Status != nil && f.ResponseHeader != nil:
+		side.Status, side.Header, side.Body = *f.Status, f.ResponseHeader, ""
+		if f.ResponseBody != nil {
+			side.Body = *f.ResponseBody
+		}
+	}
+	return side, nil
+}
+
+// ResponseDiff is how response b differs from response a
+type ResponseDiff struct {
+	A      string   `json:"a"`
+	B      string   `json:"b"`
+	Status *Change  `json:"status,omitempty"`
+	Header []Change `json:"header"`
+	Body   []Change `json:"body"`
+}
+
+// Change is a value added, removed or changed at a path: a json path of the body such as
+// $.items[3].price, a header name, or a line of a body that isn't json
+type Change struct {
+	Path string      `json:"path"`
+	Kind string      `json:"kind"`
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+const (
+	added   = "added"
+	removed = "removed"
+	changed = "changed"
+)
+
+// Same is true when there are no differences
+func (d *ResponseDiff) Same() bool {
+	return d.Status == nil && len(d.Header) == 0 && len(d.Body) == 0
+}
+
+// headers that differ on every response, always ignored
+var volatileHeaders = []string{"Date"}
+
+// DiffResponses compares b to a. Json bodies are compared value by value, other bodies line
+// by line. ignore has json paths of the body, such as $..updatedAt or $.items[*].id, and
+// header:<name> for headers, whose differences are left out.
+func DiffResponses(a, b *DiffSide, ignore []string) (*ResponseDiff, error) {
+	d := &ResponseDiff{A: a.Source, B: b.Source, Header: []Change{}, Body: []Change{}}
+	var paths []*jsonPath
+	ignoreHeaders := map[string]bool{}
+	for _, rule := range ignore {
+		if h, ok := strings.CutPrefix(rule, "header:"); ok {
+			ignoreHeaders[http.CanonicalHeaderKey(strings.TrimSpace(h))] = true
+			continue
+		}
+		jp, err := parseJSONPath(rule)
+		if err != nil {
+			return nil, fmt.Errorf("ignore rules are json paths or header:<name>: %w", err)
+		}
+		paths = append(paths, jp)
+	}
+	if a.Status != 0 && b.Status != 0 && a.Status != b.Status {
+		d.Status = &Change{Path: "status", Kind: changed, Old: a.Status, New: b.Status}
+	}
+	if a.Header != nil && b.Header != nil {
+		d.Header = diffHeaders(a.Header, b.Header, ignoreHeaders)
+	}
+	av, aErr := decodeJSON([]byte(a.Body))
+	bv, bErr := decodeJSON([]byte(b.Body))
+	if aErr == nil && bErr == nil {
+		diffJSON(nil, av, bv, paths, &d.Body)
+	} else {
+		d.Body = diffLines(a.Body, b.Body)
+	}
+	return d, nil
+}
+
+// DiffIgnoreRules returns the ignore rules of diff: in config.yaml, and the volatile headers
+func DiffIgnoreRules() []string {
+	var rules []string
+	for _, h := range volatileHeaders {
+		rules = append(rules, "header:"+h)
+	}
+	return append(rules, config.Brang.GetStringSlice("diff.ignore")...)
+}
+
+func diffHeaders(a, b http.Header, ignore map[string]bool) []Change {
+	keys := map[string]bool{}
+	for k := range a {
+		keys[http.CanonicalHeaderKey(k)] = true
+	}
+	for k := range b {
+		keys[http.CanonicalHeaderKey(k)] = true
+	}
+	changes := []Change{}
+	for _, k := range sortedKeys(keys) {
+		if ignore[k] {
+			continue
+		}
+		av, aok := a.Values(k), len(a.Values(k)) > 0
+		bv, bok := b.Values(k), len(b.Values(k)) > 0
+		as, bs := strings.Join(av, ", "), strings.Join(bv, ", ")
+		switch {
+		case !aok:
+			changes = append(changes, Change{Path: k, Kind: added, New: bs})
+		case !bok:
+			changes = append(changes, Change{Path: k, Kind: removed, Old: as})
+		case as != bs:
+			changes = append(changes, Change{Path: k, Kind: changed, Old: as, New: bs})
+		}
+	}
+	return changes
+}
+
+// Adds the changes from a to b at path, leaving out paths matching ignore
+func diffJSON(path []pathStep, a, b interface{}, ignore []*jsonPath, changes *[]Change) {
+	for _, jp := range ignore {
+		if jp.matches(path) {
+			return
+		}
+	}
+	switch at := a.(type) {
+	case map[string]interface{}:
+		if bt, ok := b.(map[string]interface{}); ok {
+			keys := map[string]bool{}
+			for k := range at {
+				keys[k] = true
+			}
+			for k := range bt {
+				keys[k] = true
+			}
+			for _, k := range sortedKeys(keys) {
+				p := append(path[:len(path):len(path)], pathStep{key: k})
+				av, aok := at[k]
+				bv, bok := bt[k]
+				switch {
+				case !aok:
+					addChange(p, added, nil, bv, ignore, changes)
+				case !bok:
+					addChange(p, removed, av, nil, ignore, changes)
+				default:
+					diffJSON(p, av, bv, ignore, changes)
+				}
+			}
+			return
+		}
+	case []interface{}:
+		if bt, ok := b.([]interface{}); ok {
+			for i := 0; i < len(at) || i < len(bt); i++ {
+				p := append(path[:len(path):len(path)], pathStep{index: i, isIndex: true})
+				switch {
+				case i >= len(at):
+					addChange(p, added, nil, bt[i], ignore, changes)
+				case i >= len(bt):
+					addChange(p, removed, at[i], nil, ignore, changes)
+				default:
+					diffJSON(p, at[i], bt[i], ignore, changes)
+				}
+			}
+			return
+		}
+	}
+	if !jsonEqual(a, b) {
+		*changes = append(*changes, Change{Path: formatPath(path), Kind: changed, Old: a, New: b})
+	}
+}
+
+func addChange(path []pathStep, kind string, old, new interface{}, ignore []*jsonPath, changes *[]Change) {
+	for _, jp := range ignore {
+		if jp.matches(path) {
+			return
+		}
+	}
+	*changes = append(*changes, Change{Path: formatPath(path), Kind: kind, Old: old, New: new})
+}
+
+func jsonEqual(a, b interface{}) bool {
+	ab, _ := json.Marshal(a)
+	bb, _ := json.Marshal(b)
+	return string(ab) == string(bb)
+}
+
+// keys that can be written as .key in a json path
+var plainKey = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$-]*$`)
+
+// Formats the steps of a path of a value, such as $.items[3].price
+func formatPath(path []pathStep) string {
+	var b strings.Builder
+	b.WriteString("$")
+	for _, s := range path {
+		switch {
+		case s.isIndex:
+			fmt.Fprintf(&b, "[%d]", s.index)
+		case plainKey.MatchString(s.key):
+			b.WriteString("." + s.key)
+		default:
+			fmt.Fprintf(&b, "['%s']", s.key)
+		}
+	}
+	return b.String()
+}
+
+// matches is true when jp matches the path of a value, as Find would find it
+func (jp *jsonPath) matches(path []pathStep) bool {
+	var match func(i, j int) bool
+	match = func(i, j int) bool {
+		if i == len(jp.steps) {
+			return j == len(path)
+		}
+		s := jp.steps[i]
+		if s.recursive {
+			for k := j; k < len(path); k++ {
+				if s.matchesStep(path[k]) && match(i+1, k+1) {
+					return true
+				}
+			}
+			return false
+		}
+		return j < len(path) && s.matchesStep(path[j]) && match(i+1, j+1)
+	}
+	return match(0, 0)
+}
+
+func (s pathStep) matchesStep(p pathStep) bool {
+	switch {
+	case s.wildcard:
+		return true
+	case s.isIndex:
+		return p.isIndex && p.index == s.index
+	default:
+		return !p.isIndex && p.key == s.key
+	}
+}
+
+// most lines of each body to compare line by line
+const maxDiffLines = 2000
+
+// Compares bodies that aren't json line by line
+func diffLines(a, b string) []Change {
+	changes := []Change{}
+	if a == b {
+		return changes
+	}
+	al, bl := strings.Split(a, "\n"), strings.Split(b, "\n")
+	if len(al) > maxDiffLines || len(bl) > maxDiffLines {
+		return append(changes, Change{Path: "body", Kind: changed, Old: fmt.Sprintf("%d bytes", len(a)), New: fmt.Sprintf("%d bytes", len(b))})
+	}
+	// lcs[i][j] is the longest common subsequence of al[i:] and bl[j:]
+	lcs := make([][]int, len(al)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(bl)+1)
+	}
+	for i := len(al) - 1; i >= 0; i-- {
+		for j := len(bl) - 1; j >= 0; j-- {
+			if al[i] == bl[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(al) || j < len(bl) {
+		switch {
+		case i < len(al) && j < len(bl) && al[i] == bl[j]:
+			i, j = i+1, j+1
+		case j < len(bl) && (i == len(al) || lcs[i][j+1] >= lcs[i+1][j]):
+			changes = append(changes, Change{Path: fmt.Sprintf("line %d", j+1), Kind: added, New: bl[j]})
+			j++
+		default:
+			changes = append(changes, Change{Path: fmt.Sprintf("line %d", i+1), Kind: removed, Old: al[i]})
+			i++
+		}
+	}
+	return changes
+}
+
+const (
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+	colorBold   = "\033[1m"
+	colorReset  = "\033[0m"
+)
+
+// WriteDiff writes the differences for reading in a terminal, in color if color
+func WriteDiff(w io.Writer, d *ResponseDiff, color bool) {
+	paint := func(c, s string) string {
+		if !color {
+			return s
+		}
+		return c + s + colorReset
+	}
+	fmt.Fprintln(w, paint(colorBold, "--- "+d.A))
+	fmt.Fprintln(w, paint(colorBold, "+++ "+d.B))
+	if d.Same() {
+		fmt.Fprintln(w, "no differences")
+		return
+	}
+	if d.Status != nil {
+		fmt.Fprintln(w, paint(colorYellow, fmt.Sprintf("~ status: %v -> %v", d.Status.Old, d.Status.New)))
+	}
+	for _, section := range []struct {
+		prefix  string
+		changes []Change
+	}{{"header ", d.Header}, {"", d.Body}} {
+		for _, c := range section.changes {
+			path := section.prefix + c.Path
+			switch c.Kind {
+			case added:
+				fmt.Fprintln(w, paint(colorGreen, fmt.Sprintf("+ %s: %s", path, diffValue(c.New))))
+			case removed:
+				fmt.Fprintln(w, paint(colorRed, fmt.Sprintf("- %s: %s", path, diffValue(c.Old))))
+			default:
+				fmt.Fprintln(w, paint(colorYellow, fmt.Sprintf("~ %s: %s -> %s", path, diffValue(c.Old), diffValue(c.New))))
+			}
+		}
+	}
+	if n := d.count(); n == 1 {
+		fmt.Fprintln(w, "1 difference")
+	} else {
+		fmt.Fprintf(w, "%d differences\n", n)
+	}
+}
+
+func (d *ResponseDiff) count() int {
+	n := len(d.Header) + len(d.Body)
+	if d.Status != nil {
+		n++
+	}
+	return n
+}
+
+// Formats a value of a change as json on one line, cut to fit a terminal
+func diffValue(v interface{}) string {
+	b, _ := json.Marshal(v)
+	if s := string(b); len(s) <= 120 {
+		return s
+	}
+	return string(b[:117]) + "..."
+}
+
+// WriteDiffJSON writes the differences as json, for scripts
+func WriteDiffJSON(w io.Writer, d *ResponseDiff) error {
+	out := struct {
+		*ResponseDiff
+		Same bool `json:"same"`
+	}{d, d.Same()}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
@@ -0,0 +1,141 @@
+package client
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffResponses(t *testing.T) {
+	a := &DiffSide{Source: "a", Status: 200, Header: http.Header{"Date": {"Mon"}, "Etag": {"1"}, "X-Old": {"o"}},
+		Body: `{"id": 1, "items": [{"price": 10, "at": "x"}, {"price": 5}], "name": "a", "gone": true}`}
+	b := &DiffSide{Source: "b", Status: 500, Header: http.Header{"Date": {"Tue"}, "Etag": {"2"}, "X-New": {"n"}},
+		Body: `{"id": 2, "items": [{"price": 12, "at": "y"}, {"price": 5}, {"price": 1}], "name": "a", "new": null}`}
+	tests := map[string]struct {
+		ignore     []string
+		wantStatus bool
+		wantHeader []string
+		wantBody   []string
+	}{
+		"all": {
+			ignore:     []string{"header:Date"},
+			wantStatus: true,
+			wantHeader: []string{"~ Etag", "+ X-New", "- X-Old"},
+			wantBody:   []string{"- $.gone", "~ $.id", "~ $.items[0].at", "~ $.items[0].price", "+ $.items[2]", "+ $.new"},
+		},
+		"ignored": {
+			ignore:     []string{"header:date", "header:Etag", "$.id", "$.items[*].at", "$..gone", "$.items[2]"},
+			wantStatus: true,
+			wantHeader: []string{"+ X-New", "- X-Old"},
+			wantBody:   []string{"~ $.items[0].price", "+ $.new"},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			d, err := DiffResponses(a, b, tc.ignore)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (d.Status != nil) != tc.wantStatus {
+				t.Errorf("status change: got %v", d.Status)
+			}
+			if got := changeList(d.Header); !reflect.DeepEqual(got, tc.wantHeader) {
+				t.Errorf("header: got %v - want %v", got, tc.wantHeader)
+			}
+			if got := changeList(d.Body); !reflect.DeepEqual(got, tc.wantBody) {
+				t.Errorf("body: got %v - want %v", got, tc.wantBody)
+			}
+		})
+	}
+	if _, err := DiffResponses(a, b, []string{"items"}); err == nil {
+		t.Error("should err on a bad ignore rule")
+	}
+}
+
+// Lists the changes as kind and path, such as ~ $.id
+func changeList(changes []Change) []string {
+	kinds := map[string]string{added: "+", removed: "-", changed: "~"}
+	var l []string
+	for _, c := range changes {
+		l = append(l, kinds[c.Kind]+" "+c.Path)
+	}
+	return l
+}
+
+func TestDiffLines(t *testing.T) {
+	got := changeList(diffLines("a\nb\nc\nd", "a\nc\nx\nd"))
+	want := []string{"- line 2", "+ line 3"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v - want %v", got, want)
+	}
+	if len(diffLines("same", "same")) != 0 {
+		t.Error("same lines should have no changes")
+	}
+}
+
+func TestJSONPathMatches(t *testing.T) {
+	path := []pathStep{{key: "data"}, {index: 3, isIndex: true}, {key: "id"}}
+	tests := map[string]bool{
+		"$.data[3].id":   true,
+		"$.data[*].id":   true,
+		"$..id":          true,
+		"$..[3].id":      true,
+		"$.*.*.id":       true,
+		"$.data[2].id":   false,
+		"$.data":         false,
+		"$.data[3].id.x": false,
+		"$..data.id":     false,
+	}
+	for p, want := range tests {
+		jp, err := parseJSONPath(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := jp.matches(path); got != want {
+			t.Errorf("%s: got %v - want %v", p, got, want)
+		}
+	}
+	if got := formatPath(append(path, pathStep{key: "a.b"})); got != "$.data[3].id['a.b']" {
+		t.Errorf("got %v - want $.data[3].id['a.b']", got)
+	}
+}
+
+func TestDiffSideFile(t *testing.T) {
+	dir := t.TempDir()
+	tests := map[string]struct {
+		in   string
+		want DiffSide
+	}{
+		"json output": {
+			in:   `{"request": {}, "response": {"statusCode": 201, "header": {"A": ["1"]}, "body": {"id": 1}}}`,
+			want: DiffSide{Status: 201, Header: http.Header{"A": {"1"}}, Body: `{"id": 1}`},
+		},
+		"json output of text": {
+			in:   `{"response": {"statusCode": 200, "header": {}, "body": "hi"}}`,
+			want: DiffSide{Status: 200, Header: http.Header{}, Body: "hi"},
+		},
+		"history entry": {
+			in:   `{"id": 3, "status": 404, "responseHeader": {"A": ["1"]}, "responseBody": "nope"}`,
+			want: DiffSide{Status: 404, Header: http.Header{"A": {"1"}}, Body: "nope"},
+		},
+		"body": {in: `{"status": "ok"}`, want: DiffSide{Body: `{"status": "ok"}`}},
+		"text": {in: "plain\ntext", want: DiffSide{Body: "plain\ntext"}},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			f := filepath.Join(dir, strings.ReplaceAll(name, " ", "-"))
+			os.WriteFile(f, []byte(tc.in), 0644)
+			got, err := diffSideFile(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tc.want.Source = f
+			if !reflect.DeepEqual(*got, tc.want) {
+				t.Errorf("got %+v - want %+v", *got, tc.want)
+			}
+		})
+	}
+}
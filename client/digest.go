@@ -0,0 +1,252 @@
+package client
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// digestChallenge is a Digest challenge of a WWW-Authenticate header (RFC 7616), kept to
+// answer later requests with, counting the times its nonce was used
+type digestChallenge struct {
+	host      string
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	// auth when the server offers it, else empty for the old RFC 2069 digest
+	qop string
+	nc  int
+}
+
+// digestAuth answers the Digest challenges of servers with a username and password
+type digestAuth struct {
+	username, password string
+	// key of the challenge in digestChallenges: the group of the request, or its host
+	key string
+}
+
+var (
+	// challenges got by earlier requests, so the requests of a group answer them
+	// straight away instead of each getting a 401 first
+	digestChallenges = map[string]*digestChallenge{}
+	digestMu         sync.Mutex
+)
+
+// Returns the Digest auth of cred as username:password, for the group of the request or its host
+func newDigestAuth(cred, group, host string) *digestAuth {
+	user, pass, _ := strings.Cut(cred, ":")
+	key := group
+	if key == "" {
+		key = host
+	}
+	return &digestAuth{username: user, password: pass, key: key}
+}
+
+// Returns the challenge of the header, preferring SHA-256 over MD5. nil when there is no Digest
+// challenge of an algorithm brang supports.
+func parseDigestChallenge(h http.Header) *digestChallenge {
+	var best *digestChallenge
+	for _, v := range h.Values("WWW-Authenticate") {
+		for _, params := range parseChallenges(v) {
+			if !strings.EqualFold(params[""], "Digest") {
+				continue
+			}
+			c := &digestChallenge{realm: params["realm"], nonce: params["nonce"], opaque: params["opaque"], algorithm: params["algorithm"]}
+			if c.algorithm == "" {
+				c.algorithm = "MD5"
+			}
+			if digestHash(c.algorithm) == nil || c.nonce == "" {
+				continue
+			}
+			if qop, ok := params["qop"]; ok {
+				for _, q := range strings.Split(qop, ",") {
+					if strings.TrimSpace(q) == "auth" {
+						c.qop = "auth"
+					}
+				}
+				// only auth-int is offered, which needs the whole body hashed
+				if c.qop == "" {
+					continue
+				}
+			}
+			if best == nil || strings.HasPrefix(strings.ToUpper(c.algorithm), "SHA-256") && !strings.HasPrefix(strings.ToUpper(best.algorithm), "SHA-256") {
+				best = c
+			}
+		}
+	}
+	return best
+}
+
+// Splits the value of a WWW-Authenticate header into its challenges, each a map of its params
+// with the scheme at "". Param names are lower case.
+func parseChallenges(v string) []map[string]string {
+	var out []map[string]string
+	var cur map[string]string
+	for i := 0; i < len(v); {
+		for i < len(v) && (v[i] == ' ' || v[i] == ',' || v[i] == '\t') {
+			i++
+		}
+		start := i
+		for i < len(v) && v[i] != '=' && v[i] != ' ' && v[i] != ',' {
+			i++
+		}
+		tok := v[start:i]
+		if tok == "" {
+			break
+		}
+		if i >= len(v) || v[i] != '=' {
+			// a token without a value starts the next challenge
+			cur = map[string]string{"": tok}
+			out = append(out, cur)
+			continue
+		}
+		i++
+		var val strings.Builder
+		if i < len(v) && v[i] == '"' {
+			for i++; i < len(v) && v[i] != '"'; i++ {
+				if v[i] == '\\' && i+1 < len(v) {
+					i++
+				}
+				val.WriteByte(v[i])
+			}
+			i++
+		} else {
+			for ; i < len(v) && v[i] != ','; i++ {
+				val.WriteByte(v[i])
+			}
+		}
+		if cur != nil {
+			cur[strings.ToLower(tok)] = strings.TrimSpace(val.String())
+		}
+	}
+	return out
+}
+
+// Returns the hash of a Digest algorithm, with or without -sess. nil for one brang doesn't support
+func digestHash(algorithm string) func() hash.Hash {
+	switch strings.TrimSuffix(strings.ToUpper(algorithm), "-SESS") {
+	case "MD5":
+		return md5.New
+	case "SHA-256":
+		return sha256.New
+	}
+	return nil
+}
+
+// Returns the Authorization header answering c for a request of method to uri, with the
+// client nonce cnonce
+func (c *digestChallenge) authorization(username, password, method, uri, cnonce string) string {
+	newHash := digestHash(c.algorithm)
+	h := func(s string) string {
+		hs := newHash()
+		io.WriteString(hs, s)
+		return hex.EncodeToString(hs.Sum(nil))
+	}
+	nc := fmt.Sprintf("%08x", c.nc)
+	ha1 := h(username + ":" + c.realm + ":" + password)
+	if strings.HasSuffix(strings.ToUpper(c.algorithm), "-SESS") {
+		ha1 = h(ha1 + ":" + c.nonce + ":" + cnonce)
+	}
+	ha2 := h(method + ":" + uri)
+	var response string
+	if c.qop == "" {
+		response = h(ha1 + ":" + c.nonce + ":" + ha2)
+	} else {
+		response = h(strings.Join([]string{ha1, c.nonce, nc, cnonce, c.qop, ha2}, ":"))
+	}
+	q := func(s string) string {
+		return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+	}
+	a := fmt.Sprintf("Digest username=%s, realm=%s, nonce=%s, uri=%s, algorithm=%s, response=%s",
+		q(username), q(c.realm), q(c.nonce), q(uri), c.algorithm, q(response))
+	if c.qop != "" {
+		a += fmt.Sprintf(", qop=%s, nc=%s, cnonce=%s", c.qop, nc, q(cnonce))
+	}
+	if c.opaque != "" {
+		a += ", opaque=" + q(c.opaque)
+	}
+	return a
+}
+
+// Returns the Authorization header answering the challenge cached for the auth, when there is
+// one for the host of r, counting the use of its nonce
+func (d *digestAuth) header(r *http.Request) (string, error) {
+	digestMu.Lock()
+	defer digestMu.Unlock()
+	c := digestChallenges[d.key]
+	if c == nil || c.host != r.URL.Host {
+		return "", nil
+	}
+	c.nc++
+	cnonce, err := randomString(12)
+	if err != nil {
+		return "", err
+	}
+	return c.authorization(d.username, d.password, r.Method, r.URL.RequestURI(), cnonce), nil
+}
+
+// digestTransport answers the Digest challenge of a 401 and sends the request again. The
+// challenge is kept so later requests of the group answer it without getting a 401 first.
+type digestTransport struct {
+	auth *digestAuth
+	base http.RoundTripper
+}
+
+func (t *digestTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	h, err := t.auth.header(r)
+	if err != nil {
+		return nil, err
+	}
+	sent := r
+	if h != "" {
+		sent = r.Clone(r.Context())
+		sent.Header.Set("Authorization", h)
+	}
+	resp, err := t.base.RoundTrip(sent)
+	// a body that was read can't be sent again
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || r.Body != nil && r.Body != http.NoBody && r.GetBody == nil {
+		return resp, err
+	}
+	c := parseDigestChallenge(resp.Header)
+	if c == nil {
+		return resp, nil
+	}
+	c.host = r.URL.Host
+	digestMu.Lock()
+	digestChallenges[t.auth.key] = c
+	digestMu.Unlock()
+	if h, err = t.auth.header(r); err != nil {
+		return resp, nil
+	}
+	r2 := r.Clone(r.Context())
+	if r.GetBody != nil {
+		if r2.Body, err = r.GetBody(); err != nil {
+			return resp, nil
+		}
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	r2.Header.Set("Authorization", h)
+	return t.base.RoundTrip(r2)
+}
+
+// Returns a copy of c answering Digest challenges with d, or c when d is nil
+func (c *brangClient) withDigest(d *digestAuth) *brangClient {
+	if d == nil {
+		return c
+	}
+	hc := *c.Client
+	base := hc.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	hc.Transport = &digestTransport{d, base}
+	return &brangClient{&hc}
+}
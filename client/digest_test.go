@@ -0,0 +1,122 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jerempy/brang/config"
+)
+
+// the examples of RFC 7616 section 3.9.1
+func TestDigestAuthorization(t *testing.T) {
+	tests := map[string]string{
+		"MD5":     "8ca523f5e9506fed4657c9700eebdbec",
+		"SHA-256": "753927fa0e85d155564e2e272a28d1802ca10daf4496794697cf8db5856cb6c1",
+	}
+	for alg, want := range tests {
+		t.Run(alg, func(t *testing.T) {
+			c := &digestChallenge{realm: "http-auth@example.org", nonce: "7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v",
+				opaque: "FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS", algorithm: alg, qop: "auth", nc: 1}
+			got := c.authorization("Mufasa", "Circle of Life", "GET", "/dir/index.html", "f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ")
+			if !strings.Contains(got, `response="`+want+`"`) || !strings.Contains(got, "nc=00000001") {
+				t.Errorf("got %v - want response %v", got, want)
+			}
+		})
+	}
+}
+
+func TestParseDigestChallenge(t *testing.T) {
+	tests := map[string]struct {
+		in      []string
+		wantAlg string
+		wantQop string
+	}{
+		"sha-256 over md5": {[]string{
+			`Digest realm="r", qop="auth, auth-int", algorithm=MD5, nonce="n1", opaque="o"`,
+			`Digest realm="r", qop="auth", algorithm=SHA-256, nonce="n2", opaque="o"`,
+		}, "SHA-256", "auth"},
+		"in one header": {[]string{`Basic realm="r", Digest realm="r, with comma", nonce="n", qop="auth"`}, "MD5", "auth"},
+		"rfc 2069":      {[]string{`Digest realm="r", nonce="n"`}, "MD5", ""},
+		"only auth-int": {[]string{`Digest realm="r", nonce="n", qop="auth-int"`}, "", ""},
+		"not supported": {[]string{`Digest realm="r", nonce="n", algorithm=SHA-512-256`}, "", ""},
+		"not digest":    {[]string{`Bearer realm="r"`}, "", ""},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := parseDigestChallenge(http.Header{"Www-Authenticate": tc.in})
+			if tc.wantAlg == "" {
+				if c != nil {
+					t.Errorf("got %+v - want no challenge", c)
+				}
+				return
+			}
+			if c == nil || c.algorithm != tc.wantAlg || c.qop != tc.wantQop || c.realm == "" {
+				t.Errorf("got %+v - want %v %v", c, tc.wantAlg, tc.wantQop)
+			}
+		})
+	}
+}
+
+func TestDigestRunner(t *testing.T) {
+	defer func(old string) { config.HistoryPath = old }(config.HistoryPath)
+	config.HistoryPath = t.TempDir()
+	digestChallenges = map[string]*digestChallenge{}
+	var mu sync.Mutex
+	var seen []string
+	challenge := &digestChallenge{realm: "device", nonce: "abc", algorithm: "SHA-256", qop: "auth"}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		h := r.Header.Get("Authorization")
+		seen = append(seen, h)
+		params := map[string]string{}
+		if l := parseChallenges(h); len(l) == 1 {
+			params = l[0]
+		}
+		c := *challenge
+		fmt.Sscanf(params["nc"], "%x", &c.nc)
+		if params["uri"] != r.URL.RequestURI() || h != c.authorization("admin", "pa55", r.Method, params["uri"], params["cnonce"]) {
+			w.Header().Set("WWW-Authenticate", `Digest realm="device", nonce="abc", algorithm=SHA-256, qop="auth"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, r.Method)
+	}))
+	defer ts.Close()
+	t.Setenv("TESTSPACE_DIGEST_PASSWORD", "pa55")
+	config.Requests.ReadConfig(bytes.NewBufferString(fmt.Sprintf(`
+testspace:
+  baseUrl: %v
+  auth:
+    authType: Digest
+    username: admin
+    password: $TESTSPACE_DIGEST_PASSWORD
+  requests:
+    status: /status?verbose=1
+    reboot:
+      url: /reboot
+      method: POST
+      body: '{"now": true}'
+`, ts.URL)))
+	rn := &Runner{Base: mockRSet(""), Quiet: true}
+	for _, r := range rn.Run([]string{"testspace.status", "testspace.reboot"}) {
+		if r.Err != nil || r.Status != 200 {
+			t.Errorf("%s: got %v %v - want 200", r.Name, r.Status, r.Err)
+		}
+	}
+	// only the first request gets a 401, the next answers the challenge it got
+	if len(seen) != 3 || seen[0] != "" || !strings.Contains(seen[1], "nc=00000001") || !strings.Contains(seen[2], "nc=00000002") {
+		t.Errorf("got %q - want 3 requests answering the challenge once got", seen)
+	}
+
+	// a wrong password gets the 401
+	t.Setenv("TESTSPACE_DIGEST_PASSWORD", "nope")
+	if r := rn.Run([]string{"testspace.status"})[0]; r.Status != 401 {
+		t.Errorf("with a wrong password: got %v %v - want 401", r.Status, r.Err)
+	}
+}
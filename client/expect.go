@@ -0,0 +1,295 @@
+package client
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Expect holds assertions on the response of a saved request, checked by 'brang test'.
+//
+//	expect:
+//	  status: 200 # or a list: [200, 201]
+//	  headers:
+//	    Content-Type: application/json # regex the header needs to match
+//	  json:
+//	    - path: $.data.id
+//	      equals: 27
+//	    - path: $.data.email
+//	      matches: ^.+@.+$
+//	    - path: $.data.deletedAt
+//	      exists: false
+//	  maxLatency: 500ms
+type Expect struct {
+	Status     []int             `yaml:"status,omitempty"`
+	Headers    map[string]string `yaml:"headers,omitempty"`
+	JSON       []JSONExpect      `yaml:"json,omitempty"`
+	MaxLatency string            `yaml:"maxLatency,omitempty"`
+}
+
+// JSONExpect is an assertion on the value at a json path of the body
+type JSONExpect struct {
+	Path    string      `yaml:"path"`
+	Equals  interface{} `yaml:"equals,omitempty"`
+	Exists  *bool       `yaml:"exists,omitempty"`
+	Matches string      `yaml:"matches,omitempty"`
+}
+
+// Assertion is the outcome of checking one assertion
+type Assertion struct {
+	Name   string `json:"name"`
+	Passed bool   `json:"passed"`
+	// what was found, such as: got 404
+	Msg string `json:"msg,omitempty"`
+}
+
+// TestResult is a sent saved request and its checked assertions
+type TestResult struct {
+	*RunResult
+	Assertions []Assertion
+}
+
+// Passed is true when every assertion passed
+func (tr *TestResult) Passed() bool {
+	for _, a := range tr.Assertions {
+		if !a.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckExpect checks the result against the expect: of its saved request.
+// Without an expect: status, the status is expected to not be 4xx|5xx.
+func CheckExpect(res *RunResult) *TestResult {
+	tr := &TestResult{RunResult: res}
+	if res.Err != nil {
+		tr.Assertions = append(tr.Assertions, Assertion{Name: "request is sent", Msg: res.Err.Error()})
+		return tr
+	}
+	e := res.Expect
+	if e == nil {
+		e = &Expect{}
+	}
+	tr.Assertions = append(tr.Assertions, e.checkStatus(res.Status))
+	for _, k := range sortedKeys(e.Headers) {
+		tr.Assertions = append(tr.Assertions, checkHeader(res.Response, k, e.Headers[k]))
+	}
+	if len(e.JSON) > 0 {
+		body, err := decodeJSON(res.Response.OutBody.Bytes())
+		for _, je := range e.JSON {
+			if err != nil {
+				tr.Assertions = append(tr.Assertions, Assertion{Name: je.name(), Msg: err.Error()})
+				continue
+			}
+			tr.Assertions = append(tr.Assertions, je.check(body))
+		}
+	}
+	if e.MaxLatency != "" {
+		tr.Assertions = append(tr.Assertions, checkLatency(res.Latency, e.MaxLatency))
+	}
+	return tr
+}
+
+func (e *Expect) checkStatus(got int) Assertion {
+	if len(e.Status) == 0 {
+		return Assertion{Name: "status is not 4xx|5xx", Passed: got < 400, Msg: fmt.Sprintf("got %d", got)}
+	}
+	var want []string
+	for _, s := range e.Status {
+		if s == got {
+			return Assertion{Name: "status is " + fmt.Sprint(s), Passed: true}
+		}
+		want = append(want, fmt.Sprint(s))
+	}
+	return Assertion{Name: "status is " + strings.Join(want, "|"), Msg: fmt.Sprintf("got %d", got)}
+}
+
+func checkHeader(br *BResponse, k, pattern string) Assertion {
+	a := Assertion{Name: fmt.Sprintf("header %s matches %s", k, pattern)}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		a.Msg = err.Error()
+		return a
+	}
+	vals := br.Header.Values(k)
+	if len(vals) == 0 {
+		a.Msg = "no such header"
+		return a
+	}
+	got := strings.Join(vals, ", ")
+	a.Passed = re.MatchString(got)
+	a.Msg = "got " + got
+	return a
+}
+
+func (je JSONExpect) name() string {
+	switch {
+	case je.Exists != nil && *je.Exists:
+		return je.Path + " exists"
+	case je.Exists != nil:
+		return je.Path + " doesn't exist"
+	case je.Matches != "":
+		return fmt.Sprintf("%s matches %s", je.Path, je.Matches)
+	default:
+		return fmt.Sprintf("%s equals %s", je.Path, jsonString(je.Equals))
+	}
+}
+
+func (je JSONExpect) check(body interface{}) Assertion {
+	a := Assertion{Name: je.name()}
+	jp, err := parseJSONPath(je.Path)
+	if err != nil {
+		a.Msg = err.Error()
+		return a
+	}
+	found := jp.Find(body)
+	if je.Exists != nil {
+		a.Passed = (len(found) > 0) == *je.Exists
+		a.Msg = fmt.Sprintf("found %d values", len(found))
+		return a
+	}
+	if len(found) == 0 {
+		a.Msg = "nothing found"
+		return a
+	}
+	got := jsonString(found[0])
+	a.Msg = "got " + got
+	if je.Matches != "" {
+		re, err := regexp.Compile(je.Matches)
+		if err != nil {
+			a.Msg = err.Error()
+			return a
+		}
+		a.Passed = re.MatchString(got)
+		return a
+	}
+	a.Passed = got == jsonString(je.Equals)
+	return a
+}
+
+func checkLatency(got time.Duration, max string) Assertion {
+	a := Assertion{Name: "latency is at most " + max}
+	d, err := time.ParseDuration(max)
+	if err != nil {
+		a.Msg = err.Error()
+		return a
+	}
+	a.Passed = got <= d
+	a.Msg = "got " + got.Round(time.Millisecond).String()
+	return a
+}
+
+// WriteTestResults writes pass|fail of each assertion, and how many failed
+func WriteTestResults(w io.Writer, results []*TestResult) {
+	failed, count := 0, 0
+	for _, tr := range results {
+		status := "ok  "
+		if !tr.Passed() {
+			status = "FAIL"
+		}
+		fmt.Fprintf(w, "%s %s (%s %d %v)\n", status, tr.Name, tr.Method, tr.Status, tr.Latency.Round(time.Millisecond))
+		for _, a := range tr.Assertions {
+			count++
+			if a.Passed {
+				fmt.Fprintf(w, "    pass: %s\n", a.Name)
+				continue
+			}
+			failed++
+			fmt.Fprintf(w, "    FAIL: %s - %s\n", a.Name, a.Msg)
+		}
+	}
+	fmt.Fprintf(w, "%d requests, %d assertions, %d failed\n", len(results), count, failed)
+}
+
+type jsonTestResult struct {
+	Name       string      `json:"name"`
+	Method     string      `json:"method"`
+	Status     int         `json:"status"`
+	LatencyMs  int64       `json:"latencyMs"`
+	Size       int         `json:"size"`
+	Passed     bool        `json:"passed"`
+	Error      string      `json:"error,omitempty"`
+	Assertions []Assertion `json:"assertions"`
+}
+
+// WriteTestJSON writes the results as a json report
+func WriteTestJSON(w io.Writer, results []*TestResult) error {
+	out := make([]jsonTestResult, 0, len(results))
+	for _, tr := range results {
+		jr := jsonTestResult{
+			Name: tr.Name, Method: tr.Method, Status: tr.Status, Size: tr.Size,
+			LatencyMs: tr.Latency.Milliseconds(), Passed: tr.Passed(), Assertions: tr.Assertions,
+		}
+		if tr.Err != nil {
+			jr.Error = tr.Err.Error()
+		}
+		out = append(out, jr)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the results as a JUnit xml report, with a testsuite per saved
+// request and a testcase per assertion, for CI tools to read.
+func WriteJUnit(w io.Writer, results []*TestResult) error {
+	var s junitSuites
+	for _, tr := range results {
+		secs := fmt.Sprintf("%.3f", tr.Latency.Seconds())
+		suite := junitSuite{Name: tr.Name, Time: secs}
+		for _, a := range tr.Assertions {
+			c := junitCase{Name: a.Name, Classname: tr.Name, Time: secs}
+			if !a.Passed {
+				c.Failure = &junitFailure{Message: a.Msg, Text: fmt.Sprintf("%s: %s", a.Name, a.Msg)}
+				suite.Failures++
+			}
+			suite.Cases = append(suite.Cases, c)
+		}
+		suite.Tests = len(suite.Cases)
+		s.Tests += suite.Tests
+		s.Failures += suite.Failures
+		s.Suites = append(s.Suites, suite)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(s); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,80 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func mockRunResult(status int, body string, e *Expect) *RunResult {
+	br := NewBResponse()
+	br.Response = &http.Response{StatusCode: status, Header: http.Header{"Content-Type": []string{"application/json"}}}
+	br.Write([]byte(body))
+	return &RunResult{Name: "testspace.users", Method: "GET", Status: status, Latency: 20 * time.Millisecond, Response: br, Expect: e}
+}
+
+func TestCheckExpect(t *testing.T) {
+	yes, no := true, false
+	body := `{"data": {"id": 27, "email": "joe@mysite.com"}}`
+	tests := map[string]struct {
+		in   *RunResult
+		want string
+	}{
+		"no expect":        {in: mockRunResult(200, body, nil), want: "true"},
+		"no expect 404":    {in: mockRunResult(404, body, nil), want: "false"},
+		"status list":      {in: mockRunResult(201, body, &Expect{Status: []int{200, 201}}), want: "true"},
+		"wrong status":     {in: mockRunResult(500, body, &Expect{Status: []int{200}}), want: "false"},
+		"header":           {in: mockRunResult(200, body, &Expect{Headers: map[string]string{"content-type": "^application/json$"}}), want: "true|true"},
+		"missing header":   {in: mockRunResult(200, body, &Expect{Headers: map[string]string{"X-Nope": "."}}), want: "true|false"},
+		"json equals":      {in: mockRunResult(200, body, &Expect{JSON: []JSONExpect{{Path: "$.data.id", Equals: 27}, {Path: "$.data.id", Equals: 28}}}), want: "true|true|false"},
+		"json exists":      {in: mockRunResult(200, body, &Expect{JSON: []JSONExpect{{Path: "$.data.email", Exists: &yes}, {Path: "$.data.nope", Exists: &no}}}), want: "true|true|true"},
+		"json matches":     {in: mockRunResult(200, body, &Expect{JSON: []JSONExpect{{Path: "$.data.email", Matches: "@mysite.com$"}}}), want: "true|true"},
+		"not json":         {in: mockRunResult(200, "<html>", &Expect{JSON: []JSONExpect{{Path: "$.data.id", Exists: &yes}}}), want: "true|false"},
+		"latency":          {in: mockRunResult(200, body, &Expect{MaxLatency: "100ms"}), want: "true|true"},
+		"latency too slow": {in: mockRunResult(200, body, &Expect{MaxLatency: "10ms"}), want: "true|false"},
+		"not sent":         {in: &RunResult{Err: fmt.Errorf("connection refused")}, want: "false"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			tr := CheckExpect(tc.in)
+			var got []string
+			for _, a := range tr.Assertions {
+				got = append(got, fmt.Sprint(a.Passed))
+			}
+			if strings.Join(got, "|") != tc.want {
+				t.Errorf("got %v - want %v: %+v", strings.Join(got, "|"), tc.want, tr.Assertions)
+			}
+		})
+	}
+}
+
+func TestWriteReports(t *testing.T) {
+	results := []*TestResult{
+		CheckExpect(mockRunResult(200, "{}", nil)),
+		CheckExpect(mockRunResult(500, "{}", &Expect{Status: []int{200}})),
+	}
+	var b bytes.Buffer
+	WriteTestResults(&b, results)
+	if !strings.Contains(b.String(), "2 requests, 2 assertions, 1 failed") {
+		t.Errorf("results: got %v", b.String())
+	}
+	b.Reset()
+	if err := WriteJUnit(&b, results); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`<testsuites tests="2" failures="1">`, `<failure message="got 500">`} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("junit missing %v: got %v", want, b.String())
+		}
+	}
+	b.Reset()
+	if err := WriteTestJSON(&b, results); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), `"passed": false`) {
+		t.Errorf("json: got %v", b.String())
+	}
+}
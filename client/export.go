@@ -0,0 +1,289 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ExportTargets are the forms a request can be exported to
+var ExportTargets = []string{"curl", "httpie", "go", "python", "javascript"}
+
+const redacted = "REDACTED"
+
+// headers whose values are always creds
+var secretHeaders = map[string]bool{
+	"Authorization": true, "Proxy-Authorization": true, "Cookie": true,
+}
+
+// parts of header and query param names that hold creds, such as X-Api-Key or access_token
+var secretNameParts = []string{"token", "secret", "password", "apikey", "api-key", "api_key", "session"}
+
+// Export writes req as code or a command line of target, one of ExportTargets, so it can
+// be sent again with other tools. Creds are redacted unless withSecrets.
+func Export(target string, req *http.Request, withSecrets bool) (string, error) {
+	er, err := newExportRequest(req, withSecrets)
+	if err != nil {
+		return "", err
+	}
+	switch target {
+	case "curl":
+		return er.curl(), nil
+	case "httpie":
+		return er.httpie(), nil
+	case "go":
+		return er.goCode(), nil
+	case "python":
+		return er.python(), nil
+	case "javascript", "js":
+		return er.javascript(), nil
+	}
+	return "", fmt.Errorf("can't export to %s. choices: %s", target, strings.Join(ExportTargets, "|"))
+}
+
+// exportRequest is the parts of a request to export, redacted as needed
+type exportRequest struct {
+	method, url, body string
+	// header keys sorted, with their values
+	keys   []string
+	header http.Header
+}
+
+func newExportRequest(req *http.Request, withSecrets bool) (*exportRequest, error) {
+	er := &exportRequest{method: req.Method, header: req.Header.Clone()}
+	if er.method == "" {
+		er.method = http.MethodGet
+	}
+	if req.Body != nil && req.Body != http.NoBody {
+		b, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, fmt.Errorf("err reading request body: %w", err)
+		}
+		req.Body = io.NopCloser(bytes.NewReader(b))
+		er.body = string(b)
+	}
+	u := *req.URL
+	if !withSecrets {
+		redactURL(&u)
+		er.body = redactBody(req.Header.Get("Content-Type"), er.body)
+		for k, vals := range er.header {
+			if isSecretName(k) {
+				for i, v := range vals {
+					vals[i] = redactValue(k, v)
+				}
+			}
+		}
+	}
+	er.url = u.String()
+	for k := range er.header {
+		er.keys = append(er.keys, k)
+	}
+	sort.Strings(er.keys)
+	return er, nil
+}
+
+func isSecretName(name string) bool {
+	if secretHeaders[http.CanonicalHeaderKey(name)] {
+		return true
+	}
+	n := strings.ToLower(name)
+	for _, p := range secretNameParts {
+		if strings.Contains(n, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Keeps the scheme of an Authorization value, like Bearer REDACTED
+func redactValue(key, v string) string {
+	if strings.HasSuffix(http.CanonicalHeaderKey(key), "Authorization") {
+		if scheme, _, ok := strings.Cut(v, " "); ok {
+			return scheme + " " + redacted
+		}
+	}
+	return redacted
+}
+
+// Redacts the values of fields named like creds, such as password or client_secret, of a form
+// or json body. Other bodies are kept as they are.
+func redactBody(contentType, body string) string {
+	if strings.Contains(contentType, "x-www-form-urlencoded") {
+		pairs := strings.Split(body, "&")
+		for i, p := range pairs {
+			k, _, _ := strings.Cut(p, "=")
+			if name, err := url.QueryUnescape(k); err == nil && isSecretName(name) {
+				pairs[i] = k + "=" + redacted
+			}
+		}
+		return strings.Join(pairs, "&")
+	}
+	dec := json.NewDecoder(strings.NewReader(body))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil || dec.More() || !redactSecretFields(v) {
+		return body
+	}
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.Encode(v)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// Redacts the values of the fields of v named like creds, at any depth. Tells if any were.
+func redactSecretFields(v interface{}) bool {
+	changed := false
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, val := range t {
+			if _, nested := val.(map[string]interface{}); isSecretName(k) && !nested {
+				t[k] = redacted
+				changed = true
+			} else if redactSecretFields(val) {
+				changed = true
+			}
+		}
+	case []interface{}:
+		for _, val := range t {
+			if redactSecretFields(val) {
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+func redactURL(u *url.URL) {
+	if u.User != nil {
+		u.User = url.User(redacted)
+	}
+	q := u.Query()
+	changed := false
+	for k := range q {
+		if isSecretName(k) {
+			q.Set(k, redacted)
+			changed = true
+		}
+	}
+	if changed {
+		u.RawQuery = q.Encode()
+	}
+}
+
+// Quotes s for a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// Quotes s as a json string, which is also a valid string in python and javascript
+func jsonQuote(s string) string {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func (er *exportRequest) curl() string {
+	var b strings.Builder
+	b.WriteString("curl")
+	switch er.method {
+	case http.MethodGet:
+	case http.MethodHead:
+		b.WriteString(" --head")
+	default:
+		b.WriteString(" -X " + er.method)
+	}
+	b.WriteString(" " + shellQuote(er.url))
+	for _, k := range er.keys {
+		for _, v := range er.header[k] {
+			b.WriteString(" \\\n  -H " + shellQuote(k+": "+v))
+		}
+	}
+	if er.body != "" {
+		b.WriteString(" \\\n  --data-raw " + shellQuote(er.body))
+	}
+	return b.String() + "\n"
+}
+
+func (er *exportRequest) httpie() string {
+	var b strings.Builder
+	b.WriteString("http")
+	if er.body != "" {
+		b.WriteString(" --raw " + shellQuote(er.body))
+	}
+	b.WriteString(" " + er.method + " " + shellQuote(er.url))
+	for _, k := range er.keys {
+		for _, v := range er.header[k] {
+			b.WriteString(" \\\n  " + shellQuote(k+":"+v))
+		}
+	}
+	return b.String() + "\n"
+}
+
+func (er *exportRequest) goCode() string {
+	var b strings.Builder
+	b.WriteString("package main\n\nimport (\n\t\"fmt\"\n\t\"io\"\n\t\"net/http\"\n")
+	body := "nil"
+	if er.body != "" {
+		b.WriteString("\t\"strings\"\n")
+		body = "strings.NewReader(" + strconv.Quote(er.body) + ")"
+	}
+	b.WriteString(")\n\nfunc main() {\n")
+	fmt.Fprintf(&b, "\treq, err := http.NewRequest(%s, %s, %s)\n", strconv.Quote(er.method), strconv.Quote(er.url), body)
+	b.WriteString("\tif err != nil {\n\t\tpanic(err)\n\t}\n")
+	for _, k := range er.keys {
+		for _, v := range er.header[k] {
+			fmt.Fprintf(&b, "\treq.Header.Add(%s, %s)\n", strconv.Quote(k), strconv.Quote(v))
+		}
+	}
+	b.WriteString("\tresp, err := http.DefaultClient.Do(req)\n\tif err != nil {\n\t\tpanic(err)\n\t}\n")
+	b.WriteString("\tdefer resp.Body.Close()\n\tb, err := io.ReadAll(resp.Body)\n\tif err != nil {\n\t\tpanic(err)\n\t}\n")
+	b.WriteString("\tfmt.Println(resp.Status)\n\tfmt.Println(string(b))\n}\n")
+	return b.String()
+}
+
+func (er *exportRequest) python() string {
+	var b strings.Builder
+	b.WriteString("import requests\n\n")
+	args := ""
+	if len(er.keys) > 0 {
+		b.WriteString("headers = {\n")
+		for _, k := range er.keys {
+			fmt.Fprintf(&b, "    %s: %s,\n", jsonQuote(k), jsonQuote(strings.Join(er.header[k], ", ")))
+		}
+		b.WriteString("}\n")
+		args += ", headers=headers"
+	}
+	if er.body != "" {
+		fmt.Fprintf(&b, "data = %s\n", jsonQuote(er.body))
+		args += ", data=data.encode()"
+	}
+	fmt.Fprintf(&b, "\nresponse = requests.request(%s, %s%s)\n", jsonQuote(er.method), jsonQuote(er.url), args)
+	b.WriteString("print(response.status_code)\nprint(response.text)\n")
+	return b.String()
+}
+
+func (er *exportRequest) javascript() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "const response = await fetch(%s, {\n  method: %s,\n", jsonQuote(er.url), jsonQuote(er.method))
+	if len(er.keys) > 0 {
+		b.WriteString("  headers: {\n")
+		for _, k := range er.keys {
+			fmt.Fprintf(&b, "    %s: %s,\n", jsonQuote(k), jsonQuote(strings.Join(er.header[k], ", ")))
+		}
+		b.WriteString("  },\n")
+	}
+	if er.body != "" {
+		fmt.Fprintf(&b, "  body: %s,\n", jsonQuote(er.body))
+	}
+	b.WriteString("});\nconsole.log(response.status);\nconsole.log(await response.text());\n")
+	return b.String()
+}
@@ -0,0 +1,118 @@
+package client
+
+import (
+	"go/parser"
+	"go/token"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func newExportTestRequest() *http.Request {
+	req, _ := http.NewRequest("POST", "https://joe:pw@mysite.com/orders?api_key=k1&page=2", strings.NewReader(`{"note": "it's"}`))
+	req.Header.Set("Authorization", "Bearer abc-123")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Auth-Token", "t1")
+	return req
+}
+
+func TestExport(t *testing.T) {
+	tests := map[string]struct {
+		target  string
+		secrets bool
+		want    []string
+	}{
+		"curl": {target: "curl", want: []string{
+			"curl -X POST 'https://REDACTED@mysite.com/orders?api_key=REDACTED&page=2'",
+			"-H 'Authorization: Bearer REDACTED'", "-H 'X-Auth-Token: REDACTED'", `--data-raw '{"note": "it'\''s"}'`,
+		}},
+		"curl with secrets": {target: "curl", secrets: true, want: []string{
+			"https://joe:pw@mysite.com/orders?api_key=k1&page=2", "-H 'Authorization: Bearer abc-123'",
+		}},
+		"httpie": {target: "httpie", want: []string{
+			`http --raw '{"note": "it'\''s"}' POST`, "'Authorization:Bearer REDACTED'",
+		}},
+		"go": {target: "go", want: []string{
+			`http.NewRequest("POST", "https://REDACTED@mysite.com/orders?api_key=REDACTED&page=2", strings.NewReader("{\"note\": \"it's\"}"))`,
+			`req.Header.Add("Authorization", "Bearer REDACTED")`,
+		}},
+		"python": {target: "python", want: []string{
+			`"Authorization": "Bearer REDACTED",`, `data = "{\"note\": \"it's\"}"`, `requests.request("POST", `,
+		}},
+		"javascript": {target: "javascript", want: []string{
+			`await fetch("https://REDACTED@mysite.com/orders?api_key=REDACTED&page=2"`, `method: "POST"`, `"X-Auth-Token": "REDACTED"`,
+		}},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := Export(tc.target, newExportTestRequest(), tc.secrets)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, w := range tc.want {
+				if !strings.Contains(got, w) {
+					t.Errorf("missing %s in:\n%s", w, got)
+				}
+			}
+			if !tc.secrets && (strings.Contains(got, "abc-123") || strings.Contains(got, "k1") || strings.Contains(got, "pw")) {
+				t.Errorf("cred not redacted in:\n%s", got)
+			}
+		})
+	}
+	if _, err := Export("powershell", newExportTestRequest(), false); err == nil {
+		t.Error("should err for an unknown target")
+	}
+}
+
+func TestExportBody(t *testing.T) {
+	tests := map[string]struct {
+		contentType, body string
+		secrets           bool
+		want              string
+	}{
+		"json":              {contentType: "application/json", body: `{"user": "joe", "password": "pw", "client": {"client_secret": "s1", "tags": ["<a>"]}}`, want: `{"client":{"client_secret":"REDACTED","tags":["<a>"]},"password":"REDACTED","user":"joe"}`},
+		"json in an array":  {contentType: "application/json", body: `[{"token": "t1", "n": 1.50}]`, want: `[{"n":1.50,"token":"REDACTED"}]`},
+		"json no secrets":   {contentType: "application/json", body: `{"note": "it's"}`, want: `{"note": "it's"}`},
+		"json with secrets": {contentType: "application/json", body: `{"password": "pw"}`, secrets: true, want: `{"password": "pw"}`},
+		"form":              {contentType: "application/x-www-form-urlencoded", body: "user=joe&password=pw&api%5Fkey=k1", want: "user=joe&password=REDACTED&api%5Fkey=REDACTED"},
+		"form with secrets": {contentType: "application/x-www-form-urlencoded", body: "user=joe&password=pw", secrets: true, want: "user=joe&password=pw"},
+		"text":              {contentType: "text/plain", body: "password=pw", want: "password=pw"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			req, _ := http.NewRequest("POST", "https://mysite.com/login", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", tc.contentType)
+			er, err := newExportRequest(req, tc.secrets)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if er.body != tc.want {
+				t.Errorf("body: got %v - want %v", er.body, tc.want)
+			}
+		})
+	}
+}
+
+func TestExportGoParses(t *testing.T) {
+	got, _ := Export("go", newExportTestRequest(), false)
+	if _, err := parser.ParseFile(token.NewFileSet(), "main.go", got, 0); err != nil {
+		t.Errorf("%v in:\n%s", err, got)
+	}
+}
+
+func TestExportCurlRoundTrip(t *testing.T) {
+	req := newExportTestRequest()
+	out, err := Export("curl", req, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ci, err := ParseCurl(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(req.Body)
+	if ci.Method != "POST" || ci.URL != req.URL.String() || ci.Body != string(body) || len(ci.HeaderSlice) != 3 {
+		t.Errorf("got %s %s %s %v - want the same request back", ci.Method, ci.URL, ci.Body, ci.HeaderSlice)
+	}
+}
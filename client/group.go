@@ -0,0 +1,149 @@
+package client
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jerempy/brang/config"
+)
+
+// Group holds the settings of a group in requests.yaml, shared by all of its requests
+type Group struct {
+	Name         string
+	BaseURL      string `yaml:"baseUrl,omitempty"`
+	Auth         Auth   `yaml:"auth,omitempty"`
+	Vars         Vars   `yaml:"vars,omitempty"`
+	Environments map[string]Environment
+	// overrides transport: of config.yaml for the group's requests
+	Transport Transport `yaml:"transport,omitempty"`
+	// session the group's requests keep cookies and sticky headers in
+	Session GroupSession `yaml:"session,omitempty"`
+	// what of the group's responses 'brang snapshot' records
+	Snapshot SnapshotSettings `yaml:"snapshot,omitempty"`
+}
+
+// Environment overrides the baseUrl, vars and auth of its group, such as for dev|staging|prod.
+// Selected with --env or env: in config.yaml
+type Environment struct {
+	BaseURL string `yaml:"baseUrl,omitempty"`
+	Auth    Auth   `yaml:"auth,omitempty"`
+	Vars    Vars   `yaml:"vars,omitempty"`
+}
+
+// Reads the settings of group from requests.yaml
+func loadGroup(name string) (*Group, error) {
+	g := &Group{}
+	if n := config.Requests.Lookup(name); n != nil {
+		if err := decodeNode(n, g); err != nil {
+			return nil, err
+		}
+	}
+	g.Name = name
+	if g.Vars == nil {
+		g.Vars = Vars{}
+	}
+	return g, nil
+}
+
+// ActiveEnv returns the name of the environment to use for group and where it was set.
+// env is from the --env flag. When empty the default env: in config.yaml is used if the group has it.
+func ActiveEnv(group, env string) (name, source string, err error) {
+	if err := config.LoadRequests(); err != nil {
+		return "", "", err
+	}
+	g, err := loadGroup(group)
+	if err != nil {
+		return "", "", err
+	}
+	name, err = g.envName(env)
+	switch {
+	case name == "":
+	case env != "":
+		source = "--env"
+	default:
+		source = "env: in config.yaml"
+	}
+	return name, source, err
+}
+
+// Returns the name of the environment to use: env when given, else the default
+// env: in config.yaml if the group has it. Errors when env isn't one of the group's.
+func (g *Group) envName(env string) (string, error) {
+	if env == "" {
+		env = config.Brang.GetString("env")
+		if _, ok := g.Environments[env]; !ok {
+			return "", nil
+		}
+		return env, nil
+	}
+	if _, ok := g.Environments[env]; !ok {
+		return "", fmt.Errorf("environment %s not found for %s. it has: %s", env, g.Name, g.EnvNames())
+	}
+	return env, nil
+}
+
+// EnvNames lists the group's environments in a string
+func (g *Group) EnvNames() string {
+	if len(g.Environments) == 0 {
+		return "no environments"
+	}
+	names := make([]string, 0, len(g.Environments))
+	for k := range g.Environments {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// Applies the environment on top of the group's settings. Set values of the
+// environment win, auth is overridden key by key.
+func (g *Group) useEnv(name string) {
+	env, ok := g.Environments[name]
+	if !ok {
+		return
+	}
+	if env.BaseURL != "" {
+		g.BaseURL = env.BaseURL
+	}
+	for k, v := range env.Vars {
+		g.Vars[k] = v
+	}
+	for _, f := range []struct{ to, from *string }{
+		{&g.Auth.AuthType, &env.Auth.AuthType},
+		{&g.Auth.Token, &env.Auth.Token},
+		{&g.Auth.Username, &env.Auth.Username},
+		{&g.Auth.Password, &env.Auth.Password},
+		{&g.Auth.Grant, &env.Auth.Grant},
+		{&g.Auth.TokenURL, &env.Auth.TokenURL},
+		{&g.Auth.AuthURL, &env.Auth.AuthURL},
+		{&g.Auth.DeviceURL, &env.Auth.DeviceURL},
+		{&g.Auth.RedirectURL, &env.Auth.RedirectURL},
+		{&g.Auth.ClientID, &env.Auth.ClientID},
+		{&g.Auth.ClientSecret, &env.Auth.ClientSecret},
+		{&g.Auth.Scopes, &env.Auth.Scopes},
+		{&g.Auth.Audience, &env.Auth.Audience},
+		{&g.Auth.RefreshToken, &env.Auth.RefreshToken},
+		{&g.Auth.AccessKey, &env.Auth.AccessKey},
+		{&g.Auth.SecretKey, &env.Auth.SecretKey},
+		{&g.Auth.SessionToken, &env.Auth.SessionToken},
+		{&g.Auth.Region, &env.Auth.Region},
+		{&g.Auth.Service, &env.Auth.Service},
+	} {
+		if *f.from != "" {
+			*f.to = *f.from
+		}
+	}
+}
+
+// Joins a url starting with / to the baseUrl of the group or its environment
+func (g *Group) resolveURL(u string) (string, error) {
+	if !strings.HasPrefix(u, "/") {
+		return u, nil
+	}
+	if g.BaseURL == "" {
+		return "", fmt.Errorf("url %s is relative but %s has no baseUrl. set baseUrl: on the group or its environments (%s) and select one with --env",
+			u, g.Name, g.EnvNames())
+	}
+	return strings.TrimRight(g.BaseURL, "/") + u, nil
+}
@@ -0,0 +1,75 @@
+package client
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/jerempy/brang/config"
+)
+
+var mockEnvYml = []byte(`
+testspace:
+  baseUrl: https://mysite.com
+  auth:
+    authType: Bearer
+    token: group-token
+  vars:
+    userId: 1
+  environments:
+    staging:
+      baseUrl: https://staging.mysite.com/
+      auth:
+        token: staging-token
+      vars:
+        userId: 2
+  requests:
+    user: /users/{{userId}}
+    full: https://other.com/users/{{userId}}
+
+testspace2:
+  requests:
+    user: /users/1
+`)
+
+func TestLoadEnvironments(t *testing.T) {
+	config.Requests.ReadConfig(bytes.NewBuffer(mockEnvYml))
+	tests := map[string]struct {
+		name, env   string
+		defaultEnv  string
+		want, token string
+		wantEnv     string
+		wantErr     bool
+	}{
+		"group":          {name: "testspace.user", want: "https://mysite.com/users/1", token: "Bearer group-token"},
+		"staging":        {name: "testspace.user", env: "staging", want: "https://staging.mysite.com/users/2", token: "Bearer staging-token", wantEnv: "staging"},
+		"config default": {name: "testspace.user", defaultEnv: "staging", want: "https://staging.mysite.com/users/2", token: "Bearer staging-token", wantEnv: "staging"},
+		"full url":       {name: "testspace.full", env: "staging", want: "https://other.com/users/2", token: "Bearer staging-token", wantEnv: "staging"},
+		"unknown env":    {name: "testspace.user", env: "prod", wantErr: true},
+		"no baseUrl":     {name: "testspace2.user", wantErr: true},
+		"default skips":  {name: "testspace2.user", defaultEnv: "staging", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			config.Brang.Set("env", tc.defaultEnv)
+			defer config.Brang.Set("env", "")
+			rset := mockRSet(tc.name)
+			rset.Env = tc.env
+			got, err := LoadSavedRequest(rset)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("got err %v - want err %v", err, tc.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.URL.String() != tc.want {
+				t.Errorf("url: got %v - want %v", got.URL, tc.want)
+			}
+			if a := got.Header.Get("Authorization"); a != tc.token {
+				t.Errorf("auth: got %v - want %v", a, tc.token)
+			}
+			if rset.Env != tc.wantEnv {
+				t.Errorf("env: got %v - want %v", rset.Env, tc.wantEnv)
+			}
+		})
+	}
+}
@@ -8,13 +8,32 @@ import (
 )
 
 // Auth holds the authentication data for building HTTP Auth Headers.
-// Allowed AuthTypes are: Password, Bearer, Token, or empty string
+// Allowed AuthTypes are: Password, Bearer, Token, Digest, or empty string. A group's auth: can also be OAuth2 or AWSSigV4
 // If AuthType is passed value of 'Basic' it will be handled as 'Password'
 type Auth struct {
 	AuthType string `yaml:"authType,omitempty" json:"authType,omitempty"`
 	Token    string `yaml:"token,omitempty" json:"token,omitempty"`
 	Username string `yaml:"username,omitempty" json:"username,omitempty"`
 	Password string `yaml:"password,omitempty" json:"password,omitempty"`
+	// for OAuth2: the token is got from TokenURL with the refresh_token grant when there
+	// is a RefreshToken, else with Grant: client_credentials by default, or authorization_code
+	// and device_code for the user to log in. Scopes are space separated
+	Grant        string `yaml:"grant,omitempty" json:"grant,omitempty"`
+	TokenURL     string `yaml:"tokenUrl,omitempty" json:"tokenUrl,omitempty"`
+	AuthURL      string `yaml:"authUrl,omitempty" json:"authUrl,omitempty"`
+	DeviceURL    string `yaml:"deviceUrl,omitempty" json:"deviceUrl,omitempty"`
+	RedirectURL  string `yaml:"redirectUrl,omitempty" json:"redirectUrl,omitempty"`
+	ClientID     string `yaml:"clientId,omitempty" json:"clientId,omitempty"`
+	ClientSecret string `yaml:"clientSecret,omitempty" json:"clientSecret,omitempty"`
+	Scopes       string `yaml:"scopes,omitempty" json:"scopes,omitempty"`
+	Audience     string `yaml:"audience,omitempty" json:"audience,omitempty"`
+	RefreshToken string `yaml:"refreshToken,omitempty" json:"refreshToken,omitempty"`
+	// for AWSSigV4: requests are signed with the keys, for the region and service such as execute-api or s3
+	AccessKey    string `yaml:"accessKey,omitempty" json:"accessKey,omitempty"`
+	SecretKey    string `yaml:"secretKey,omitempty" json:"secretKey,omitempty"`
+	SessionToken string `yaml:"sessionToken,omitempty" json:"sessionToken,omitempty"`
+	Region       string `yaml:"region,omitempty" json:"region,omitempty"`
+	Service      string `yaml:"service,omitempty" json:"service,omitempty"`
 }
 
 // Returns value for Authorization Header as "Basic username:pasword"
@@ -32,28 +51,35 @@ func (a *Auth) TokenAuth() string {
 func (rset *RequestSet) BuildHeader() (*http.Header, error) {
 	a, h := &Auth{}, &http.Header{}
 	a.AuthType = rset.AuthType
-	if rset.AuthType == "Basic" || rset.AuthType == "Password" {
-		s := strings.Split(rset.Cred, ":")
-		a.Username, a.Password = s[0], s[1]
+	if rset.AuthType == "Basic" || rset.AuthType == "Password" || rset.AuthType == "Digest" {
+		var ok bool
+		if a.Username, a.Password, ok = strings.Cut(rset.Cred, ":"); !ok {
+			return nil, fmt.Errorf("%s auth needs the cred as username:password", rset.AuthType)
+		}
 	} else {
 		a.Token = rset.Cred
 	}
 	if err := mapHeaderSliceToHeader(rset.HeaderSlice, h); err != nil {
 		return nil, err
 	}
-	addDefaultHeaders(h)
+	if allowsBody(rset.Method) {
+		addDefaultHeaders(h)
+	} else {
+		addDefaultAccept(h)
+	}
 	if h.Get("Authorization") != "" {
 		return h, nil
 	}
 	switch a.AuthType {
-	case "":
+	case "", "AWSSigV4", "Digest":
+		// AWSSigV4 signs the whole request once it is built, Digest answers the challenge of a 401
 		break
 	case "Bearer", "Token":
 		h.Set("Authorization", a.TokenAuth())
 	case "Password", "Basic":
 		h.Set("Authorization", a.PasswordAuth())
 	default:
-		return nil, fmt.Errorf("wrong auth type. accepts: Password|Bearer|Token. was given: %v", a.AuthType)
+		return nil, fmt.Errorf("wrong auth type. accepts: Password|Bearer|Token|Digest, or OAuth2|AWSSigV4 in the auth: of a group. was given: %v", a.AuthType)
 	}
 	return h, nil
 }
@@ -62,6 +88,10 @@ func addDefaultHeaders(h *http.Header) {
 	if h.Get("Content-Type") == "" {
 		h.Add("Content-Type", "application/json; charset=UTF-8")
 	}
+	addDefaultAccept(h)
+}
+
+func addDefaultAccept(h *http.Header) {
 	if h.Get("Accept") == "" {
 		h.Add("Accept", "text/html,text/plain,application/json")
 	}
@@ -70,11 +100,11 @@ func addDefaultHeaders(h *http.Header) {
 // Takes a []string of "key:value" from -H flag and adds them to *http.Header
 func mapHeaderSliceToHeader(slice []string, h *http.Header) error {
 	for _, v := range slice {
-		s := strings.Split(v, ":")
-		if len(s) != 2 {
+		k, val, ok := strings.Cut(v, ":")
+		if !ok || strings.TrimSpace(k) == "" {
 			return fmt.Errorf("headers need to be <key>:<value> - ex: Content-Type:application/json")
 		}
-		h.Add(s[0], s[1])
+		h.Add(strings.TrimSpace(k), strings.TrimSpace(val))
 	}
 	return nil
 }
@@ -56,16 +56,20 @@ func TestAuth(t *testing.T) {
 
 func TestMapHeaderSliceToHeader(t *testing.T) {
 	h := http.Header{}
-	s := []string{"key:value", "test:value", "foo:roo"}
+	s := []string{"key:value", "test:value", "foo:roo", "Origin: https://mysite.com"}
 	want := http.Header{
-		"Key":  []string{"value"},
-		"Test": []string{"value"},
-		"Foo":  []string{"roo"},
+		"Key":    []string{"value"},
+		"Test":   []string{"value"},
+		"Foo":    []string{"roo"},
+		"Origin": []string{"https://mysite.com"},
 	}
 	mapHeaderSliceToHeader(s, &h)
 	if !compareHeaders(h, want) {
 		t.Error("header didn't get mapped")
 	}
+	if err := mapHeaderSliceToHeader([]string{"novalue"}, &h); err == nil {
+		t.Error("should err on header without ':'")
+	}
 }
 
 func TestAddDefaultHeaders(t *testing.T) {
@@ -109,4 +113,14 @@ func TestRequestSetBuildHeader(t *testing.T) {
 	if !compareHeaders(*gotB, wantB) {
 		t.Errorf("err build header. got: %v - want: %v", gotB, wantB)
 	}
+	rsetB.Cred = "joey:sand:wiches"
+	if gotB, errB = rsetB.BuildHeader(); errB != nil || gotB.Get("Authorization") != "Basic "+base64.StdEncoding.EncodeToString([]byte("joey:sand:wiches")) {
+		t.Errorf("err build header with a password of ':'. got: %v %v", gotB, errB)
+	}
+	for _, authType := range []string{"Password", "Digest"} {
+		rsetB.AuthType, rsetB.Cred = authType, "joey"
+		if _, errB = rsetB.BuildHeader(); errB == nil {
+			t.Errorf("%s cred without ':' should err", authType)
+		}
+	}
 }
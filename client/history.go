@@ -0,0 +1,408 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jerempy/brang/config"
+)
+
+// HistorySettings are the history: settings of config.yaml
+//
+//	history:
+//	  disabled: false # don't record requests
+//	  maxEntries: 500 # the oldest are removed past this. default 500
+//	  maxAge: 720h # entries older than this are removed. default no limit
+//	  maxBodySize: 65536 # bytes kept of each request and response body. default 64KiB
+type HistorySettings struct {
+	Disabled    bool
+	MaxEntries  int
+	MaxAge      string
+	MaxBodySize int
+}
+
+const (
+	defaultHistoryEntries  = 500
+	defaultHistoryBodySize = 64 << 10
+)
+
+// Returns the history: settings of config.yaml, with defaults for those not set
+func configHistory() HistorySettings {
+	var h HistorySettings
+	if err := config.Brang.UnmarshalKey("history", &h); err != nil {
+		fmt.Printf("ignoring history: in config.yaml: %v\n", err)
+	}
+	if h.MaxEntries <= 0 {
+		h.MaxEntries = defaultHistoryEntries
+	}
+	if h.MaxBodySize <= 0 {
+		h.MaxBodySize = defaultHistoryBodySize
+	}
+	return h
+}
+
+// HistoryEntry is a request that was sent and its response. Creds in headers and
+// the url are redacted, and bodies are cut to history: maxBodySize of config.yaml.
+type HistoryEntry struct {
+	ID   int       `json:"id"`
+	Time time.Time `json:"time"`
+	// saved request that was sent, if any, and the environment of its group
+	Name   string      `json:"name,omitempty"`
+	Env    string      `json:"env,omitempty"`
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header"`
+	Body   string      `json:"body,omitempty"`
+	// the status code, 0 when no response came back
+	Status         int         `json:"status"`
+	ResponseHeader http.Header `json:"responseHeader,omitempty"`
+	ResponseBody   string      `json:"responseBody,omitempty"`
+	Timing         *jsonTiming `json:"timing,omitempty"`
+	Redirects      []Redirect  `json:"redirects,omitempty"`
+	Errors         []string    `json:"errors,omitempty"`
+	// the request body or response body was cut to history: maxBodySize
+	BodyTruncated         bool `json:"bodyTruncated,omitempty"`
+	ResponseBodyTruncated bool `json:"responseBodyTruncated,omitempty"`
+}
+
+// Returns the history file of the entry with id
+func historyFile(id int) string {
+	return filepath.Join(config.HistoryPath, strconv.Itoa(id)+".json")
+}
+
+// guards numbering and pruning history files, for requests sent at once by a Runner
+var historyMu sync.Mutex
+
+// Records req, as sent for the saved request name if any, and its response br in the history.
+// The body of br needs to be read first.
+func recordHistory(name string, req *http.Request, br *BResponse) error {
+	h := configHistory()
+	if h.Disabled {
+		return nil
+	}
+	e := newHistoryEntry(name, req, br, h.MaxBodySize)
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	if err := e.save(); err != nil {
+		return fmt.Errorf("err saving history: %w", err)
+	}
+	return pruneHistory(h)
+}
+
+func newHistoryEntry(name string, req *http.Request, br *BResponse, maxBody int) *HistoryEntry {
+	e := &HistoryEntry{
+		Time: time.Now(), Name: name, Env: br.Env, Method: req.Method,
+		Header: redactSecretHeaders(req.Header), Status: br.StatusCode, Redirects: br.Redirects,
+	}
+	u := *req.URL
+	redactURL(&u)
+	e.URL = u.String()
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			b, _ := io.ReadAll(body)
+			e.Body, e.BodyTruncated = cutBody(string(b), maxBody)
+		}
+	}
+	if br.Response != nil && br.Header != nil {
+		e.ResponseHeader = redactSecretHeaders(br.Header)
+	}
+	e.ResponseBody, e.ResponseBodyTruncated = cutBody(br.StringResponseBody(), maxBody)
+	if tm := br.Timing(); tm != nil {
+		e.Timing = &jsonTiming{
+			DNSMs: ms(tm.DNS), ConnectMs: ms(tm.Connect), TLSMs: ms(tm.TLS),
+			TTFBMs: ms(tm.TTFB), TransferMs: ms(tm.Transfer), TotalMs: ms(tm.Total), Reused: tm.Reused,
+		}
+	}
+	for _, err := range br.errs {
+		e.Errors = append(e.Errors, err.Error())
+	}
+	return e
+}
+
+// Returns a copy of h with the values of creds, cookies included, redacted
+func redactSecretHeaders(h http.Header) http.Header {
+	c := http.Header{}
+	for k, vs := range h {
+		secret := isSecretName(k) || http.CanonicalHeaderKey(k) == "Set-Cookie"
+		for _, v := range vs {
+			if secret {
+				v = redactValue(k, v)
+			}
+			c.Add(k, v)
+		}
+	}
+	return c
+}
+
+func cutBody(s string, max int) (string, bool) {
+	if len(s) <= max {
+		return s, false
+	}
+	return s[:max], true
+}
+
+// Writes the entry under the id after the last one, setting e.ID
+func (e *HistoryEntry) save() error {
+	if err := os.MkdirAll(config.HistoryPath, 0700); err != nil {
+		return err
+	}
+	ids, err := historyIDs()
+	if err != nil {
+		return err
+	}
+	e.ID = 1
+	if len(ids) > 0 {
+		e.ID = ids[len(ids)-1] + 1
+	}
+	for {
+		b, err := json.MarshalIndent(e, "", "  ")
+		if err != nil {
+			return err
+		}
+		// another brang may have taken the id, so the file is only made when it isn't there
+		f, err := os.OpenFile(historyFile(e.ID), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if errors.Is(err, fs.ErrExist) {
+			e.ID++
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(b)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		return err
+	}
+}
+
+// Returns the ids of the history entries, oldest first
+func historyIDs() ([]int, error) {
+	m, err := filepath.Glob(filepath.Join(config.HistoryPath, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var ids []int
+	for _, f := range m {
+		if id, err := strconv.Atoi(strings.TrimSuffix(filepath.Base(f), ".json")); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+// Removes the oldest entries past maxEntries, and those older than maxAge
+func pruneHistory(h HistorySettings) error {
+	maxAge, err := parseTimeout("history maxAge", h.MaxAge, 0)
+	if err != nil {
+		return err
+	}
+	ids, err := historyIDs()
+	if err != nil {
+		return err
+	}
+	for i, id := range ids {
+		f := historyFile(id)
+		if i >= len(ids)-h.MaxEntries {
+			if maxAge == 0 {
+				break
+			}
+			// entries are written once, so the file's time is the entry's
+			if fi, err := os.Stat(f); err != nil || time.Since(fi.ModTime()) <= maxAge {
+				continue
+			}
+		}
+		if err := os.Remove(f); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadHistory reads the history entry with id
+func LoadHistory(id int) (*HistoryEntry, error) {
+	b, err := os.ReadFile(historyFile(id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("no history entry %d. type 'brang history list' to see them", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("err reading history entry %d: %w", id, err)
+	}
+	e := &HistoryEntry{}
+	if err := json.Unmarshal(b, e); err != nil {
+		return nil, fmt.Errorf("err reading history entry %d: %w", id, err)
+	}
+	return e, nil
+}
+
+// HistoryFilter picks history entries. Empty fields match all.
+type HistoryFilter struct {
+	// saved request name, as a glob of path.Match such as mysite.*
+	Name string
+	// status code such as 404, a class such as 4xx, or err for requests that got no response
+	Status string
+	// most entries to return. 0 for all
+	Limit int
+}
+
+// ListHistory returns the history entries matching f, newest first
+func ListHistory(f HistoryFilter) ([]*HistoryEntry, error) {
+	if f.Status != "" && !validStatusFilter(f.Status) {
+		return nil, fmt.Errorf("status needs to be a code like 404, a class like 4xx, or err. was given: %s", f.Status)
+	}
+	if _, err := path.Match(f.Name, ""); err != nil {
+		return nil, fmt.Errorf("bad name pattern %s: %w", f.Name, err)
+	}
+	ids, err := historyIDs()
+	if err != nil {
+		return nil, err
+	}
+	var entries []*HistoryEntry
+	for i := len(ids) - 1; i >= 0 && (f.Limit <= 0 || len(entries) < f.Limit); i-- {
+		e, err := LoadHistory(ids[i])
+		if err != nil {
+			return nil, err
+		}
+		if f.matches(e) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+func (f HistoryFilter) matches(e *HistoryEntry) bool {
+	if f.Name != "" {
+		if ok, _ := path.Match(f.Name, e.Name); !ok {
+			return false
+		}
+	}
+	switch s := strings.ToLower(f.Status); {
+	case s == "":
+		return true
+	case s == "err":
+		return e.Status == 0
+	case strings.HasSuffix(s, "xx"):
+		return e.Status/100 == int(s[0]-'0')
+	default:
+		return strconv.Itoa(e.Status) == s
+	}
+}
+
+func validStatusFilter(s string) bool {
+	s = strings.ToLower(s)
+	if s == "err" {
+		return true
+	}
+	if len(s) != 3 || s[0] < '1' || s[0] > '5' {
+		return false
+	}
+	if s[1:] == "xx" {
+		return true
+	}
+	_, err := strconv.Atoi(s)
+	return err == nil
+}
+
+// Replay sends the request of the history entry again. Redacted values of a saved request
+// are filled in from it as it is now. Those of other requests are left out, unless set with
+// headers or auth of rset.
+func (rset *RequestSet) Replay(e *HistoryEntry) {
+	req, err := rset.replayRequest(e)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	rset.send(req)
+}
+
+// Builds the request of the entry. Headers of rset are set on top, and its auth for a request
+// that wasn't a saved one. Saved requests of a group with AWSSigV4 auth are signed again.
+func (rset *RequestSet) replayRequest(e *HistoryEntry) (*http.Request, error) {
+	flags := http.Header{}
+	if err := mapHeaderSliceToHeader(rset.HeaderSlice, &flags); err != nil {
+		return nil, err
+	}
+	var saved *http.Request
+	if e.Name != "" {
+		rset.URL = e.Name
+		if rset.Env == "" {
+			rset.Env = e.Env
+		}
+		var err error
+		if saved, err = LoadSavedRequest(rset); err != nil {
+			return nil, fmt.Errorf("err loading %s to fill in its redacted values: %w", e.Name, err)
+		}
+	} else if rset.AuthType != "" {
+		h, err := rset.BuildHeader()
+		if err != nil {
+			return nil, fmt.Errorf("err building header: %w", err)
+		}
+		flags.Set("Authorization", h.Get("Authorization"))
+	}
+	u := e.URL
+	if strings.Contains(u, redacted) {
+		if saved == nil {
+			return nil, fmt.Errorf("the url of history entry %d has redacted creds, so it can't be sent again: %s", e.ID, u)
+		}
+		u = saved.URL.String()
+	}
+	if e.BodyTruncated {
+		return nil, fmt.Errorf("the body of history entry %d was cut to fit in history, so it can't be sent again", e.ID)
+	}
+	var body io.Reader = http.NoBody
+	if e.Body != "" {
+		body = bytes.NewBufferString(e.Body)
+	}
+	req, err := http.NewRequest(e.Method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, vs := range e.Header {
+		if !redactedValues(vs) {
+			req.Header[k] = vs
+			continue
+		}
+		switch {
+		case flags.Get(k) != "":
+		case saved != nil && saved.Header.Get(k) != "":
+			req.Header[k] = saved.Header[k]
+		default:
+			fmt.Printf("%s was redacted in history, so it isn't sent. set it with -H, or -a and -c\n", k)
+		}
+	}
+	for k, vs := range flags {
+		req.Header[k] = vs
+	}
+	// an AWSSigV4 signature is only good for the time it was made at, so the request is
+	// signed again, with the headers set above
+	if saved != nil && rset.sigv4 != nil {
+		for _, k := range []string{"X-Amz-Date", "X-Amz-Content-Sha256", "X-Amz-Security-Token"} {
+			req.Header.Del(k)
+		}
+		rset.sigv4.sign(req, []byte(e.Body), time.Now())
+	}
+	return req, nil
+}
+
+func redactedValues(vs []string) bool {
+	for _, v := range vs {
+		if strings.Contains(v, redacted) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,164 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jerempy/brang/config"
+)
+
+func TestRecordHistory(t *testing.T) {
+	defer func(old string) { config.HistoryPath = old }(config.HistoryPath)
+	config.HistoryPath = t.TempDir()
+	config.Brang.Set("history.maxEntries", 2)
+	config.Brang.Set("history.maxBodySize", 8)
+	defer config.Brang.Set("history", nil)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "sid=abc")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"id": 123456789}`)
+	}))
+	defer ts.Close()
+
+	for i := 0; i < 3; i++ {
+		rset := mockRSet(ts.URL + "/users?api_key=k-1")
+		rset.Method, rset.Body = "POST", `{"name": "joe"}`
+		req, err := rset.BuildRequest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		br := NewBResponse()
+		NewClient().DoRequest(req, &quietHandler{br})
+		if err := recordHistory("", req, br); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ids, _ := historyIDs(); !reflect.DeepEqual(ids, []int{2, 3}) {
+		t.Errorf("ids: got %v - want [2 3] with the oldest entry pruned", ids)
+	}
+	e, err := LoadHistory(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Method != "POST" || e.Status != 201 || e.URL != ts.URL+"/users?api_key="+redacted {
+		t.Errorf("got %+v - want a POST of 201 with the api_key redacted", e)
+	}
+	if e.Header.Get("Authorization") != "Bearer "+redacted || e.ResponseHeader.Get("Set-Cookie") != redacted {
+		t.Errorf("got %v %v - want creds redacted", e.Header, e.ResponseHeader)
+	}
+	if e.Body != `{"name":` || !e.BodyTruncated || e.ResponseBody != `{"id": 1` || !e.ResponseBodyTruncated {
+		t.Errorf("got %q %q - want bodies cut to 8 bytes", e.Body, e.ResponseBody)
+	}
+}
+
+// writes nothing, so tests don't print responses
+type quietHandler struct{ *BResponse }
+
+func (q *quietHandler) WriteResponse() { q.StringResponseBody() }
+
+func TestListHistory(t *testing.T) {
+	defer func(old string) { config.HistoryPath = old }(config.HistoryPath)
+	config.HistoryPath = t.TempDir()
+	for _, e := range []*HistoryEntry{
+		{Name: "mysite.users.get", Status: 200},
+		{Name: "mysite.users.create", Status: 422},
+		{Name: "other.ping", Status: 404},
+		{URL: "https://x.com", Status: 0, Errors: []string{"refused"}},
+	} {
+		if err := e.save(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := map[string]struct {
+		in   HistoryFilter
+		want []int
+	}{
+		"all":         {HistoryFilter{}, []int{4, 3, 2, 1}},
+		"limit":       {HistoryFilter{Limit: 2}, []int{4, 3}},
+		"name glob":   {HistoryFilter{Name: "mysite.*"}, []int{2, 1}},
+		"status":      {HistoryFilter{Status: "404"}, []int{3}},
+		"status 4xx":  {HistoryFilter{Status: "4xx"}, []int{3, 2}},
+		"name+status": {HistoryFilter{Name: "mysite.*", Status: "4XX"}, []int{2}},
+		"err":         {HistoryFilter{Status: "err"}, []int{4}},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			entries, err := ListHistory(tc.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []int
+			for _, e := range entries {
+				ids = append(ids, e.ID)
+			}
+			if !reflect.DeepEqual(ids, tc.want) {
+				t.Errorf("got %v - want %v", ids, tc.want)
+			}
+		})
+	}
+	for _, s := range []string{"4x", "600", "ok"} {
+		if _, err := ListHistory(HistoryFilter{Status: s}); err == nil {
+			t.Errorf("status %s: should err", s)
+		}
+	}
+}
+
+func TestReplayRequest(t *testing.T) {
+	config.Requests.ReadConfig(bytes.NewBufferString(`
+testspace:
+  baseUrl: https://mysite.com
+  auth:
+    authtype: Bearer
+    token: now-123
+  requests:
+    users: /users
+`))
+	h := http.Header{"Authorization": {"Bearer " + redacted}, "X-Trace": {"1"}}
+	tests := map[string]struct {
+		entry    HistoryEntry
+		rset     *RequestSet
+		wantURL  string
+		wantAuth string
+		wantErr  bool
+	}{
+		"saved fills in redacted": {
+			entry: HistoryEntry{Name: "testspace.users", Method: "GET", URL: "https://mysite.com/users?token=" + redacted, Header: h},
+			rset:  &RequestSet{}, wantURL: "https://mysite.com/users", wantAuth: "Bearer now-123",
+		},
+		"url with flags auth": {
+			entry: HistoryEntry{Method: "POST", URL: "https://x.com/a", Header: h, Body: "hi"},
+			rset:  &RequestSet{AuthType: "Token", Cred: "t-1"}, wantURL: "https://x.com/a", wantAuth: "Token t-1",
+		},
+		"url without auth": {
+			entry: HistoryEntry{Method: "GET", URL: "https://x.com/a", Header: h},
+			rset:  &RequestSet{}, wantURL: "https://x.com/a",
+		},
+		"redacted url":   {entry: HistoryEntry{Method: "GET", URL: "https://x.com/?token=" + redacted}, rset: &RequestSet{}, wantErr: true},
+		"truncated body": {entry: HistoryEntry{Method: "POST", URL: "https://x.com", Body: "{", BodyTruncated: true}, rset: &RequestSet{}, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			req, err := tc.rset.replayRequest(&tc.entry)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("%v: should err", name)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if req.URL.String() != tc.wantURL || req.Method != tc.entry.Method || req.Header.Get("Authorization") != tc.wantAuth || req.Header.Get("X-Trace") != "1" {
+				t.Errorf("got %s %s %v - want %s %s with auth %q", req.Method, req.URL, req.Header, tc.entry.Method, tc.wantURL, tc.wantAuth)
+			}
+			if strings.Contains(fmt.Sprint(req.Header), redacted) {
+				t.Errorf("redacted value sent: %v", req.Header)
+			}
+		})
+	}
+}
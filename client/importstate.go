@@ -0,0 +1,353 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jerempy/brang/config"
+	"gopkg.in/yaml.v3"
+)
+
+// importState is what was last imported to a group, kept to tell local edits of the
+// requests.yaml from changes of the spec when it is imported again
+type importState struct {
+	Source string `json:"source"`
+	// settings of the group, flattened by fields()
+	Group map[string]string `json:"group"`
+	// saved requests by their name under the group, flattened by fields()
+	Requests map[string]map[string]string `json:"requests"`
+}
+
+// Returns the import state file of the group
+func importFile(group string) (string, error) {
+	if err := checkStoreName("group", group); err != nil {
+		return "", err
+	}
+	return filepath.Join(config.ImportsPath, group+".json"), nil
+}
+
+func loadImportState(group string) (*importState, error) {
+	st := &importState{Group: map[string]string{}, Requests: map[string]map[string]string{}}
+	f, err := importFile(group)
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(f)
+	if errors.Is(err, fs.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("err reading last import of %s: %w", group, err)
+	}
+	if err := json.Unmarshal(b, st); err != nil {
+		return nil, fmt.Errorf("err reading last import of %s: %w", group, err)
+	}
+	return st, nil
+}
+
+func (st *importState) save(group string) error {
+	f, err := importFile(group)
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(config.ImportsPath, 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(f, b, 0600)
+}
+
+// MergeReport is what a re-import changed, by saved request name
+type MergeReport struct {
+	Added     []string
+	Updated   []string
+	Unchanged []string
+	// requests no longer in the spec. they are left in the requests.yaml
+	Gone []string
+	// fields edited locally and changed in the spec. the local edit is kept
+	Conflicts []string
+}
+
+// SaveMerged writes the group to requests.yaml, merging it with what is there. Fields of
+// requests and settings that were edited since the last import from source are kept, and
+// the rest are updated to the import. Requests that are no longer in the import are left.
+func (g *ImportedGroup) SaveMerged(source string) (*MergeReport, error) {
+	if err := config.LoadRequests(); err != nil {
+		return nil, err
+	}
+	st, err := loadImportState(g.Name)
+	if err != nil {
+		return nil, err
+	}
+	e, rep := config.Requests, &MergeReport{}
+	next := &importState{Source: source, Group: g.fields(), Requests: map[string]map[string]string{}}
+
+	cur, err := savedGroupFields(e, g.Name)
+	if err != nil {
+		return nil, err
+	}
+	merged, conflicts := merge3(st.Group, cur, next.Group)
+	for _, c := range conflicts {
+		rep.Conflicts = append(rep.Conflicts, g.Name+" "+c)
+	}
+	if err := setGroupFields(e, g.Name, cur, merged); err != nil {
+		return nil, err
+	}
+
+	for _, r := range g.Requests {
+		name := config.RequestName(r.Path)
+		path := append([]string{g.Name, "requests"}, r.Path...)
+		next.Requests[name] = requestFields(r.Request)
+		n := e.Lookup(path...)
+		if n == nil {
+			rep.Added = append(rep.Added, name)
+			if err := e.Set(savedValue(r.Request), path...); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		var sr SavedRequestSet
+		if n.Kind == yaml.ScalarNode {
+			sr.URL = n.Value
+		} else if err := decodeNode(n, &sr); err != nil {
+			return nil, err
+		}
+		cur := requestFields(sr)
+		merged, conflicts := merge3(st.Requests[name], cur, next.Requests[name])
+		for _, c := range conflicts {
+			rep.Conflicts = append(rep.Conflicts, name+" "+c)
+		}
+		if sameFields(cur, merged) {
+			rep.Unchanged = append(rep.Unchanged, name)
+			continue
+		}
+		rep.Updated = append(rep.Updated, name)
+		// captures and expects aren't imported, so they are kept as they are
+		if err := e.Set(savedValue(fromRequestFields(merged, sr)), path...); err != nil {
+			return nil, err
+		}
+	}
+	for name := range st.Requests {
+		if _, ok := next.Requests[name]; !ok {
+			rep.Gone = append(rep.Gone, name)
+		}
+	}
+	sort.Strings(rep.Gone)
+	if err := e.WriteConfig(); err != nil {
+		return nil, err
+	}
+	return rep, next.save(g.Name)
+}
+
+// Flattens the settings of the group to compare them field by field
+func (g *ImportedGroup) fields() map[string]string {
+	f := map[string]string{}
+	if g.BaseURL != "" {
+		f["baseUrl"] = g.BaseURL
+	}
+	if g.Auth != nil {
+		addAuthFields(f, g.Auth)
+	}
+	for k, v := range g.Vars {
+		f["vars."+k] = v
+	}
+	return f
+}
+
+func addAuthFields(f map[string]string, a *Auth) {
+	for k, v := range map[string]string{"authType": a.AuthType, "token": a.Token, "username": a.Username, "password": a.Password} {
+		if v != "" {
+			f["auth."+k] = v
+		}
+	}
+}
+
+// Returns the settings of the group in requests.yaml, flattened as by fields()
+func savedGroupFields(e *config.RequestsStore, group string) (map[string]string, error) {
+	f := map[string]string{}
+	if n := e.Lookup(group, "baseUrl"); n != nil && n.Value != "" {
+		f["baseUrl"] = n.Value
+	}
+	a, err := savedAuth(e, group)
+	if err != nil {
+		return nil, err
+	}
+	if a != nil {
+		addAuthFields(f, a)
+	}
+	vars := Vars{}
+	if err := decodeNode(e.Lookup(group, "vars"), &vars); err != nil {
+		return nil, err
+	}
+	for k, v := range vars {
+		f["vars."+k] = v
+	}
+	return f, nil
+}
+
+// Writes the settings of the group that changed from cur. Settings the merge drops are
+// left, as they can't be told from ones added locally
+func setGroupFields(e *config.RequestsStore, group string, cur, merged map[string]string) error {
+	if v := merged["baseUrl"]; v != "" && v != cur["baseUrl"] {
+		if err := e.Set(v, group, "baseUrl"); err != nil {
+			return err
+		}
+	}
+	a, authChanged := Auth{}, false
+	for _, f := range []struct {
+		v   *string
+		key string
+	}{{&a.AuthType, "auth.authType"}, {&a.Token, "auth.token"}, {&a.Username, "auth.username"}, {&a.Password, "auth.password"}} {
+		*f.v = merged[f.key]
+		authChanged = authChanged || merged[f.key] != cur[f.key]
+	}
+	if authChanged && a.AuthType != "" {
+		if err := e.Set(&a, group, "auth"); err != nil {
+			return err
+		}
+	}
+	for _, k := range sortedKeys(merged) {
+		if name, ok := strings.CutPrefix(k, "vars."); ok && merged[k] != cur[k] {
+			if err := e.Set(merged[k], group, "vars", name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Flattens a saved request to compare it field by field
+func requestFields(sr SavedRequestSet) map[string]string {
+	f := map[string]string{"url": sr.URL}
+	if sr.Method != "" {
+		f["method"] = strings.ToUpper(sr.Method)
+	}
+	if !addJSONBodyFields(f, sr.Body) && sr.Body != "" {
+		f["body"] = sr.Body
+	}
+	for k, v := range sr.Header {
+		f["header."+k] = v
+	}
+	return f
+}
+
+// Flattens a json object body to a field per value, like body.customer.email, so edits
+// to some of its values are merged with changes to others. Tells if the body was flattened.
+func addJSONBodyFields(f map[string]string, body string) bool {
+	d := json.NewDecoder(strings.NewReader(body))
+	d.UseNumber()
+	var obj map[string]interface{}
+	if err := d.Decode(&obj); err != nil || obj == nil || d.More() {
+		return false
+	}
+	fields := map[string]string{}
+	var walk func(prefix string, v interface{}) bool
+	walk = func(prefix string, v interface{}) bool {
+		if m, ok := v.(map[string]interface{}); ok && len(m) > 0 {
+			for k, val := range m {
+				if strings.Contains(k, ".") || !walk(prefix+"."+k, val) {
+					return false
+				}
+			}
+			return true
+		}
+		b, err := json.Marshal(v)
+		fields[prefix] = string(b)
+		return err == nil
+	}
+	if len(obj) == 0 || !walk("body", obj) {
+		return false
+	}
+	for k, v := range fields {
+		f[k] = v
+	}
+	return true
+}
+
+// Returns sr with its url, method, body and headers from the fields
+func fromRequestFields(f map[string]string, sr SavedRequestSet) SavedRequestSet {
+	sr.URL, sr.Method, sr.Body, sr.Header = f["url"], f["method"], f["body"], nil
+	obj := map[string]interface{}{}
+	for k, v := range f {
+		if h, ok := strings.CutPrefix(k, "header."); ok {
+			if sr.Header == nil {
+				sr.Header = map[string]string{}
+			}
+			sr.Header[h] = v
+		} else if p, ok := strings.CutPrefix(k, "body."); ok {
+			keys := strings.Split(p, ".")
+			m := obj
+			for _, key := range keys[:len(keys)-1] {
+				next, ok := m[key].(map[string]interface{})
+				if !ok {
+					next = map[string]interface{}{}
+					m[key] = next
+				}
+				m = next
+			}
+			m[keys[len(keys)-1]] = json.RawMessage(v)
+		}
+	}
+	if sr.Body == "" && len(obj) > 0 {
+		b, _ := json.MarshalIndent(obj, "", "  ")
+		sr.Body = string(b)
+	}
+	return sr
+}
+
+// Merges the fields of ours, edited locally, and theirs, newly imported, from base, as last
+// imported. A field changed on one side takes that change. A field changed on both sides
+// to different values keeps ours, and is returned as a conflict.
+func merge3(base, ours, theirs map[string]string) (map[string]string, []string) {
+	merged := map[string]string{}
+	var conflicts []string
+	keys := map[string]bool{}
+	for _, m := range []map[string]string{base, ours, theirs} {
+		for k := range m {
+			keys[k] = true
+		}
+	}
+	for k := range keys {
+		b, bok := base[k]
+		o, ook := ours[k]
+		t, tok := theirs[k]
+		switch {
+		case o == b && ook == bok:
+			if tok {
+				merged[k] = t
+			}
+		case t == b && tok == bok, o == t && ook == tok:
+			if ook {
+				merged[k] = o
+			}
+		default:
+			if ook {
+				merged[k] = o
+			}
+			conflicts = append(conflicts, k)
+		}
+	}
+	sort.Strings(conflicts)
+	return merged, conflicts
+}
+
+func sameFields(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if w, ok := b[k]; !ok || w != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,119 @@
+package client
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jerempy/brang/config"
+)
+
+func TestMerge3(t *testing.T) {
+	base := map[string]string{"url": "/a", "method": "POST", "body.x": "1", "header.H": "h"}
+	tests := map[string]struct {
+		ours, theirs  map[string]string
+		want          map[string]string
+		wantConflicts []string
+	}{
+		"no edits": {
+			ours:   base,
+			theirs: map[string]string{"url": "/b", "method": "POST", "body.x": "2"},
+			want:   map[string]string{"url": "/b", "method": "POST", "body.x": "2"},
+		},
+		"local edit kept": {
+			ours:   map[string]string{"url": "/a", "method": "POST", "body.x": "5", "header.H": "h", "header.Mine": "m"},
+			theirs: map[string]string{"url": "/b", "method": "POST", "body.x": "1", "header.H": "h"},
+			want:   map[string]string{"url": "/b", "method": "POST", "body.x": "5", "header.H": "h", "header.Mine": "m"},
+		},
+		"both changed": {
+			ours:          map[string]string{"url": "/mine", "method": "POST", "body.x": "1", "header.H": "h"},
+			theirs:        map[string]string{"url": "/theirs", "method": "PUT", "body.x": "1", "header.H": "h"},
+			want:          map[string]string{"url": "/mine", "method": "PUT", "body.x": "1", "header.H": "h"},
+			wantConflicts: []string{"url"},
+		},
+		"removed locally": {
+			ours:   map[string]string{"url": "/a", "method": "POST", "body.x": "1"},
+			theirs: base,
+			want:   map[string]string{"url": "/a", "method": "POST", "body.x": "1"},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, conflicts := merge3(base, tc.ours, tc.theirs)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v - want %v", got, tc.want)
+			}
+			if !reflect.DeepEqual(conflicts, tc.wantConflicts) {
+				t.Errorf("conflicts: got %v - want %v", conflicts, tc.wantConflicts)
+			}
+		})
+	}
+}
+
+func TestRequestFieldsJSONBody(t *testing.T) {
+	sr := SavedRequestSet{URL: "/a", Body: `{"a": 1, "b": {"c": "x", "d": [1, 2]}, "e": {}}`, Capture: map[string]string{"id": "$.id"}}
+	f := requestFields(sr)
+	want := map[string]string{"url": "/a", "body.a": "1", "body.b.c": `"x"`, "body.b.d": "[1,2]", "body.e": "{}"}
+	if !reflect.DeepEqual(f, want) {
+		t.Errorf("got %v - want %v", f, want)
+	}
+	f["body.b.c"] = `"y"`
+	back := fromRequestFields(f, sr)
+	wantBody := "{\n  \"a\": 1,\n  \"b\": {\n    \"c\": \"y\",\n    \"d\": [\n      1,\n      2\n    ]\n  },\n  \"e\": {}\n}"
+	if back.Body != wantBody || back.Capture["id"] != "$.id" {
+		t.Errorf("got %+v - want body %s with the capture kept", back, wantBody)
+	}
+}
+
+func TestSaveMerged(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "requests.yaml")
+	os.WriteFile(f, []byte(""), 0644)
+	config.Requests.SetConfigFile(f)
+	defer config.Requests.SetConfigFile("")
+	defer func(old string) { config.ImportsPath = old }(config.ImportsPath)
+	config.ImportsPath = t.TempDir()
+
+	spec := func(amount, currency, path string) *ImportedGroup {
+		g := newImportedGroup("pay")
+		g.BaseURL = "https://pay.example.com"
+		g.Requests = []ImportedRequest{
+			{Path: []string{"create"}, Request: SavedRequestSet{URL: "/payments", Method: "POST",
+				Body: `{"amount": ` + amount + `, "currency": "` + currency + `"}`}},
+			{Path: []string{"get"}, Request: SavedRequestSet{URL: path}},
+		}
+		return g
+	}
+	rep, err := spec("1", "EUR", "/payments/{{id}}").SaveMerged("spec.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rep.Added) != 2 {
+		t.Errorf("added: got %+v - want 2", rep)
+	}
+
+	// edit the amount and url locally, as a user would
+	b, _ := os.ReadFile(f)
+	edited := strings.Replace(string(b), `"amount": 1`, `"amount": 5`, 1)
+	edited = strings.Replace(edited, "get: /payments/{{id}}", "get: /payments/{{id}}?expand=all", 1)
+	os.WriteFile(f, []byte(edited), 0644)
+
+	g := spec("2", "USD", "/v2/payments/{{id}}")
+	g.Requests = g.Requests[:1]
+	rep, err = g.SaveMerged("spec.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(rep.Updated, []string{"create"}) || !reflect.DeepEqual(rep.Conflicts, []string{"create body.amount"}) ||
+		!reflect.DeepEqual(rep.Gone, []string{"get"}) {
+		t.Errorf("report: got %+v - want create updated with a conflict of body.amount and get gone", rep)
+	}
+	b, _ = os.ReadFile(f)
+	got := string(b)
+	for _, want := range []string{`"amount": 5`, `"currency": "USD"`, "get: /payments/{{id}}?expand=all", "baseUrl: https://pay.example.com"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("merged file missing %q:\n%s", want, got)
+		}
+	}
+}
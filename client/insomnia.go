@@ -0,0 +1,294 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// insomniaExport is an Insomnia export in the v4 format: a flat list of resources
+// linked to their parent by id
+type insomniaExport struct {
+	Format    int                `json:"__export_format"`
+	Resources []insomniaResource `json:"resources"`
+}
+
+// insomniaResource is any of a workspace, request_group, request or environment, by its _type
+type insomniaResource struct {
+	ID       string `json:"_id"`
+	Type     string `json:"_type"`
+	ParentID string `json:"parentId"`
+	Name     string `json:"name"`
+	// request
+	Method         string                 `json:"method"`
+	URL            string                 `json:"url"`
+	Body           insomniaBody           `json:"body"`
+	Headers        []insomniaParam        `json:"headers"`
+	Parameters     []insomniaParam        `json:"parameters"`
+	Authentication insomniaAuth           `json:"authentication"`
+	SortKey        float64                `json:"metaSortKey"`
+	Data           map[string]interface{} `json:"data"`
+	Environment    map[string]interface{} `json:"environment"`
+}
+
+type insomniaBody struct {
+	MimeType string          `json:"mimeType"`
+	Text     string          `json:"text"`
+	Params   []insomniaParam `json:"params"`
+}
+
+type insomniaParam struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Type     string `json:"type"`
+	Disabled bool   `json:"disabled"`
+}
+
+type insomniaAuth struct {
+	Type     string `json:"type"`
+	Disabled bool   `json:"disabled"`
+	Token    string `json:"token"`
+	Prefix   string `json:"prefix"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Key      string `json:"key"`
+	Value    string `json:"value"`
+	AddTo    string `json:"addTo"`
+}
+
+var (
+	// {{ _.name }} of Insomnia's templates
+	insomniaVar = regexp.MustCompile(`\{\{\s*_\.([A-Za-z0-9_.-]+)\s*\}\}`)
+	// {% tag %} of Insomnia's templates, such as {% response ... %}
+	insomniaTag = regexp.MustCompile(`\{%.*?%\}`)
+)
+
+// ParseInsomnia reads an Insomnia v4 export into a group per workspace. group names the
+// group when there is only one workspace, else they are named after the workspaces.
+// Folders become nested requests, the base environment the group's vars and the
+// sub environments its environments.
+func ParseInsomnia(data []byte, group string) ([]*ImportedGroup, error) {
+	var ex insomniaExport
+	if err := json.Unmarshal(data, &ex); err != nil {
+		return nil, fmt.Errorf("err reading Insomnia export: %w", err)
+	}
+	if ex.Format != 4 {
+		return nil, fmt.Errorf("not an Insomnia v4 export. export it from Insomnia as Insomnia v4 (JSON)")
+	}
+	children := map[string][]*insomniaResource{}
+	var workspaces []*insomniaResource
+	for i := range ex.Resources {
+		r := &ex.Resources[i]
+		children[r.ParentID] = append(children[r.ParentID], r)
+		if r.Type == "workspace" {
+			workspaces = append(workspaces, r)
+		}
+	}
+	for _, c := range children {
+		sort.SliceStable(c, func(i, j int) bool { return c[i].SortKey < c[j].SortKey })
+	}
+	if len(workspaces) == 0 {
+		return nil, fmt.Errorf("no workspace found in the Insomnia export")
+	}
+	if group != "" && len(workspaces) > 1 {
+		return nil, fmt.Errorf("the export has %d workspaces, so they are named after them. leave out the group name", len(workspaces))
+	}
+	var groups []*ImportedGroup
+	used := map[string]bool{}
+	for _, ws := range workspaces {
+		name := group
+		if name == "" {
+			name = importKey(ws.Name, used)
+		}
+		in := &insomniaImport{g: newImportedGroup(name), children: children}
+		in.environments(ws.ID)
+		in.items(ws.ID, nil, map[string]bool{})
+		in.g.finish()
+		groups = append(groups, in.g)
+	}
+	return groups, nil
+}
+
+type insomniaImport struct {
+	g        *ImportedGroup
+	children map[string][]*insomniaResource
+}
+
+// Makes the templates of s into brang's: {{ _.name }} to {{name}}. {% tags %} are noted
+func (in *insomniaImport) template(name, s string) string {
+	s = insomniaVar.ReplaceAllString(s, "{{$1}}")
+	for _, t := range insomniaTag.FindAllString(s, -1) {
+		in.g.note("%s: template tag %s isn't supported and is sent as is", name, t)
+	}
+	in.g.checkVars(name, s)
+	return s
+}
+
+// Reads the base environment of the workspace as the group's vars, and its sub
+// environments as the group's environments
+func (in *insomniaImport) environments(workspace string) {
+	for _, base := range in.children[workspace] {
+		if base.Type != "environment" {
+			continue
+		}
+		for k, v := range flattenVars(base.Data) {
+			in.g.addVar(in.g.Vars, k, in.template("base environment", v))
+		}
+		used := map[string]bool{}
+		for _, sub := range in.children[base.ID] {
+			if sub.Type != "environment" {
+				continue
+			}
+			key := importKey(sub.Name, used)
+			env := Environment{Vars: Vars{}}
+			for k, v := range flattenVars(sub.Data) {
+				v = in.template("environment "+key, v)
+				if isSecretName(k) {
+					v = in.g.secretRef(key+"_"+k, v)
+				}
+				env.Vars[k] = v
+			}
+			in.g.Environments[key] = env
+		}
+		// a workspace has one base environment
+		return
+	}
+}
+
+// Flattens nested data to vars of dotted names, such as api.host, as used in {{ _.api.host }}
+func flattenVars(data map[string]interface{}) Vars {
+	v := Vars{}
+	var walk func(prefix string, d interface{})
+	walk = func(prefix string, d interface{}) {
+		switch d := d.(type) {
+		case map[string]interface{}:
+			for k, val := range d {
+				if prefix != "" {
+					k = prefix + "." + k
+				}
+				walk(k, val)
+			}
+		case string:
+			v[prefix] = d
+		case nil:
+			v[prefix] = ""
+		default:
+			b, _ := json.Marshal(d)
+			v[prefix] = string(b)
+		}
+	}
+	walk("", data)
+	return v
+}
+
+func (in *insomniaImport) items(parent string, path []string, used map[string]bool) {
+	for _, r := range in.children[parent] {
+		switch r.Type {
+		case "request_group":
+			key := importKey(r.Name, used)
+			p := append(append([]string{}, path...), key)
+			if len(r.Environment) > 0 {
+				in.g.note("%s: folder environment isn't imported. set its values as vars of %s", strings.Join(p, "."), in.g.Name)
+			}
+			in.items(r.ID, p, map[string]bool{})
+		case "request":
+			in.request(append(append([]string{}, path...), importKey(r.Name, used)), r)
+		case "grpc_request", "websocket_request":
+			in.g.note("%s: %s isn't supported and isn't imported", r.Name, strings.ReplaceAll(r.Type, "_", " "))
+		}
+	}
+}
+
+func (in *insomniaImport) request(path []string, r *insomniaResource) {
+	g, name := in.g, strings.Join(path, ".")
+	sr := SavedRequestSet{URL: in.template(name, r.URL)}
+	if m := strings.ToUpper(r.Method); m != "" && m != http.MethodGet {
+		sr.Method = m
+	}
+	var query [][2]string
+	for _, p := range r.Parameters {
+		if !p.Disabled {
+			query = append(query, [2]string{in.template(name, p.Name), in.template(name, p.Value)})
+		}
+	}
+	sr.URL = addQuery(sr.URL, formEncode(query))
+	header := map[string]string{}
+	for _, h := range r.Headers {
+		if !h.Disabled && h.Name != "" {
+			header[h.Name] = in.template(name, h.Value)
+		}
+	}
+	switch b := r.Body; b.MimeType {
+	case "":
+	case "application/x-www-form-urlencoded":
+		var fields [][2]string
+		for _, p := range b.Params {
+			if !p.Disabled {
+				fields = append(fields, [2]string{in.template(name, p.Name), in.template(name, p.Value)})
+			}
+		}
+		sr.Body = formEncode(fields)
+		setContentType(header, b.MimeType, true)
+	case "multipart/form-data":
+		var fields [][2]string
+		for _, p := range b.Params {
+			switch {
+			case p.Disabled:
+			case p.Type == "file":
+				g.note("%s: file field %s of the form isn't imported", name, p.Name)
+			default:
+				fields = append(fields, [2]string{in.template(name, p.Name), in.template(name, p.Value)})
+			}
+		}
+		if body, ct, err := multipartBody(fields); err == nil {
+			sr.Body = body
+			setContentType(header, ct, true)
+		}
+	case "application/octet-stream":
+		g.note("%s: a body from a file isn't imported. save it and send with -f", name)
+	case "application/graphql":
+		// the text is already json of the query and variables
+		sr.Body = in.template(name, b.Text)
+		setContentType(header, "application/json", true)
+	default:
+		sr.Body = in.template(name, b.Text)
+		if !strings.Contains(b.MimeType, "json") {
+			setContentType(header, b.MimeType, false)
+		}
+	}
+	var auth *Auth
+	if a := r.Authentication; !a.Disabled {
+		switch a.Type {
+		case "bearer":
+			auth = &Auth{AuthType: "Bearer", Token: in.template(name, a.Token)}
+			if a.Prefix != "" && a.Prefix != "Bearer" {
+				auth.AuthType = a.Prefix
+				if a.Prefix != "Token" {
+					g.note("%s: bearer auth with prefix %s isn't supported and isn't imported", name, a.Prefix)
+					auth = nil
+				}
+			}
+		case "basic":
+			auth = &Auth{AuthType: "Password", Username: in.template(name, a.Username), Password: in.template(name, a.Password)}
+		case "apikey":
+			key := in.template(name, a.Key)
+			val := g.secretRef(key, in.template(name, a.Value))
+			if a.AddTo == "queryParams" {
+				sr.URL = addQuery(sr.URL, formEncode([][2]string{{key, val}}))
+			} else {
+				header[key] = val
+			}
+		case "", "none":
+		default:
+			g.note("%s: %s auth isn't supported and isn't imported", name, a.Type)
+		}
+	}
+	auth = g.secretHeaders(header, auth)
+	if len(header) > 0 {
+		sr.Header = header
+	}
+	g.addRequest(path, sr, auth)
+}
@@ -0,0 +1,99 @@
+package client
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testInsomniaExport = `{"_type": "export", "__export_format": 4, "resources": [
+  {"_id": "wrk_1", "_type": "workspace", "parentId": null, "name": "Billing API"},
+  {"_id": "env_base", "_type": "environment", "parentId": "wrk_1", "name": "Base Environment",
+    "data": {"baseUrl": "https://billing.example.com", "api": {"version": "v2"}, "apiToken": "tok-123", "retries": 3}},
+  {"_id": "env_stg", "_type": "environment", "parentId": "env_base", "name": "Staging",
+    "data": {"baseUrl": "https://stg.billing.example.com", "apiToken": "stg-tok"}},
+  {"_id": "fld_1", "_type": "request_group", "parentId": "wrk_1", "name": "Invoices", "metaSortKey": 1},
+  {"_id": "req_2", "_type": "request", "parentId": "fld_1", "name": "Create invoice", "method": "POST", "metaSortKey": 2,
+    "url": "{{ _.baseUrl }}/{{ _.api.version }}/invoices",
+    "body": {"mimeType": "application/xml", "text": "<amount>5</amount>"},
+    "authentication": {"type": "bearer", "token": "{{ _.apiToken }}"}},
+  {"_id": "req_1", "_type": "request", "parentId": "fld_1", "name": "List invoices", "method": "GET", "metaSortKey": 1,
+    "url": "{{ _.baseUrl }}/invoices",
+    "parameters": [{"name": "status", "value": "open"}, {"name": "x", "value": "1", "disabled": true}],
+    "authentication": {"type": "bearer", "token": "{{ _.apiToken }}"}},
+  {"_id": "req_3", "_type": "request", "parentId": "wrk_1", "name": "Sign in", "method": "POST", "metaSortKey": 2,
+    "url": "{{ _.baseUrl }}/signin", "headers": [{"name": "X-Req", "value": "{% uuid 'v4' %}"}],
+    "body": {"mimeType": "application/x-www-form-urlencoded", "params": [{"name": "user", "value": "{{ _.user }}"}]},
+    "authentication": {"type": "basic", "username": "joe", "password": "pw"}},
+  {"_id": "req_4", "_type": "request", "parentId": "wrk_1", "name": "Reports", "method": "GET", "metaSortKey": 3,
+    "url": "{{ _.baseUrl }}/reports", "headers": [{"name": "Authorization", "value": "Bearer plainsecret"}, {"name": "X-Api-Key", "value": "k-plain"}]}
+]}`
+
+func TestParseInsomnia(t *testing.T) {
+	groups, err := ParseInsomnia([]byte(testInsomniaExport), "billing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 {
+		t.Fatalf("groups: got %v - want 1", len(groups))
+	}
+	g := groups[0]
+	if g.Name != "billing" || g.BaseURL != "https://billing.example.com" {
+		t.Errorf("group: got %v %v - want billing https://billing.example.com", g.Name, g.BaseURL)
+	}
+	wantVars := Vars{"api.version": "v2", "apiToken": "${BILLING_APITOKEN}", "retries": "3"}
+	if !reflect.DeepEqual(g.Vars, wantVars) {
+		t.Errorf("vars: got %v - want %v", g.Vars, wantVars)
+	}
+	wantEnvs := map[string]Environment{"staging": {BaseURL: "https://stg.billing.example.com", Vars: Vars{"apiToken": "${BILLING_STAGING_APITOKEN}"}}}
+	if !reflect.DeepEqual(g.Environments, wantEnvs) {
+		t.Errorf("environments: got %+v - want %+v", g.Environments, wantEnvs)
+	}
+	if g.Auth == nil || *g.Auth != (Auth{AuthType: "Bearer", Token: "{{apiToken}}"}) {
+		t.Errorf("auth: got %+v - want Bearer of {{apiToken}}", g.Auth)
+	}
+	want := []ImportedRequest{
+		{Path: []string{"invoices", "list-invoices"}, Request: SavedRequestSet{URL: "/invoices?status=open"}},
+		{Path: []string{"invoices", "create-invoice"}, Request: SavedRequestSet{URL: "/{{api.version}}/invoices", Method: "POST",
+			Body: "<amount>5</amount>", Header: map[string]string{"Content-Type": "application/xml"}}},
+		{Path: []string{"sign-in"}, Request: SavedRequestSet{URL: "/signin", Method: "POST", Body: "user={{user}}",
+			Header: map[string]string{"X-Req": "{% uuid 'v4' %}", "Content-Type": "application/x-www-form-urlencoded"}}},
+		{Path: []string{"reports"}, Request: SavedRequestSet{URL: "/reports", Header: map[string]string{"X-Api-Key": "${BILLING_X_API_KEY}"}}},
+	}
+	if !reflect.DeepEqual(g.Requests, want) {
+		t.Errorf("requests: got %+v - want %+v", g.Requests, want)
+	}
+	notes := strings.Join(g.Notes, "\n")
+	for _, n := range []string{"{% uuid 'v4' %}", "sign-in: its Password auth isn't imported", "reports: its Bearer auth isn't imported"} {
+		if !strings.Contains(notes, n) {
+			t.Errorf("missing a note of %q in:\n%s", n, notes)
+		}
+	}
+	if strings.Contains(notes+fmt.Sprint(g.Requests), "plain") {
+		t.Errorf("cred kept as plain text")
+	}
+	if !reflect.DeepEqual(g.Envs, []string{"BILLING_APITOKEN", "BILLING_STAGING_APITOKEN", "BILLING_X_API_KEY"}) {
+		t.Errorf("envs: got %v", g.Envs)
+	}
+}
+
+func TestParseInsomniaErrs(t *testing.T) {
+	tests := map[string]struct {
+		in    string
+		group string
+	}{
+		"not json":     {in: `{"resources":`},
+		"v3":           {in: `{"__export_format": 3, "resources": []}`},
+		"no workspace": {in: `{"__export_format": 4, "resources": []}`},
+		"group of many": {in: `{"__export_format": 4, "resources": [{"_id": "a", "_type": "workspace", "name": "a"},
+			{"_id": "b", "_type": "workspace", "name": "b"}]}`, group: "x"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseInsomnia([]byte(tc.in), tc.group); err == nil {
+				t.Errorf("%v: should err", name)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// jsonPath is a parsed JSONPath, supporting the common subset:
+// $.key, $['key'], $[0], $[-1], $.*, $[*] and $..key for a key at any depth.
+type jsonPath struct {
+	raw   string
+	steps []pathStep
+}
+
+type pathStep struct {
+	key       string
+	index     int
+	isIndex   bool
+	wildcard  bool
+	recursive bool
+}
+
+func parseJSONPath(p string) (*jsonPath, error) {
+	jp := &jsonPath{raw: p}
+	rest, ok := strings.CutPrefix(strings.TrimSpace(p), "$")
+	if !ok {
+		return nil, fmt.Errorf("json path needs to start with $ - ex: $.data.id. was given: %s", p)
+	}
+	for rest != "" {
+		var s pathStep
+		switch {
+		case strings.HasPrefix(rest, ".."):
+			s.recursive = true
+			rest = rest[2:]
+			if strings.HasPrefix(rest, "[") {
+				var err error
+				if s, rest, err = parseBracket(rest); err != nil {
+					return nil, fmt.Errorf("%s: %w", p, err)
+				}
+				s.recursive = true
+				break
+			}
+			s.key, rest = cutKey(rest)
+		case rest[0] == '.':
+			s.key, rest = cutKey(rest[1:])
+		case rest[0] == '[':
+			var err error
+			if s, rest, err = parseBracket(rest); err != nil {
+				return nil, fmt.Errorf("%s: %w", p, err)
+			}
+		default:
+			return nil, fmt.Errorf("unexpected %q in json path %s", rest[0], p)
+		}
+		if s.key == "*" && !s.isIndex {
+			s.key, s.wildcard = "", true
+		}
+		if s.key == "" && !s.isIndex && !s.wildcard {
+			return nil, fmt.Errorf("empty key in json path %s", p)
+		}
+		jp.steps = append(jp.steps, s)
+	}
+	return jp, nil
+}
+
+// Cuts a key up to the next . or [
+func cutKey(s string) (string, string) {
+	i := strings.IndexAny(s, ".[")
+	if i < 0 {
+		return s, ""
+	}
+	return s[:i], s[i:]
+}
+
+// Parses ['key'], ["key"], [0] or [*] at the start of s
+func parseBracket(s string) (pathStep, string, error) {
+	var step pathStep
+	end := strings.Index(s, "]")
+	if end < 0 {
+		return step, "", fmt.Errorf("missing ]")
+	}
+	in := s[1:end]
+	switch {
+	case in == "*":
+		step.wildcard = true
+	case len(in) >= 2 && (in[0] == '\'' || in[0] == '"') && in[len(in)-1] == in[0]:
+		step.key = in[1 : len(in)-1]
+	case len(in) > 0 && (in[0] == '\'' || in[0] == '"'):
+		// the quoted key holds a ], so look for the closing quote
+		q := strings.Index(s[2:], string(in[0])+"]")
+		if q < 0 {
+			return step, "", fmt.Errorf("missing closing %c]", in[0])
+		}
+		step.key, end = s[2:q+2], q+3
+	default:
+		i, err := strconv.Atoi(in)
+		if err != nil {
+			return step, "", fmt.Errorf("[%s] needs to be an index, * or a quoted key", in)
+		}
+		step.index, step.isIndex = i, true
+	}
+	return step, s[end+1:], nil
+}
+
+// Find returns every value in v matched by the path
+func (jp *jsonPath) Find(v interface{}) []interface{} {
+	found := []interface{}{v}
+	for _, s := range jp.steps {
+		var next []interface{}
+		for _, f := range found {
+			if s.recursive {
+				walkJSON(f, func(d interface{}) {
+					next = append(next, s.match(d)...)
+				})
+			} else {
+				next = append(next, s.match(f)...)
+			}
+		}
+		found = next
+	}
+	return found
+}
+
+func (s pathStep) match(v interface{}) []interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		if s.wildcard {
+			var all []interface{}
+			for _, k := range sortedKeys(t) {
+				all = append(all, t[k])
+			}
+			return all
+		}
+		if c, ok := t[s.key]; ok && !s.isIndex {
+			return []interface{}{c}
+		}
+	case []interface{}:
+		if s.wildcard {
+			return t
+		}
+		if s.isIndex {
+			i := s.index
+			if i < 0 {
+				i += len(t)
+			}
+			if i >= 0 && i < len(t) {
+				return []interface{}{t[i]}
+			}
+		}
+	}
+	return nil
+}
+
+// Calls fn for v and every value nested in it
+func walkJSON(v interface{}, fn func(interface{})) {
+	fn(v)
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for _, k := range sortedKeys(t) {
+			walkJSON(t[k], fn)
+		}
+	case []interface{}:
+		for _, c := range t {
+			walkJSON(c, fn)
+		}
+	}
+}
+
+// Returns the keys of m in order, so maps are walked and written the same way each time
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Decodes a json body for use with json paths
+func decodeJSON(b []byte) (interface{}, error) {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, fmt.Errorf("body isn't json: %w", err)
+	}
+	return v, nil
+}
+
+// Makes a json value into a string: strings as is, everything else as json
+func jsonString(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
@@ -0,0 +1,58 @@
+package client
+
+import (
+	"strings"
+	"testing"
+)
+
+var mockJSON = []byte(`{
+  "data": {"access_token": "abc", "expires": 3600},
+  "items": [{"id": 1, "price": 2.5}, {"id": 2, "price": 3, "tags": ["a"]}],
+  "odd.key": {"x]": true}
+}`)
+
+func TestJSONPathFind(t *testing.T) {
+	v, err := decodeJSON(mockJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]struct {
+		in   string
+		want string
+	}{
+		"root":         {in: "$", want: `{"data":{"access_token":"abc","expires":3600},"items":[{"id":1,"price":2.5},{"id":2,"price":3,"tags":["a"]}],"odd.key":{"x]":true}}`},
+		"key":          {in: "$.data.access_token", want: "abc"},
+		"number":       {in: "$.data.expires", want: "3600"},
+		"index":        {in: "$.items[1].price", want: "3"},
+		"last index":   {in: "$.items[-1].id", want: "2"},
+		"wildcard":     {in: "$.items[*].id", want: "1|2"},
+		"dot wildcard": {in: "$.data.*", want: "abc|3600"},
+		"quoted key":   {in: `$['odd.key']["x]"]`, want: "true"},
+		"recursive":    {in: "$..id", want: "1|2"},
+		"not found":    {in: "$.data.nope", want: ""},
+		"out of range": {in: "$.items[5]", want: ""},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			jp, err := parseJSONPath(tc.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, f := range jp.Find(v) {
+				got = append(got, jsonString(f))
+			}
+			if strings.Join(got, "|") != tc.want {
+				t.Errorf("%v: got %v - want %v", tc.in, strings.Join(got, "|"), tc.want)
+			}
+		})
+	}
+}
+
+func TestParseJSONPathErrs(t *testing.T) {
+	for _, in := range []string{"data.id", "$.", "$[abc]", "$['key'", "$.a[1"} {
+		if _, err := parseJSONPath(in); err == nil {
+			t.Errorf("%v: should err", in)
+		}
+	}
+}
@@ -4,48 +4,94 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"reflect"
 	"strings"
 
 	"github.com/jerempy/brang/config"
 	"github.com/mitchellh/mapstructure"
-	"golang.org/x/text/cases"
-	"golang.org/x/text/language"
+	"gopkg.in/yaml.v3"
 )
 
 type SavedRequestSet struct {
-	URL    string
-	Body   string
-	Header map[string]string
+	URL    string            `yaml:"url"`
+	Method string            `yaml:"method,omitempty"`
+	Body   string            `yaml:"body,omitempty"`
+	Header map[string]string `yaml:"header,omitempty"`
+	// name: rule to capture values from the response into the group's state, for use as {{name}}
+	Capture map[string]string `yaml:"capture,omitempty"`
+	// assertions on the response, checked by 'brang test'
+	Expect *Expect `yaml:"expect,omitempty"`
 }
 
 // LoadSavedRequest accepts a string from arg in running command as dot.notation.
 // Looks up against the requests.yaml, loads it and searches for the request.
 // The saved request in requests.yaml could be <name>: <url string>.
-// Can also be <name>: {url: <string>, body: <json>, header: {<key>:<value>}}
+// Can also be <name>: {url: <string>, method: <string>, body: <json>, header: {<key>:<value>}}
+// Keys containing dots can be quoted, ex: mysite."v1.2".users
+// {{name}} placeholders are filled from --var flags, values captured from earlier responses
+// of the group, the group's vars: and env variables.
+// Urls starting with / are joined to the baseUrl of the group, or of its environment selected with --env.
 // Currently only supports 1 request file and reads whole file - this can be re-visited.
 func LoadSavedRequest(rset *RequestSet) (*http.Request, error) {
 	if err := config.LoadRequests(); err != nil {
 		return nil, err
 	}
-	s := strings.Split(rset.URL, ".")
-	q := s[0] + ".requests." + strings.Join(s[1:], ".")
-	auth := &Auth{}
-	config.Requests.UnmarshalKey(s[0]+".auth", auth)
-	rset.AuthType = auth.AuthType
-	rset.Cred = getCred(auth)
-	v := config.Requests.Get(q)
-	if v == "" || v == nil {
+	path, err := config.ParseRequestPath(rset.URL)
+	if err != nil {
+		return nil, err
+	}
+	g, err := loadGroup(path[0])
+	if err != nil {
+		return nil, err
+	}
+	if rset.Env, err = g.envName(rset.Env); err != nil {
+		return nil, err
+	}
+	g.useEnv(rset.Env)
+	rset.AuthType = g.Auth.AuthType
+	rset.Cred = getCred(&g.Auth)
+	rset.vars = g.Vars
+	state, err := LoadState(g.Name)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range state {
+		rset.vars[k] = v
+	}
+	v := config.Requests.Lookup(append([]string{path[0], "requests"}, path[1:]...)...)
+	if v == nil || v.Value == "" && v.Kind == yaml.ScalarNode {
 		return nil, fmt.Errorf("saved request not found: %s. type 'brang config -h' for help in checking config", rset.URL)
 	}
 	var sr SavedRequestSet
-	r, ok := v.(string)
-	if ok {
-		sr.URL = r
-	} else {
-		mapstructure.Decode(v, &sr)
+	switch v.Kind {
+	case yaml.ScalarNode:
+		sr.URL = v.Value
+	case yaml.MappingNode:
+		if err := decodeNode(v, &sr); err != nil {
+			return nil, err
+		}
 		mapLoadedValsToHeaderSlice(rset, sr.Header)
+	default:
+		return nil, config.Requests.Errorf(v, "saved request %s needs to be a url or a map of url, method, body, header", rset.URL)
+	}
+	rset.group, rset.saved, rset.name = g.Name, &sr, config.RequestName(path)
+	rset.groupTransport, rset.groupSession = g.Transport, g.Session
+	switch g.Auth.AuthType {
+	case "OAuth2":
+		if err := rset.useOAuth2(g); err != nil {
+			return nil, err
+		}
+	case "AWSSigV4":
+		if rset.sigv4, err = newSigV4Signer(g.Auth, g.Name); err != nil {
+			return nil, err
+		}
+	}
+	if err := resolveMethod(rset, sr.Method); err != nil {
+		return nil, err
+	}
+	if rset.URL, err = g.resolveURL(sr.URL); err != nil {
+		return nil, err
 	}
-	rset.URL = sr.URL
 	if rset.Body == "" {
 		rset.Body = sr.Body
 	}
@@ -56,18 +102,54 @@ func LoadSavedRequest(rset *RequestSet) (*http.Request, error) {
 	return req, nil
 }
 
-// Converts lower-case:value to Title-Case:value and sets on header
-func mapLoadedValsToHeaderSlice(r *RequestSet, sMap map[string]string) {
-	if len(sMap) == 0 {
-		return
+// Decodes a node of requests.yaml into v, matching keys to fields ignoring case
+// (authtype and authType are both AuthType). A nil node leaves v as is.
+func decodeNode(n *yaml.Node, v interface{}) error {
+	if n == nil {
+		return nil
 	}
-	for k, v := range sMap {
-		r.HeaderSlice = append(r.HeaderSlice, toTitle(k)+":"+v)
+	var m interface{}
+	if err := n.Decode(&m); err != nil {
+		return config.Requests.Errorf(n, "%v", err)
+	}
+	d, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{Result: v, WeaklyTypedInput: true, DecodeHook: mapstructure.ComposeDecodeHookFunc(sessionNameHook, scopesHook)})
+	if err != nil {
+		return err
+	}
+	if err := d.Decode(m); err != nil {
+		return config.Requests.Errorf(n, "%v", err)
+	}
+	return nil
+}
+
+// Decodes session: mysite as a GroupSession of that name
+func sessionNameHook(from, to reflect.Type, v interface{}) (interface{}, error) {
+	if from.Kind() == reflect.String && to == reflect.TypeOf(GroupSession{}) {
+		return GroupSession{Name: v.(string)}, nil
+	}
+	return v, nil
+}
+
+// Uses the saved method when none was given, such as from 'brang run'.
+// Refuses to send a saved request with a method that conflicts with the saved one.
+func resolveMethod(rset *RequestSet, saved string) error {
+	saved = strings.ToUpper(saved)
+	switch {
+	case saved == "":
+	case rset.Method == "":
+		rset.Method = saved
+	case rset.Method != saved:
+		return fmt.Errorf("saved request %s is a %s request. refusing to send it as %s - use 'brang run %s' to send it with its saved method",
+			rset.URL, saved, rset.Method, rset.URL)
 	}
+	return nil
 }
 
-func toTitle(s string) string {
-	return cases.Title(language.Und, cases.NoLower).String(s)
+// Adds the saved header key:value pairs to the header slice, as written in requests.yaml
+func mapLoadedValsToHeaderSlice(r *RequestSet, sMap map[string]string) {
+	for k, v := range sMap {
+		r.HeaderSlice = append(r.HeaderSlice, k+":"+v)
+	}
 }
 
 // Gets correct cred value to assign to *RequestSet
@@ -76,7 +158,7 @@ func getCred(a *Auth) string {
 	switch a.AuthType {
 	case "Bearer", "Token":
 		s = checkEnv(a.Token)
-	case "Password", "Basic":
+	case "Password", "Basic", "Digest":
 		s = checkEnv(a.Username) + ":" + checkEnv(a.Password)
 	default:
 	}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"net/http"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/jerempy/brang/config"
@@ -37,7 +38,6 @@ func TestLoad(t *testing.T) {
 		"success password": {in: mockRSet("testspace2.users"), want: mockReq(), wantErr: nil},
 		"fail":             {in: mockRSet("not.a.real.request"), want: nil},
 	}
-	config.Requests.SetConfigType("yaml")
 	config.Requests.ReadConfig(bytes.NewBuffer(mockYml))
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -55,7 +55,7 @@ func TestMapLoadedValsToHeader(t *testing.T) {
 		"test2": "festroo",
 	}
 	mapLoadedValsToHeaderSlice(r, sMap)
-	if r.HeaderSlice[0] != "Test2:festroo" && r.HeaderSlice[0] != "Test:fest" {
+	if r.HeaderSlice[0] != "test2:festroo" && r.HeaderSlice[0] != "test:fest" {
 		t.Errorf("values didn't get mapped to header %v, %v", r.HeaderSlice[0], r.HeaderSlice[1])
 	}
 }
@@ -82,3 +82,63 @@ func TestCheckEnv(t *testing.T) {
 	}
 
 }
+
+func TestResolveMethod(t *testing.T) {
+	tests := map[string]struct {
+		given, saved string
+		want         string
+		wantErr      bool
+	}{
+		"use saved":     {given: "", saved: "post", want: "POST"},
+		"nothing saved": {given: "DELETE", saved: "", want: "DELETE"},
+		"same":          {given: "POST", saved: "POST", want: "POST"},
+		"conflict":      {given: "DELETE", saved: "GET", wantErr: true},
+		"neither given": {given: "", saved: "", want: ""},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := mockRSet("testspace.posts.1")
+			r.Method = tc.given
+			err := resolveMethod(r, tc.saved)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("got err %v - want err %v", err, tc.wantErr)
+			}
+			if err == nil && r.Method != tc.want {
+				t.Errorf("got %v - want %v", r.Method, tc.want)
+			}
+		})
+	}
+}
+
+func TestLoadKeepsCase(t *testing.T) {
+	config.Requests.ReadConfig(bytes.NewBufferString(`
+testspace:
+  requests:
+    getUserByID:
+      url: https://mysite.com/users/1
+      header:
+        X-API-Key: abc
+    "v1.2":
+      users: https://mysite.com/v1.2/users
+    broken:
+      - https://mysite.com
+`))
+	got, err := LoadSavedRequest(mockRSet("testspace.getUserByID"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.URL.String() != "https://mysite.com/users/1" || got.Header.Get("X-API-Key") != "abc" {
+		t.Errorf("got %v %v", got.URL, got.Header)
+	}
+	got, err = LoadSavedRequest(mockRSet(`testspace."v1.2".users`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.URL.String() != "https://mysite.com/v1.2/users" {
+		t.Errorf("got %v - want https://mysite.com/v1.2/users", got.URL)
+	}
+	_, err = LoadSavedRequest(mockRSet("testspace.broken"))
+	if err == nil || !strings.HasPrefix(err.Error(), "requests.yaml:11:") {
+		t.Errorf("got %v - want err starting with requests.yaml:11:", err)
+	}
+}
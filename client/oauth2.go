@@ -0,0 +1,390 @@
+package client
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jerempy/brang/config"
+)
+
+// a token this close to expiring is got again, so it doesn't expire on the way
+const tokenExpiryLeeway = 30 * time.Second
+
+// oauthToken is a token got from a tokenUrl, as cached between runs
+type oauthToken struct {
+	AccessToken  string    `json:"accessToken"`
+	RefreshToken string    `json:"refreshToken,omitempty"`
+	Expiry       time.Time `json:"expiry,omitempty"`
+}
+
+// valid is true when the token is there and not about to expire. A token without
+// an expiry is used until a request gets a 401
+func (t *oauthToken) valid() bool {
+	return t.AccessToken != "" && (t.Expiry.IsZero() || time.Until(t.Expiry) > tokenExpiryLeeway)
+}
+
+// Returns the value of the Authorization header for the token
+func (t *oauthToken) header() string {
+	return "Bearer " + t.AccessToken
+}
+
+// oauthSource gets the tokens of an OAuth2 auth: from its tokenUrl, caching them in the tokens
+// folder. It uses the refresh_token grant when there is a refresh token, else the grant of the auth.
+type oauthSource struct {
+	auth  Auth
+	group string
+	// key of the token in the cache, from the auth settings and environment
+	key    string
+	client *http.Client
+	// prints the login url without opening it in a browser
+	noBrowser bool
+}
+
+// Returns the token file of the group
+func tokenFile(group string) string {
+	return filepath.Join(config.TokensPath, group+".json")
+}
+
+// guards reading and writing token files, for requests sent at once by a Runner
+var tokenMu sync.Mutex
+
+// Returns the source of tokens for the OAuth2 auth a, with its $ENV and {{name}} values filled in
+func newOAuthSource(a Auth, group, env string, v Vars, c *http.Client) (*oauthSource, error) {
+	missing := map[string]bool{}
+	for _, f := range []*string{&a.TokenURL, &a.AuthURL, &a.DeviceURL, &a.RedirectURL, &a.ClientID, &a.ClientSecret, &a.Scopes, &a.Audience, &a.RefreshToken} {
+		*f = v.expand(checkEnv(*f), missing)
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("unresolved variables in OAuth2 auth of %s: %s", group, strings.Join(sortedKeys(missing), ", "))
+	}
+	if a.Grant == "" {
+		a.Grant = "client_credentials"
+	}
+	if a.TokenURL == "" {
+		return nil, fmt.Errorf("OAuth2 auth of %s needs a tokenUrl", group)
+	}
+	switch a.Grant {
+	case "client_credentials":
+		if a.ClientID == "" && a.RefreshToken == "" {
+			return nil, fmt.Errorf("OAuth2 auth of %s needs a clientId, or a refreshToken", group)
+		}
+	case "authorization_code":
+		if a.ClientID == "" || a.AuthURL == "" {
+			return nil, fmt.Errorf("OAuth2 auth of %s with grant authorization_code needs a clientId and an authUrl", group)
+		}
+		if _, _, ok := loopbackAddr(a.RedirectURL); !ok {
+			return nil, fmt.Errorf("redirectUrl of %s needs to be a loopback url with a port, ex: http://127.0.0.1:8400/callback", group)
+		}
+	case "device_code":
+		if a.ClientID == "" || a.DeviceURL == "" {
+			return nil, fmt.Errorf("OAuth2 auth of %s with grant device_code needs a clientId and a deviceUrl", group)
+		}
+	default:
+		return nil, fmt.Errorf("wrong OAuth2 grant of %s. accepts: client_credentials|authorization_code|device_code. was given: %s", group, a.Grant)
+	}
+	sum := sha256.Sum256([]byte(strings.Join([]string{env, a.Grant, a.TokenURL, a.ClientID, a.Scopes, a.Audience}, "\n")))
+	return &oauthSource{auth: a, group: group, key: hex.EncodeToString(sum[:8]), client: c}, nil
+}
+
+// Header returns the Authorization header value of a cached token, or of a new one when there is
+// none or it expired. stale is a header a request was refused with, so a token still cached
+// with it is got again. A token that was already got again by another request is used as is.
+// The authorization_code and device_code grants need the user, so without a token that can be
+// refreshed it errs asking to run 'brang auth login', rather than waiting for them.
+func (s *oauthSource) Header(stale string) (string, error) {
+	tokenMu.Lock()
+	defer tokenMu.Unlock()
+	tokens, err := loadTokens(s.group)
+	if err != nil {
+		return "", err
+	}
+	cached := tokens[s.key]
+	if cached != nil && cached.valid() && cached.header() != stale {
+		return cached.header(), nil
+	}
+	rt := s.auth.RefreshToken
+	if cached != nil && cached.RefreshToken != "" {
+		rt = cached.RefreshToken
+	}
+	var t *oauthToken
+	if rt != "" {
+		t, err = s.fetch(url.Values{"grant_type": {"refresh_token"}, "refresh_token": {rt}})
+		// a refresh token that was got, not set in the auth, can expire. a new one is got
+		// the way the first one was, when the client can
+		if err != nil && s.auth.RefreshToken == "" && (s.auth.Grant != "client_credentials" || s.auth.ClientSecret != "") {
+			t, err = nil, nil
+		}
+		if err == nil && t != nil && t.RefreshToken == "" {
+			t.RefreshToken = rt
+		}
+	}
+	if t == nil && err == nil {
+		if s.auth.Grant != "client_credentials" {
+			return "", fmt.Errorf("no OAuth2 token of %s that can be used or refreshed. run 'brang auth login %s'", s.group, s.group)
+		}
+		t, err = s.login()
+	}
+	if err != nil {
+		return "", err
+	}
+	return t.header(), s.save(tokens, t)
+}
+
+// Login gets a new token with the grant of the auth, even when one is cached, and caches it
+func (s *oauthSource) Login() error {
+	tokenMu.Lock()
+	defer tokenMu.Unlock()
+	tokens, err := loadTokens(s.group)
+	if err != nil {
+		return err
+	}
+	t, err := s.login()
+	if err != nil {
+		return err
+	}
+	return s.save(tokens, t)
+}
+
+func (s *oauthSource) save(tokens map[string]*oauthToken, t *oauthToken) error {
+	tokens[s.key] = t
+	if err := saveTokens(s.group, tokens); err != nil {
+		return fmt.Errorf("err saving OAuth2 token of %s: %w", s.group, err)
+	}
+	return nil
+}
+
+// Gets a token with the grant of the auth, the user logging in for authorization_code and device_code
+func (s *oauthSource) login() (*oauthToken, error) {
+	switch s.auth.Grant {
+	case "authorization_code":
+		return s.authCodeLogin()
+	case "device_code":
+		return s.deviceLogin()
+	}
+	return s.fetch(url.Values{"grant_type": {"client_credentials"}})
+}
+
+// oauthError is an error response of an OAuth2 server, with its error code, such as invalid_grant
+type oauthError struct {
+	status int
+	code   string
+	msg    string
+}
+
+func (e *oauthError) Error() string {
+	return e.msg
+}
+
+// Gets a token from the tokenUrl with the grant in form
+func (s *oauthSource) fetch(form url.Values) (*oauthToken, error) {
+	if g := form.Get("grant_type"); g == "client_credentials" || g == "refresh_token" {
+		if s.auth.Scopes != "" {
+			form.Set("scope", s.auth.Scopes)
+		}
+		if s.auth.Audience != "" {
+			form.Set("audience", s.auth.Audience)
+		}
+	}
+	var body struct {
+		AccessToken  string      `json:"access_token"`
+		RefreshToken string      `json:"refresh_token"`
+		ExpiresIn    json.Number `json:"expires_in"`
+	}
+	err := s.postClient(s.auth.TokenURL, form, &body)
+	if err == nil && body.AccessToken == "" {
+		err = fmt.Errorf("no access_token in the response of %s", s.auth.TokenURL)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("err getting OAuth2 token of %s with %s: %w", s.group, form.Get("grant_type"), err)
+	}
+	t := &oauthToken{AccessToken: body.AccessToken, RefreshToken: body.RefreshToken}
+	if secs, err := body.ExpiresIn.Int64(); err == nil && secs > 0 {
+		t.Expiry = time.Now().Add(time.Duration(secs) * time.Second)
+	}
+	return t, nil
+}
+
+// Posts form to endpoint as the client, reading the json response into v. A client with a secret
+// sends it with basic auth, and again in the form when the server refuses that. One without
+// a secret, as apps users log in to are, sends only its client_id in the form.
+func (s *oauthSource) postClient(endpoint string, form url.Values, v interface{}) error {
+	if s.auth.ClientSecret == "" {
+		if s.auth.ClientID != "" {
+			form.Set("client_id", s.auth.ClientID)
+		}
+		return s.post(endpoint, form, false, v)
+	}
+	err := s.post(endpoint, form, true, v)
+	var oe *oauthError
+	if errors.As(err, &oe) && (oe.status == http.StatusBadRequest || oe.status == http.StatusUnauthorized) {
+		form.Set("client_id", s.auth.ClientID)
+		form.Set("client_secret", s.auth.ClientSecret)
+		err = s.post(endpoint, form, false, v)
+	}
+	return err
+}
+
+func (s *oauthSource) post(endpoint string, form url.Values, basic bool, v interface{}) error {
+	req, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if basic {
+		req.SetBasicAuth(url.QueryEscape(s.auth.ClientID), url.QueryEscape(s.auth.ClientSecret))
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	var body struct {
+		Error       string `json:"error"`
+		Description string `json:"error_description"`
+	}
+	json.Unmarshal(b, &body)
+	switch {
+	case body.Error != "":
+		msg := body.Error
+		if body.Description != "" {
+			msg += ": " + body.Description
+		}
+		return &oauthError{resp.StatusCode, body.Error, resp.Status + " " + msg}
+	case resp.StatusCode >= 300:
+		return &oauthError{status: resp.StatusCode, msg: resp.Status}
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("err reading the response of %s: %w", endpoint, err)
+	}
+	return nil
+}
+
+func loadTokens(group string) (map[string]*oauthToken, error) {
+	tokens := map[string]*oauthToken{}
+	if err := checkStoreName("group", group); err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(tokenFile(group))
+	if errors.Is(err, fs.ErrNotExist) {
+		return tokens, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("err reading OAuth2 tokens of %s: %w", group, err)
+	}
+	if err := json.Unmarshal(b, &tokens); err != nil {
+		return nil, fmt.Errorf("err reading OAuth2 tokens of %s: %w", group, err)
+	}
+	return tokens, nil
+}
+
+func saveTokens(group string, tokens map[string]*oauthToken) error {
+	if err := checkStoreName("group", group); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(config.TokensPath, 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(tokenFile(group), b, 0600)
+}
+
+// oauthTransport gets a new token and sends the request again, once, when it gets a 401
+type oauthTransport struct {
+	src  *oauthSource
+	base http.RoundTripper
+}
+
+func (t *oauthTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(r)
+	sent := r.Header.Get("Authorization")
+	// a body that was read can't be sent again
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || sent == "" || r.Body != nil && r.Body != http.NoBody && r.GetBody == nil {
+		return resp, err
+	}
+	h, err := t.src.Header(sent)
+	if err != nil || h == sent {
+		return resp, nil
+	}
+	r2 := r.Clone(r.Context())
+	if r.GetBody != nil {
+		if r2.Body, err = r.GetBody(); err != nil {
+			return resp, nil
+		}
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	r2.Header.Set("Authorization", h)
+	return t.base.RoundTrip(r2)
+}
+
+// Returns a copy of c sending requests through the source's retry on 401, or c when src is nil
+func (c *brangClient) withOAuth(src *oauthSource) *brangClient {
+	if src == nil {
+		return c
+	}
+	hc := *c.Client
+	base := hc.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	hc.Transport = &oauthTransport{src, base}
+	return &brangClient{&hc}
+}
+
+// Uses the OAuth2 auth of the group for the request: the token is sent as its Authorization
+// header, and got again when the request gets a 401
+func (rset *RequestSet) useOAuth2(g *Group) error {
+	c, err := rset.ActiveTransport().Client(0)
+	if err != nil {
+		return err
+	}
+	src, err := newOAuthSource(g.Auth, g.Name, rset.Env, rset.vars, c.Client)
+	if err != nil {
+		return err
+	}
+	h, err := src.Header("")
+	if err != nil {
+		return err
+	}
+	rset.AuthType, rset.Cred, rset.oauth = "Bearer", strings.TrimPrefix(h, "Bearer "), src
+	return nil
+}
+
+// Lets scopes: of auth: be a list in requests.yaml, as well as space separated like OAuth2 sends them
+func scopesHook(from, to reflect.Type, v interface{}) (interface{}, error) {
+	m, ok := v.(map[string]interface{})
+	if !ok || to != reflect.TypeOf(Auth{}) {
+		return v, nil
+	}
+	for k, val := range m {
+		if l, ok := val.([]interface{}); ok && strings.EqualFold(k, "scopes") {
+			s := make([]string, len(l))
+			for i, sc := range l {
+				s[i] = fmt.Sprint(sc)
+			}
+			m[k] = strings.Join(s, " ")
+		}
+	}
+	return m, nil
+}
@@ -0,0 +1,309 @@
+package client
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jerempy/brang/config"
+)
+
+// a token server handing out tok-1, tok-2... and an api accepting only the last one
+func oauthServers(t *testing.T) (tokenSrv, api *httptest.Server, forms *[]map[string]string) {
+	var mu sync.Mutex
+	issued := 0
+	forms = &[]map[string]string{}
+	tokenSrv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		r.ParseForm()
+		f := map[string]string{}
+		for k := range r.PostForm {
+			f[k] = r.PostForm.Get(k)
+		}
+		if id, secret, ok := r.BasicAuth(); ok {
+			f["basic"] = id + ":" + secret
+		}
+		*forms = append(*forms, f)
+		if f["grant_type"] == "refresh_token" && f["refresh_token"] != fmt.Sprintf("rt-%d", issued) {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error": "invalid_grant"}`)
+			return
+		}
+		issued++
+		fmt.Fprintf(w, `{"access_token": "tok-%d", "token_type": "bearer", "expires_in": 3600, "refresh_token": "rt-%d"}`, issued, issued)
+	}))
+	api = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Header.Get("Authorization") != fmt.Sprintf("Bearer tok-%d", issued) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, "ok")
+	}))
+	t.Cleanup(tokenSrv.Close)
+	t.Cleanup(api.Close)
+	return tokenSrv, api, forms
+}
+
+func TestOAuth2ClientCredentials(t *testing.T) {
+	defer func(old string) { config.TokensPath = old }(config.TokensPath)
+	config.TokensPath = t.TempDir()
+	defer func(old string) { config.HistoryPath = old }(config.HistoryPath)
+	config.HistoryPath = t.TempDir()
+	tokenSrv, api, forms := oauthServers(t)
+	t.Setenv("TESTSPACE_SECRET", "s3cret")
+	config.Requests.ReadConfig(bytes.NewBufferString(fmt.Sprintf(`
+testspace:
+  baseUrl: %v
+  auth:
+    authType: OAuth2
+    tokenUrl: %v/token
+    clientId: app
+    clientSecret: $TESTSPACE_SECRET
+    scopes: [read, write]
+    audience: https://api
+  requests:
+    one: /one
+`, api.URL, tokenSrv.URL)))
+	for i := 0; i < 2; i++ {
+		req, err := LoadSavedRequest(mockRSet("testspace.one"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := req.Header.Get("Authorization"); got != "Bearer tok-1" {
+			t.Errorf("got %v - want the cached Bearer tok-1", got)
+		}
+	}
+	want := map[string]string{"grant_type": "client_credentials", "scope": "read write", "audience": "https://api", "basic": "app:s3cret"}
+	if len(*forms) != 1 || fmt.Sprint((*forms)[0]) != fmt.Sprint(want) {
+		t.Fatalf("token requests: got %v - want one of %v", *forms, want)
+	}
+
+	// the token is revoked: the api wants a new one, which the 401 gets with the refresh token
+	tokens, _ := loadTokens("testspace")
+	for _, tok := range tokens {
+		tok.AccessToken = "revoked"
+	}
+	saveTokens("testspace", tokens)
+	rn := &Runner{Base: mockRSet(""), Quiet: true}
+	if r := rn.Run([]string{"testspace.one"})[0]; r.Err != nil || r.Status != 200 {
+		t.Errorf("after getting a new token: got %v %v - want 200", r.Status, r.Err)
+	}
+	if len(*forms) != 2 || (*forms)[1]["grant_type"] != "refresh_token" || (*forms)[1]["refresh_token"] != "rt-1" {
+		t.Errorf("token requests: got %v - want a refresh_token grant of rt-1", *forms)
+	}
+}
+
+func TestOAuth2Expired(t *testing.T) {
+	defer func(old string) { config.TokensPath = old }(config.TokensPath)
+	config.TokensPath = t.TempDir()
+	tokenSrv, _, forms := oauthServers(t)
+	src, err := newOAuthSource(Auth{AuthType: "OAuth2", TokenURL: tokenSrv.URL, RefreshToken: "rt-0"}, "testspace", "", Vars{}, http.DefaultClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h, err := src.Header(""); err != nil || h != "Bearer tok-1" {
+		t.Fatalf("got %v %v - want Bearer tok-1", h, err)
+	}
+	tokens, _ := loadTokens("testspace")
+	tokens[src.key].Expiry = time.Now().Add(10 * time.Second)
+	saveTokens("testspace", tokens)
+	if h, err := src.Header(""); err != nil || h != "Bearer tok-2" {
+		t.Fatalf("of a token about to expire: got %v %v - want a new Bearer tok-2", h, err)
+	}
+	// the rotated refresh token is used, and a stale one that was already replaced isn't got again
+	if (*forms)[1]["refresh_token"] != "rt-1" {
+		t.Errorf("token requests: got %v - want the rotated refresh token rt-1", *forms)
+	}
+	if h, _ := src.Header("Bearer tok-1"); h != "Bearer tok-2" || len(*forms) != 2 {
+		t.Errorf("got %v after %v token requests - want the cached Bearer tok-2 after 2", h, len(*forms))
+	}
+}
+
+func TestOAuth2Errs(t *testing.T) {
+	tests := map[string]Auth{
+		"no tokenUrl":  {ClientID: "app"},
+		"no client":    {TokenURL: "https://auth/token"},
+		"missing var":  {TokenURL: "https://auth/token", ClientID: "{{nope_oauth_var}}"},
+		"missing env":  {TokenURL: "${NOPE_OAUTH_URL}", ClientID: "app"},
+		"no authUrl":   {Grant: "authorization_code", TokenURL: "https://auth/token", ClientID: "app"},
+		"no deviceUrl": {Grant: "device_code", TokenURL: "https://auth/token", ClientID: "app"},
+		"wrong grant":  {Grant: "password", TokenURL: "https://auth/token", ClientID: "app"},
+		"remote redirect": {Grant: "authorization_code", TokenURL: "https://auth/token", AuthURL: "https://auth/authorize",
+			ClientID: "app", RedirectURL: "https://mysite.com/callback"},
+	}
+	for name, a := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := newOAuthSource(a, "testspace", "", Vars{}, http.DefaultClient); err == nil {
+				t.Errorf("%v: should err", name)
+			}
+		})
+	}
+}
+
+// an OAuth2 server users log in to: /authorize redirects back with a code, unless refuse is set,
+// and /device gives a code the user enters at /activate, polled at /token
+func loginServer(t *testing.T, refuse bool) (srv *httptest.Server, forms *[]map[string]string) {
+	var mu sync.Mutex
+	var challenge, redirect string
+	activated, polls := false, 0
+	forms = &[]map[string]string{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		q := r.URL.Query()
+		challenge, redirect = q.Get("code_challenge"), q.Get("redirect_uri")
+		back := url.Values{"state": {q.Get("state")}, "code": {"code-1"}}
+		if refuse || q.Get("code_challenge_method") != "S256" || q.Get("client_id") != "app" || q.Get("scope") != "read" {
+			back = url.Values{"state": {q.Get("state")}, "error": {"access_denied"}}
+		}
+		http.Redirect(w, r, redirect+"?"+back.Encode(), http.StatusFound)
+	})
+	mux.HandleFunc("/device", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"device_code": "dev-1", "user_code": "ABCD-EFGH", "verification_uri": "http://%s/activate", "interval": 1, "expires_in": 60}`, r.Host)
+	})
+	mux.HandleFunc("/activate", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		activated = true
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		r.ParseForm()
+		f := map[string]string{}
+		for k := range r.PostForm {
+			f[k] = r.PostForm.Get(k)
+		}
+		*forms = append(*forms, f)
+		sum := sha256.Sum256([]byte(f["code_verifier"]))
+		switch f["grant_type"] {
+		case "authorization_code":
+			if f["code"] != "code-1" || f["redirect_uri"] != redirect || base64.RawURLEncoding.EncodeToString(sum[:]) != challenge {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"error": "invalid_grant"}`)
+				return
+			}
+		case "urn:ietf:params:oauth:grant-type:device_code":
+			polls++
+			if polls < 3 || !activated {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, map[bool]string{true: `{"error": "authorization_pending"}`, false: `{"error": "slow_down"}`}[polls != 2])
+				return
+			}
+		}
+		fmt.Fprint(w, `{"access_token": "user-tok", "expires_in": 3600, "refresh_token": "user-rt"}`)
+	})
+	srv = httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv, forms
+}
+
+func TestOAuth2Login(t *testing.T) {
+	defer func(old string) { config.TokensPath = old }(config.TokensPath)
+	config.TokensPath = t.TempDir()
+	defer func(old string) { config.HistoryPath = old }(config.HistoryPath)
+	config.HistoryPath = t.TempDir()
+	defer func(old io.Writer) { loginOut = old }(loginOut)
+	loginOut = io.Discard
+	defer func(old time.Duration) { devicePollUnit = old }(devicePollUnit)
+	devicePollUnit = time.Millisecond
+	defer func(old func(string) error) { openBrowser = old }(openBrowser)
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.Header.Get("Authorization"))
+	}))
+	defer api.Close()
+
+	tests := map[string]struct {
+		grant, want string
+		refuse      bool
+		grantForm   map[string]string
+	}{
+		"authorization code": {grant: "authorization_code", want: "Bearer user-tok",
+			grantForm: map[string]string{"grant_type": "authorization_code", "client_id": "app", "code": "code-1"}},
+		"device code": {grant: "device_code", want: "Bearer user-tok",
+			grantForm: map[string]string{"grant_type": "urn:ietf:params:oauth:grant-type:device_code", "client_id": "app", "device_code": "dev-1"}},
+		"refused": {grant: "authorization_code", refuse: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			config.TokensPath = t.TempDir()
+			srv, forms := loginServer(t, tc.refuse)
+			config.Requests.ReadConfig(bytes.NewBufferString(fmt.Sprintf(`
+testspace:
+  baseUrl: %v
+  auth:
+    authType: OAuth2
+    grant: %v
+    authUrl: %v/authorize
+    deviceUrl: %v/device
+    tokenUrl: %v/token
+    clientId: app
+    scopes: [read]
+  requests:
+    one: /one
+`, api.URL, tc.grant, srv.URL, srv.URL, srv.URL)))
+			// the user, opening the url in their browser
+			openBrowser = func(u string) error {
+				go func() {
+					if resp, err := http.Get(u); err == nil {
+						resp.Body.Close()
+					}
+				}()
+				return nil
+			}
+			err := Login(mockRSet(""), "testspace", false)
+			if tc.refuse {
+				if err == nil {
+					t.Error("should err when the login is refused")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			last := (*forms)[len(*forms)-1]
+			for k, v := range tc.grantForm {
+				if last[k] != v {
+					t.Errorf("token request %s: got %v - want %v", k, last[k], v)
+				}
+			}
+
+			// requests use the token got, without logging in again
+			openBrowser = func(u string) error {
+				t.Errorf("got a login opening %s - want none", u)
+				return nil
+			}
+			rset := mockRSet("testspace.one")
+			req, err := LoadSavedRequest(rset)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := req.Header.Get("Authorization"); got != tc.want {
+				t.Errorf("got %v - want %v", got, tc.want)
+			}
+			if err := Logout("testspace"); err != nil {
+				t.Fatal(err)
+			}
+			if tokens, _ := loadTokens("testspace"); len(tokens) != 0 {
+				t.Errorf("after logging out: got %v - want no tokens", tokens)
+			}
+			// without a token, requests ask to log in rather than opening the browser
+			if _, err := LoadSavedRequest(mockRSet("testspace.one")); err == nil || !strings.Contains(err.Error(), "brang auth login testspace") {
+				t.Errorf("got err %v - want one asking to run 'brang auth login testspace'", err)
+			}
+		})
+	}
+}
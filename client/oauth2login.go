@@ -0,0 +1,287 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+
+	"github.com/jerempy/brang/config"
+)
+
+// how long brang waits for the user to log in, when the server doesn't say
+var loginTimeout = 5 * time.Minute
+
+// unit of the interval device logins are polled at, which servers give in seconds
+var devicePollUnit = time.Second
+
+// where login prompts are written, so they don't mix with responses on stdout
+var loginOut io.Writer = os.Stderr
+
+// opens the url in the browser of the user
+var openBrowser = func(u string) error {
+	var c *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		c = exec.Command("rundll32", "url.dll,FileProtocolHandler", u)
+	case "darwin":
+		c = exec.Command("open", u)
+	default:
+		c = exec.Command("xdg-open", u)
+	}
+	return c.Start()
+}
+
+// Returns n random bytes as url safe base64, for PKCE verifiers and states
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Returns the address and path the redirectUrl of an auth is received at, which needs to be
+// a loopback url with a port. Without one it is a free port of 127.0.0.1, at /callback.
+func loopbackAddr(redirect string) (addr, path string, ok bool) {
+	if redirect == "" {
+		return "127.0.0.1:0", "/callback", true
+	}
+	u, err := url.Parse(redirect)
+	if err != nil || u.Scheme != "http" || u.Port() == "" || u.Hostname() != "localhost" && !net.ParseIP(u.Hostname()).IsLoopback() {
+		return "", "", false
+	}
+	if path = u.Path; path == "" {
+		path = "/"
+	}
+	return u.Host, path, true
+}
+
+// Gets a token with the authorization_code grant and PKCE. A loopback server is started to
+// receive the redirect of the authUrl, which is opened in a browser for the user to log in.
+func (s *oauthSource) authCodeLogin() (*oauthToken, error) {
+	addr, path, _ := loopbackAddr(s.auth.RedirectURL)
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("err starting the OAuth2 login of %s: %w", s.group, err)
+	}
+	redirect := s.auth.RedirectURL
+	if redirect == "" {
+		redirect = "http://" + ln.Addr().String() + path
+	}
+	verifier, err := randomString(32)
+	if err != nil {
+		return nil, err
+	}
+	state, err := randomString(16)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	authURL, err := url.Parse(s.auth.AuthURL)
+	if err != nil {
+		return nil, fmt.Errorf("authUrl of %s: %w", s.group, err)
+	}
+	q := authURL.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", s.auth.ClientID)
+	q.Set("redirect_uri", redirect)
+	q.Set("state", state)
+	q.Set("code_challenge", base64.RawURLEncoding.EncodeToString(sum[:]))
+	q.Set("code_challenge_method", "S256")
+	if s.auth.Scopes != "" {
+		q.Set("scope", s.auth.Scopes)
+	}
+	if s.auth.Audience != "" {
+		q.Set("audience", s.auth.Audience)
+	}
+	authURL.RawQuery = q.Encode()
+
+	type result struct {
+		code string
+		err  error
+	}
+	done := make(chan result, 1)
+	srv := &http.Server{ReadHeaderTimeout: 10 * time.Second, Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != path {
+			http.NotFound(w, r)
+			return
+		}
+		q := r.URL.Query()
+		var res result
+		switch {
+		case q.Get("state") != state:
+			res.err = fmt.Errorf("the login redirect has the wrong state")
+		case q.Get("error") != "":
+			res.err = fmt.Errorf("login refused: %s %s", q.Get("error"), q.Get("error_description"))
+		case q.Get("code") == "":
+			res.err = fmt.Errorf("the login redirect has no code")
+		default:
+			res.code = q.Get("code")
+		}
+		if res.err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "brang: %v\n", res.err)
+		} else {
+			fmt.Fprintf(w, "brang: logged in to %s. You can close this page.\n", s.group)
+		}
+		select {
+		case done <- res:
+		default:
+		}
+	})}
+	go srv.Serve(ln)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+	}()
+	s.prompt(fmt.Sprintf("To log in to %s, open:\n%s\n", s.group, authURL), authURL.String())
+
+	var res result
+	select {
+	case res = <-done:
+	case <-time.After(loginTimeout):
+		return nil, fmt.Errorf("OAuth2 login of %s timed out after %v", s.group, loginTimeout)
+	}
+	if res.err != nil {
+		return nil, fmt.Errorf("err in the OAuth2 login of %s: %w", s.group, res.err)
+	}
+	return s.fetch(url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {res.code},
+		"redirect_uri":  {redirect},
+		"code_verifier": {verifier},
+	})
+}
+
+// Gets a token with the device authorization grant: the user enters a code at the url the
+// deviceUrl gives, on any device, while the tokenUrl is polled until they did.
+func (s *oauthSource) deviceLogin() (*oauthToken, error) {
+	form := url.Values{}
+	if s.auth.Scopes != "" {
+		form.Set("scope", s.auth.Scopes)
+	}
+	if s.auth.Audience != "" {
+		form.Set("audience", s.auth.Audience)
+	}
+	var d struct {
+		DeviceCode              string `json:"device_code"`
+		UserCode                string `json:"user_code"`
+		VerificationURI         string `json:"verification_uri"`
+		VerificationURIComplete string `json:"verification_uri_complete"`
+		ExpiresIn               int    `json:"expires_in"`
+		Interval                int    `json:"interval"`
+	}
+	err := s.postClient(s.auth.DeviceURL, form, &d)
+	if err == nil && (d.DeviceCode == "" || d.VerificationURI == "") {
+		err = fmt.Errorf("no device_code or verification_uri in the response of %s", s.auth.DeviceURL)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("err starting the OAuth2 device login of %s: %w", s.group, err)
+	}
+	open := d.VerificationURIComplete
+	if open == "" {
+		open = d.VerificationURI
+	}
+	s.prompt(fmt.Sprintf("To log in to %s, open %s and enter the code %s\n", s.group, d.VerificationURI, d.UserCode), open)
+
+	interval, timeout := 5, loginTimeout
+	if d.Interval > 0 {
+		interval = d.Interval
+	}
+	if d.ExpiresIn > 0 {
+		timeout = time.Duration(d.ExpiresIn) * time.Second
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		time.Sleep(time.Duration(interval) * devicePollUnit)
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("OAuth2 device login of %s expired before it was done", s.group)
+		}
+		t, err := s.fetch(url.Values{"grant_type": {"urn:ietf:params:oauth:grant-type:device_code"}, "device_code": {d.DeviceCode}})
+		var oe *oauthError
+		if errors.As(err, &oe) {
+			switch oe.code {
+			case "authorization_pending":
+				continue
+			case "slow_down":
+				interval += 5
+				continue
+			}
+		}
+		return t, err
+	}
+}
+
+// Writes msg for the user to log in, and opens u in their browser
+func (s *oauthSource) prompt(msg, u string) {
+	fmt.Fprint(loginOut, msg)
+	if !s.noBrowser {
+		openBrowser(u)
+	}
+	fmt.Fprintln(loginOut, "Waiting for the login...")
+}
+
+// Login gets a new OAuth2 token for the group in the environment rset.Env, even when one is
+// cached, and caches it for the group's requests. With the authorization_code and device_code
+// grants the user logs in, with the url opened in their browser unless noBrowser.
+func Login(rset *RequestSet, group string, noBrowser bool) error {
+	if err := config.LoadRequests(); err != nil {
+		return err
+	}
+	g, err := loadGroup(group)
+	if err != nil {
+		return err
+	}
+	if rset.Env, err = g.envName(rset.Env); err != nil {
+		return err
+	}
+	g.useEnv(rset.Env)
+	if g.Auth.AuthType != "OAuth2" {
+		return fmt.Errorf("%s has no OAuth2 auth to log in with", group)
+	}
+	rset.vars = g.Vars
+	state, err := LoadState(g.Name)
+	if err != nil {
+		return err
+	}
+	for k, v := range state {
+		rset.vars[k] = v
+	}
+	rset.groupTransport = g.Transport
+	c, err := rset.ActiveTransport().Client(0)
+	if err != nil {
+		return err
+	}
+	src, err := newOAuthSource(g.Auth, g.Name, rset.Env, rset.vars, c.Client)
+	if err != nil {
+		return err
+	}
+	src.noBrowser = noBrowser
+	return src.Login()
+}
+
+// Logout removes the cached OAuth2 tokens of the group, in all its environments
+func Logout(group string) error {
+	if err := checkStoreName("group", group); err != nil {
+		return err
+	}
+	tokenMu.Lock()
+	defer tokenMu.Unlock()
+	if err := os.Remove(tokenFile(group)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("err removing OAuth2 tokens of %s: %w", group, err)
+	}
+	return nil
+}
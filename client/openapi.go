@@ -0,0 +1,513 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// methods of a path item, in the order operations are imported
+var specMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+var (
+	// {name} of a path template or server url
+	pathParam = regexp.MustCompile(`\{([^{}/]+)\}`)
+	// chars that can't be in the key of a saved request, which is split on .
+	notOperationChars = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+)
+
+// openAPISpec is an OpenAPI 3 or Swagger 2 spec, read as plain maps to follow its $refs
+type openAPISpec struct {
+	root    map[string]interface{}
+	swagger bool
+	g       *ImportedGroup
+}
+
+// ParseOpenAPI reads an OpenAPI 3 or Swagger 2 spec, in yaml or json, into a group named
+// group, or named after the spec's title when group is empty. Each operation becomes a
+// saved request named by its operationId, or method and path. Path params become {{vars}},
+// bodies are made from the examples of the spec, and the security schemes become the
+// group's auth, with creds as $ENV references.
+func ParseOpenAPI(data []byte, group string) (*ImportedGroup, error) {
+	var n yaml.Node
+	if err := yaml.Unmarshal(data, &n); err != nil {
+		return nil, fmt.Errorf("err reading spec: %w", err)
+	}
+	plainSpecNode(&n)
+	var root map[string]interface{}
+	if err := n.Decode(&root); err != nil {
+		return nil, fmt.Errorf("err reading spec: %w", err)
+	}
+	s := &openAPISpec{root: root}
+	switch {
+	case strings.HasPrefix(specString(root, "openapi"), "3."):
+	case specString(root, "swagger") == "2.0":
+		s.swagger = true
+	default:
+		return nil, fmt.Errorf("not an OpenAPI 3 or Swagger 2 spec. it needs openapi: 3.x or swagger: \"2.0\"")
+	}
+	if group == "" {
+		group = importKey(specString(specMap(root, "info"), "title"), map[string]bool{})
+	}
+	s.g = newImportedGroup(group)
+	base := s.baseURL()
+	if isHttp(base) {
+		s.g.BaseURL = base
+		base = ""
+	} else {
+		s.g.note("the spec has no server with a full url. set baseUrl: of %s to send its requests", group)
+	}
+	s.g.Auth = s.auth(root["security"], "the spec")
+
+	paths := specMap(root, "paths")
+	used := map[string]bool{}
+	for _, p := range sortedKeys(paths) {
+		item := s.resolve(paths[p])
+		for _, m := range specMethods {
+			op := specMap(item, m)
+			if op == nil {
+				continue
+			}
+			key := operationKey(specString(op, "operationId"), m, p, used)
+			sr := s.request(key, m, base+p, item, op)
+			// operations without security of their own use the spec's
+			var auth *Auth
+			if sec, ok := op["security"]; ok {
+				if auth = s.auth(sec, key); len(specList(op, "security")) == 0 && s.g.Auth != nil {
+					s.g.note("%s: needs no auth but is sent with the auth of %s", key, group)
+				}
+			}
+			s.g.addRequest([]string{key}, sr, auth)
+		}
+	}
+	if len(s.g.Requests) == 0 {
+		return nil, fmt.Errorf("no operations found in the spec")
+	}
+	s.g.finish()
+	return s.g, nil
+}
+
+// Makes a decoded spec plain: timestamps stay strings, as they are in examples, and
+// map keys such as status codes are strings so examples can be written as json
+func plainSpecNode(n *yaml.Node) {
+	if n.Tag == "!!timestamp" {
+		n.Tag = "!!str"
+	}
+	for i, c := range n.Content {
+		if n.Kind == yaml.MappingNode && i%2 == 0 && c.Kind == yaml.ScalarNode {
+			c.Tag = "!!str"
+		}
+		plainSpecNode(c)
+	}
+}
+
+func specMap(m map[string]interface{}, key string) map[string]interface{} {
+	v, _ := m[key].(map[string]interface{})
+	return v
+}
+
+func specList(m map[string]interface{}, key string) []interface{} {
+	v, _ := m[key].([]interface{})
+	return v
+}
+
+func specString(m map[string]interface{}, key string) string {
+	v, _ := m[key].(string)
+	return v
+}
+
+// Follows $ref to a part of the same spec, such as #/components/schemas/User. Refs to
+// other files can't be followed, and give nil.
+func (s *openAPISpec) resolve(v interface{}) map[string]interface{} {
+	m, _ := v.(map[string]interface{})
+	for i := 0; i < 32 && m != nil; i++ {
+		ref, ok := m["$ref"].(string)
+		if !ok {
+			return m
+		}
+		if !strings.HasPrefix(ref, "#/") {
+			s.g.note("$ref %s to another file isn't followed", ref)
+			return nil
+		}
+		var cur interface{} = s.root
+		for _, part := range strings.Split(ref[2:], "/") {
+			part = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+			cm, _ := cur.(map[string]interface{})
+			cur = cm[part]
+		}
+		m, _ = cur.(map[string]interface{})
+	}
+	return m
+}
+
+// Returns the url of the first server, with its variables set to their defaults
+func (s *openAPISpec) baseURL() string {
+	if s.swagger {
+		host := specString(s.root, "host")
+		if host == "" {
+			return specString(s.root, "basePath")
+		}
+		scheme := "https"
+		if schemes := specList(s.root, "schemes"); len(schemes) > 0 {
+			scheme, _ = schemes[0].(string)
+		}
+		return scheme + "://" + host + strings.TrimRight(specString(s.root, "basePath"), "/")
+	}
+	servers := specList(s.root, "servers")
+	if len(servers) == 0 {
+		return ""
+	}
+	server, _ := servers[0].(map[string]interface{})
+	vars := specMap(server, "variables")
+	u := pathParam.ReplaceAllStringFunc(specString(server, "url"), func(m string) string {
+		if d := specMap(vars, m[1:len(m)-1])["default"]; d != nil {
+			return fmt.Sprint(d)
+		}
+		return m
+	})
+	return strings.TrimRight(u, "/")
+}
+
+// Names an operation by its operationId, or its method and path like get-users-id
+func operationKey(id, method, path string, used map[string]bool) string {
+	k := strings.Trim(notOperationChars.ReplaceAllString(id, "-"), "-")
+	if k == "" {
+		return importKey(method+" "+path, used)
+	}
+	key := k
+	for i := 2; used[key]; i++ {
+		key = fmt.Sprintf("%s-%d", k, i)
+	}
+	used[key] = true
+	return key
+}
+
+// Returns the auth of the first security requirement the group can use. Creds are
+// $ENV references, as a spec has none.
+func (s *openAPISpec) auth(security interface{}, name string) *Auth {
+	reqs, _ := security.([]interface{})
+	schemes := specMap(specMap(s.root, "components"), "securitySchemes")
+	if s.swagger {
+		schemes = specMap(s.root, "securityDefinitions")
+	}
+	for _, r := range reqs {
+		req, _ := r.(map[string]interface{})
+		for _, schemeName := range sortedKeys(req) {
+			scheme := s.resolve(schemes[schemeName])
+			switch t := specString(scheme, "type"); {
+			case t == "http" && strings.EqualFold(specString(scheme, "scheme"), "bearer"):
+				return &Auth{AuthType: "Bearer", Token: "$" + s.env("TOKEN")}
+			case t == "http" && strings.EqualFold(specString(scheme, "scheme"), "basic"), t == "basic":
+				return &Auth{AuthType: "Password", Username: "$" + s.env("USERNAME"), Password: "$" + s.env("PASSWORD")}
+			case t == "oauth2", t == "openIdConnect":
+				s.g.note("%s: %s %s is sent as Bearer auth. get a token and set it as %s", name, t, schemeName, envName(s.g.Name, "TOKEN"))
+				return &Auth{AuthType: "Bearer", Token: "$" + s.env("TOKEN")}
+			case t == "apiKey":
+				// added to the requests by apiKeys
+			default:
+				s.g.note("%s: %s security %s isn't supported and isn't imported", name, t, schemeName)
+			}
+		}
+	}
+	return nil
+}
+
+// Returns the env variable of the group for key, such as MYSITE_TOKEN
+func (s *openAPISpec) env(key string) string {
+	env := envName(s.g.Name, key)
+	s.g.addEnv(env)
+	return env
+}
+
+// Adds the apiKey security schemes of the operation, or of the spec, to the request as
+// headers or query params of ${ENV} references
+func (s *openAPISpec) apiKeys(name string, op map[string]interface{}, sr *SavedRequestSet) {
+	security, ok := op["security"]
+	if !ok {
+		security = s.root["security"]
+	}
+	reqs, _ := security.([]interface{})
+	if len(reqs) == 0 {
+		return
+	}
+	schemes := specMap(specMap(s.root, "components"), "securitySchemes")
+	if s.swagger {
+		schemes = specMap(s.root, "securityDefinitions")
+	}
+	req, _ := reqs[0].(map[string]interface{})
+	for _, schemeName := range sortedKeys(req) {
+		scheme := s.resolve(schemes[schemeName])
+		if specString(scheme, "type") != "apiKey" {
+			continue
+		}
+		key := specString(scheme, "name")
+		switch specString(scheme, "in") {
+		case "header":
+			if sr.Header == nil {
+				sr.Header = map[string]string{}
+			}
+			sr.Header[key] = s.g.envRef(key)
+		case "query":
+			sr.URL = addQuery(sr.URL, formEncode([][2]string{{key, s.g.envRef(key)}}))
+		default:
+			s.g.note("%s: api key %s in a cookie isn't imported", name, key)
+		}
+	}
+}
+
+// Returns the parameters of the operation, with those of its path item it doesn't override
+func (s *openAPISpec) params(item, op map[string]interface{}) []map[string]interface{} {
+	var params []map[string]interface{}
+	seen := map[string]bool{}
+	for _, l := range [][]interface{}{specList(op, "parameters"), specList(item, "parameters")} {
+		for _, p := range l {
+			pm := s.resolve(p)
+			id := specString(pm, "in") + ":" + specString(pm, "name")
+			if pm == nil || seen[id] {
+				continue
+			}
+			seen[id] = true
+			params = append(params, pm)
+		}
+	}
+	return params
+}
+
+// Returns the example of a parameter, from its example or schema, and if it has one
+func (s *openAPISpec) paramExample(p map[string]interface{}) (string, bool) {
+	var v interface{}
+	switch {
+	case p["example"] != nil:
+		v = p["example"]
+	case len(specMap(p, "examples")) > 0:
+		ex := specMap(p, "examples")
+		v = s.resolve(ex[sortedKeys(ex)[0]])["value"]
+	case p["default"] != nil:
+		v = p["default"]
+	default:
+		schema := s.resolve(p["schema"])
+		for _, k := range []string{"example", "default"} {
+			if schema[k] != nil {
+				v = schema[k]
+				break
+			}
+		}
+		if enum := specList(schema, "enum"); v == nil && len(enum) > 0 {
+			v = enum[0]
+		}
+	}
+	if v == nil {
+		return "", false
+	}
+	return fmt.Sprint(v), true
+}
+
+func (s *openAPISpec) request(name, method, path string, item, op map[string]interface{}) SavedRequestSet {
+	sr := SavedRequestSet{URL: pathParam.ReplaceAllString(path, "{{$1}}")}
+	if m := strings.ToUpper(method); m != "GET" {
+		sr.Method = m
+	}
+	var form [][2]string
+	var bodySchema interface{}
+	for _, p := range s.params(item, op) {
+		pname, in := specString(p, "name"), specString(p, "in")
+		required, _ := p["required"].(bool)
+		ex, hasEx := s.paramExample(p)
+		if hasEx && (in == "path" || required && (in == "query" || in == "header")) {
+			if _, ok := s.g.Vars[pname]; !ok {
+				s.g.addVar(s.g.Vars, pname, ex)
+			}
+		}
+		switch {
+		case in == "body":
+			bodySchema = p["schema"]
+		case in == "formData":
+			if specString(p, "type") == "file" {
+				s.g.note("%s: file field %s of the form isn't imported", name, pname)
+			} else if required || hasEx {
+				form = append(form, [2]string{pname, ex})
+			}
+		case !required:
+		case in == "query":
+			sr.URL = addQuery(sr.URL, pname+"={{"+pname+"}}")
+		case in == "header":
+			if sr.Header == nil {
+				sr.Header = map[string]string{}
+			}
+			sr.Header[pname] = "{{" + pname + "}}"
+		}
+	}
+	if s.swagger {
+		consumes := specList(op, "consumes")
+		if len(consumes) == 0 {
+			consumes = specList(s.root, "consumes")
+		}
+		switch {
+		case bodySchema != nil:
+			s.body(name, &sr, "application/json", map[string]interface{}{"schema": bodySchema})
+		case len(form) > 0 && len(consumes) > 0 && consumes[0] == "multipart/form-data":
+			s.formBody(&sr, "multipart/form-data", form)
+		case len(form) > 0:
+			s.formBody(&sr, "application/x-www-form-urlencoded", form)
+		}
+	} else if rb := s.resolve(op["requestBody"]); rb != nil {
+		content := specMap(rb, "content")
+		types := sortedKeys(content)
+		sort.SliceStable(types, func(i, j int) bool { return mediaRank(types[i]) < mediaRank(types[j]) })
+		if len(types) > 0 {
+			s.body(name, &sr, types[0], s.resolve(content[types[0]]))
+		}
+	}
+	s.apiKeys(name, op, &sr)
+	return sr
+}
+
+// Ranks media types of a request body by how well brang can send them, json first
+func mediaRank(t string) int {
+	switch {
+	case t == "application/json":
+		return 0
+	case strings.HasSuffix(t, "+json") || strings.Contains(t, "json"):
+		return 1
+	case t == "application/x-www-form-urlencoded":
+		return 2
+	case t == "multipart/form-data":
+		return 3
+	case strings.HasPrefix(t, "text/"), strings.HasSuffix(t, "xml"):
+		return 4
+	}
+	return 5
+}
+
+// Sets the body of the request from the example of the media type, or one made from its schema
+func (s *openAPISpec) body(name string, sr *SavedRequestSet, mediaType string, media map[string]interface{}) {
+	ex := media["example"]
+	if examples := specMap(media, "examples"); ex == nil && len(examples) > 0 {
+		ex = s.resolve(examples[sortedKeys(examples)[0]])["value"]
+	}
+	if ex == nil {
+		ex = s.example(media["schema"], 0)
+	}
+	switch {
+	case strings.Contains(mediaType, "json"):
+		b, err := json.MarshalIndent(ex, "", "  ")
+		if err != nil || ex == nil {
+			return
+		}
+		sr.Body = string(b)
+		if mediaType != "application/json" {
+			setHeader(sr, "Content-Type", mediaType)
+		}
+	case mediaType == "application/x-www-form-urlencoded" || mediaType == "multipart/form-data":
+		obj, _ := ex.(map[string]interface{})
+		var form [][2]string
+		for _, k := range sortedKeys(obj) {
+			form = append(form, [2]string{k, fmt.Sprint(obj[k])})
+		}
+		s.formBody(sr, mediaType, form)
+	default:
+		str, ok := ex.(string)
+		if !ok {
+			s.g.note("%s: no example body for %s", name, mediaType)
+			return
+		}
+		sr.Body = str
+		setHeader(sr, "Content-Type", mediaType)
+	}
+}
+
+func (s *openAPISpec) formBody(sr *SavedRequestSet, mediaType string, form [][2]string) {
+	if mediaType == "multipart/form-data" {
+		body, ct, err := multipartBody(form)
+		if err != nil {
+			return
+		}
+		sr.Body = body
+		setHeader(sr, "Content-Type", ct)
+		return
+	}
+	sr.Body = formEncode(form)
+	setHeader(sr, "Content-Type", mediaType)
+}
+
+func setHeader(sr *SavedRequestSet, k, v string) {
+	if sr.Header == nil {
+		sr.Header = map[string]string{}
+	}
+	sr.Header[k] = v
+}
+
+// Makes an example value of a schema, from its example and default, or else from its type
+func (s *openAPISpec) example(v interface{}, depth int) interface{} {
+	schema := s.resolve(v)
+	if schema == nil || depth > 8 {
+		return nil
+	}
+	for _, k := range []string{"example", "default"} {
+		if ex, ok := schema[k]; ok {
+			return ex
+		}
+	}
+	if enum := specList(schema, "enum"); len(enum) > 0 {
+		return enum[0]
+	}
+	if all := specList(schema, "allOf"); len(all) > 0 {
+		obj := map[string]interface{}{}
+		for _, sub := range all {
+			if m, ok := s.example(sub, depth+1).(map[string]interface{}); ok {
+				for k, v := range m {
+					obj[k] = v
+				}
+			}
+		}
+		return obj
+	}
+	for _, k := range []string{"oneOf", "anyOf"} {
+		if l := specList(schema, k); len(l) > 0 {
+			return s.example(l[0], depth+1)
+		}
+	}
+	t := specString(schema, "type")
+	if t == "" && schema["properties"] != nil {
+		t = "object"
+	}
+	switch t {
+	case "object":
+		obj := map[string]interface{}{}
+		props := specMap(schema, "properties")
+		for _, k := range sortedKeys(props) {
+			if ro, _ := s.resolve(props[k])["readOnly"].(bool); !ro {
+				obj[k] = s.example(props[k], depth+1)
+			}
+		}
+		return obj
+	case "array":
+		if item := s.example(schema["items"], depth+1); item != nil {
+			return []interface{}{item}
+		}
+		return []interface{}{}
+	case "integer", "number":
+		return 0
+	case "boolean":
+		return false
+	case "string":
+		switch specString(schema, "format") {
+		case "date-time":
+			return "2024-01-01T00:00:00Z"
+		case "date":
+			return "2024-01-01"
+		case "email":
+			return "user@example.com"
+		case "uuid":
+			return "00000000-0000-0000-0000-000000000000"
+		case "uri", "url":
+			return "https://example.com"
+		}
+		return "string"
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package client
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testOpenAPISpec = `
+openapi: 3.0.3
+info: {title: Payments API, version: "1.0"}
+servers:
+  - url: https://{region}.pay.example.com/v1
+    variables: {region: {default: eu}}
+security:
+  - bearerAuth: []
+paths:
+  /payments:
+    get:
+      operationId: listPayments
+      parameters:
+        - {name: limit, in: query, schema: {type: integer, default: 20}}
+        - {name: status, in: query, required: true, schema: {type: string, enum: [paid, open]}}
+    post:
+      operationId: create.payment
+      parameters:
+        - {name: Idempotency-Key, in: header, required: true, schema: {type: string}}
+      requestBody:
+        content:
+          text/plain: {example: hi}
+          application/json:
+            schema: {$ref: '#/components/schemas/NewPayment'}
+  /payments/{paymentId}:
+    parameters:
+      - {name: paymentId, in: path, required: true, example: pay_123, schema: {type: string}}
+    get:
+      operationId: getPayment
+    delete:
+      security: []
+  /refunds:
+    post:
+      security: [{apiKey: []}]
+      requestBody:
+        content:
+          application/x-www-form-urlencoded:
+            schema:
+              type: object
+              properties: {payment: {type: string, example: pay_1}, amount: {type: integer}}
+components:
+  securitySchemes:
+    bearerAuth: {type: http, scheme: bearer}
+    apiKey: {type: apiKey, in: header, name: X-Api-Key}
+  schemas:
+    NewPayment:
+      type: object
+      properties:
+        id: {type: string, readOnly: true}
+        amount: {type: integer, example: 1000}
+        currency: {type: string, default: EUR}
+        customer: {$ref: '#/components/schemas/Customer'}
+        tags: {type: array, items: {type: string}}
+    Customer:
+      type: object
+      properties:
+        email: {type: string, format: email}
+        since: {type: string, example: 2024-05-01}
+`
+
+func TestParseOpenAPI(t *testing.T) {
+	g, err := ParseOpenAPI([]byte(testOpenAPISpec), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Name != "payments-api" || g.BaseURL != "https://eu.pay.example.com/v1" {
+		t.Errorf("group: got %v %v - want payments-api https://eu.pay.example.com/v1", g.Name, g.BaseURL)
+	}
+	if g.Auth == nil || *g.Auth != (Auth{AuthType: "Bearer", Token: "$PAYMENTS_API_TOKEN"}) {
+		t.Errorf("auth: got %+v - want Bearer of $PAYMENTS_API_TOKEN", g.Auth)
+	}
+	wantVars := Vars{"paymentId": "pay_123", "status": "paid"}
+	if !reflect.DeepEqual(g.Vars, wantVars) {
+		t.Errorf("vars: got %v - want %v", g.Vars, wantVars)
+	}
+	want := []ImportedRequest{
+		{Path: []string{"listPayments"}, Request: SavedRequestSet{URL: "/payments?status={{status}}"}},
+		{Path: []string{"create-payment"}, Request: SavedRequestSet{URL: "/payments", Method: "POST",
+			Body:   "{\n  \"amount\": 1000,\n  \"currency\": \"EUR\",\n  \"customer\": {\n    \"email\": \"user@example.com\",\n    \"since\": \"2024-05-01\"\n  },\n  \"tags\": [\n    \"string\"\n  ]\n}",
+			Header: map[string]string{"Idempotency-Key": "{{Idempotency-Key}}"}}},
+		{Path: []string{"getPayment"}, Request: SavedRequestSet{URL: "/payments/{{paymentId}}"}},
+		{Path: []string{"delete-payments-paymentid"}, Request: SavedRequestSet{URL: "/payments/{{paymentId}}", Method: "DELETE"}},
+		{Path: []string{"post-refunds"}, Request: SavedRequestSet{URL: "/refunds", Method: "POST", Body: "amount=0&payment=pay_1",
+			Header: map[string]string{"Content-Type": "application/x-www-form-urlencoded", "X-Api-Key": "${PAYMENTS_API_X_API_KEY}"}}},
+	}
+	if !reflect.DeepEqual(g.Requests, want) {
+		t.Errorf("requests: got %+v\nwant %+v", g.Requests, want)
+	}
+	wantEnvs := []string{"PAYMENTS_API_TOKEN", "PAYMENTS_API_X_API_KEY"}
+	if !reflect.DeepEqual(g.Envs, wantEnvs) {
+		t.Errorf("envs: got %v - want %v", g.Envs, wantEnvs)
+	}
+	if notes := strings.Join(g.Notes, "\n"); !strings.Contains(notes, "delete-payments-paymentid: needs no auth") {
+		t.Errorf("missing a note of the request without auth in:\n%s", notes)
+	}
+}
+
+func TestParseSwagger(t *testing.T) {
+	spec := `{
+  "swagger": "2.0",
+  "info": {"title": "Pets", "version": "1"},
+  "host": "pets.example.com",
+  "basePath": "/api/",
+  "schemes": ["http"],
+  "securityDefinitions": {"basic": {"type": "basic"}},
+  "security": [{"basic": []}],
+  "paths": {
+    "/pets/{petId}": {
+      "put": {
+        "operationId": "updatePet",
+        "parameters": [
+          {"name": "petId", "in": "path", "required": true, "type": "integer", "default": 1},
+          {"name": "body", "in": "body", "schema": {"type": "object", "properties": {"name": {"type": "string", "example": "Rex"}}}}
+        ]
+      }
+    },
+    "/pets": {
+      "post": {
+        "consumes": ["multipart/form-data"],
+        "parameters": [
+          {"name": "name", "in": "formData", "type": "string", "required": true, "x-example": "Rex"},
+          {"name": "photo", "in": "formData", "type": "file"}
+        ]
+      }
+    }
+  }
+}`
+	g, err := ParseOpenAPI([]byte(spec), "pets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.BaseURL != "http://pets.example.com/api" {
+		t.Errorf("baseUrl: got %v - want http://pets.example.com/api", g.BaseURL)
+	}
+	if g.Auth == nil || *g.Auth != (Auth{AuthType: "Password", Username: "$PETS_USERNAME", Password: "$PETS_PASSWORD"}) {
+		t.Errorf("auth: got %+v - want Password of env references", g.Auth)
+	}
+	if len(g.Requests) != 2 {
+		t.Fatalf("requests: got %+v - want 2", g.Requests)
+	}
+	post, put := g.Requests[0].Request, g.Requests[1].Request
+	if !strings.HasPrefix(post.Header["Content-Type"], "multipart/form-data; boundary=") || !strings.Contains(post.Body, `name="name"`) {
+		t.Errorf("post: got %+v - want a multipart body", post)
+	}
+	if put.URL != "/pets/{{petId}}" || put.Method != "PUT" || put.Body != "{\n  \"name\": \"Rex\"\n}" || g.Vars["petId"] != "1" {
+		t.Errorf("put: got %+v %v - want a PUT of a json body", put, g.Vars)
+	}
+	if notes := strings.Join(g.Notes, "\n"); !strings.Contains(notes, "file field photo") {
+		t.Errorf("missing a note of the file field in:\n%s", notes)
+	}
+}
+
+func TestParseOpenAPIErrs(t *testing.T) {
+	tests := map[string]string{
+		"not yaml":      "openapi: [3",
+		"not a spec":    "name: x",
+		"openapi 1":     "swagger: '1.2'",
+		"no operations": "openapi: 3.1.0\ninfo: {title: x}\npaths: {}",
+	}
+	for name, in := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseOpenAPI([]byte(in), "x"); err == nil {
+				t.Errorf("%v: should err", name)
+			}
+		})
+	}
+}
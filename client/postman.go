@@ -0,0 +1,501 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/jerempy/brang/config"
+	"gopkg.in/yaml.v3"
+)
+
+const postmanSchema = "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"
+
+// postmanCollection is a Postman collection in the v2.1 format, as exported from Postman
+type postmanCollection struct {
+	Info struct {
+		Name   string `json:"name"`
+		Schema string `json:"schema"`
+	} `json:"info"`
+	Item     []postmanItem     `json:"item"`
+	Auth     *postmanAuth      `json:"auth,omitempty"`
+	Variable []postmanKV       `json:"variable,omitempty"`
+	Event    []json.RawMessage `json:"event,omitempty"`
+}
+
+// postmanItem is a folder when it has items, else a request
+type postmanItem struct {
+	Name    string            `json:"name"`
+	Item    []postmanItem     `json:"item,omitempty"`
+	Request *postmanRequest   `json:"request,omitempty"`
+	Auth    *postmanAuth      `json:"auth,omitempty"`
+	Event   []json.RawMessage `json:"event,omitempty"`
+}
+
+type postmanRequest struct {
+	Method string       `json:"method"`
+	Header []postmanKV  `json:"header,omitempty"`
+	Body   *postmanBody `json:"body,omitempty"`
+	URL    postmanURL   `json:"url"`
+	Auth   *postmanAuth `json:"auth,omitempty"`
+}
+
+// A request can also be only its url
+func (r *postmanRequest) UnmarshalJSON(b []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(b), []byte(`"`)) {
+		r.Method = http.MethodGet
+		return json.Unmarshal(b, &r.URL)
+	}
+	type request postmanRequest
+	return json.Unmarshal(b, (*request)(r))
+}
+
+// postmanURL is read from a string or an object of its parts, and written as a string
+type postmanURL struct {
+	Raw string
+	// values of :path variables
+	Variable []postmanKV
+}
+
+func (u *postmanURL) UnmarshalJSON(b []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(b), []byte(`"`)) {
+		return json.Unmarshal(b, &u.Raw)
+	}
+	var parts struct {
+		Raw      string          `json:"raw"`
+		Protocol string          `json:"protocol"`
+		Host     json.RawMessage `json:"host"`
+		Path     json.RawMessage `json:"path"`
+		Query    []postmanKV     `json:"query"`
+		Variable []postmanKV     `json:"variable"`
+	}
+	if err := json.Unmarshal(b, &parts); err != nil {
+		return err
+	}
+	u.Raw, u.Variable = parts.Raw, parts.Variable
+	if u.Raw != "" {
+		return nil
+	}
+	// host and path are a string, or a list of its parts
+	join := func(raw json.RawMessage, sep string) string {
+		var s string
+		if json.Unmarshal(raw, &s) == nil {
+			return s
+		}
+		var l []string
+		json.Unmarshal(raw, &l)
+		return strings.Join(l, sep)
+	}
+	u.Raw = join(parts.Host, ".")
+	if parts.Protocol != "" {
+		u.Raw = parts.Protocol + "://" + u.Raw
+	}
+	if p := join(parts.Path, "/"); p != "" {
+		u.Raw += "/" + strings.TrimPrefix(p, "/")
+	}
+	var q []string
+	for _, kv := range parts.Query {
+		if !kv.Disabled {
+			q = append(q, kv.Key+"="+string(kv.Value))
+		}
+	}
+	u.Raw = addQuery(u.Raw, strings.Join(q, "&"))
+	return nil
+}
+
+func (u postmanURL) MarshalJSON() ([]byte, error) {
+	return json.Marshal(u.Raw)
+}
+
+type postmanKV struct {
+	Key      string       `json:"key"`
+	Value    postmanValue `json:"value"`
+	Type     string       `json:"type,omitempty"`
+	Disabled bool         `json:"disabled,omitempty"`
+}
+
+// postmanValue is read from any json value, as values of variables can be numbers or bools
+type postmanValue string
+
+func (v *postmanValue) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*v = postmanValue(s)
+		return nil
+	}
+	if string(b) == "null" {
+		*v = ""
+		return nil
+	}
+	var c bytes.Buffer
+	if err := json.Compact(&c, b); err != nil {
+		return err
+	}
+	*v = postmanValue(c.String())
+	return nil
+}
+
+type postmanBody struct {
+	Mode       string      `json:"mode"`
+	Raw        string      `json:"raw,omitempty"`
+	URLEncoded []postmanKV `json:"urlencoded,omitempty"`
+	FormData   []postmanKV `json:"formdata,omitempty"`
+	GraphQL    *struct {
+		Query     string `json:"query"`
+		Variables string `json:"variables"`
+	} `json:"graphql,omitempty"`
+	Options  *postmanBodyOptions `json:"options,omitempty"`
+	Disabled bool                `json:"disabled,omitempty"`
+}
+
+type postmanBodyOptions struct {
+	Raw struct {
+		Language string `json:"language"`
+	} `json:"raw"`
+}
+
+type postmanAuth struct {
+	Type   string      `json:"type"`
+	Bearer []postmanKV `json:"bearer,omitempty"`
+	Basic  []postmanKV `json:"basic,omitempty"`
+	APIKey []postmanKV `json:"apikey,omitempty"`
+}
+
+// Returns the value of key in the list
+func postmanGet(l []postmanKV, key string) string {
+	for _, kv := range l {
+		if kv.Key == key {
+			return string(kv.Value)
+		}
+	}
+	return ""
+}
+
+// content types of raw bodies by their language in Postman. json is brang's default
+var postmanLanguages = map[string]string{
+	"text": "text/plain", "javascript": "application/javascript", "html": "text/html", "xml": "application/xml",
+}
+
+// ParsePostman reads a Postman v2.1 collection into a group named group, or named after
+// the collection when group is empty. Folders become nested requests and {{vars}} stay
+// vars, with the collection's variables as the group's.
+func ParsePostman(data []byte, group string) (*ImportedGroup, error) {
+	var c postmanCollection
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("err reading Postman collection: %w", err)
+	}
+	if !strings.Contains(c.Info.Schema, "collection/v2") {
+		return nil, fmt.Errorf("not a Postman v2.1 collection. export it from Postman as Collection v2.1")
+	}
+	if group == "" {
+		group = importKey(c.Info.Name, map[string]bool{})
+	}
+	g := newImportedGroup(group)
+	for _, kv := range c.Variable {
+		if !kv.Disabled {
+			g.addVar(g.Vars, kv.Key, string(kv.Value))
+		}
+	}
+	if len(c.Event) > 0 {
+		g.note("scripts of the collection aren't imported")
+	}
+	p := &postmanImport{g: g, root: c.Auth}
+	if c.Auth != nil {
+		g.Auth = p.auth(group, c.Auth)
+	}
+	p.items(c.Item, nil, c.Auth, map[string]bool{})
+	g.finish()
+	return g, nil
+}
+
+type postmanImport struct {
+	g *ImportedGroup
+	// auth of the collection, which is the group's
+	root *postmanAuth
+}
+
+func (p *postmanImport) items(items []postmanItem, path []string, auth *postmanAuth, used map[string]bool) {
+	for _, it := range items {
+		key := importKey(it.Name, used)
+		ipath := append(append([]string{}, path...), key)
+		a := auth
+		if it.Auth != nil && it.Auth.Type != "inherit" {
+			a = it.Auth
+		}
+		if len(it.Event) > 0 {
+			p.g.note("%s: scripts aren't imported", strings.Join(ipath, "."))
+		}
+		if it.Request == nil {
+			p.items(it.Item, ipath, a, map[string]bool{})
+			continue
+		}
+		if it.Request.Auth != nil && it.Request.Auth.Type != "inherit" {
+			a = it.Request.Auth
+		}
+		p.request(ipath, it.Request, a)
+	}
+}
+
+// Converts a Postman auth to brang's. apikey auth isn't an Authorization header, so it is
+// added to each request instead
+func (p *postmanImport) auth(name string, a *postmanAuth) *Auth {
+	switch a.Type {
+	case "bearer":
+		return &Auth{AuthType: "Bearer", Token: postmanGet(a.Bearer, "token")}
+	case "basic":
+		return &Auth{AuthType: "Password", Username: postmanGet(a.Basic, "username"), Password: postmanGet(a.Basic, "password")}
+	case "noauth", "inherit", "apikey", "":
+	default:
+		p.g.note("%s: %s auth isn't supported and isn't imported", name, a.Type)
+	}
+	return nil
+}
+
+func (p *postmanImport) request(path []string, r *postmanRequest, a *postmanAuth) {
+	g, name := p.g, strings.Join(path, ".")
+	sr := SavedRequestSet{URL: r.URL.Raw}
+	if m := strings.ToUpper(r.Method); m != "" && m != http.MethodGet {
+		sr.Method = m
+	}
+	for _, v := range r.URL.Variable {
+		pathVar := regexp.MustCompile(`/:` + regexp.QuoteMeta(v.Key) + `([/?#]|$)`)
+		sr.URL = pathVar.ReplaceAllString(sr.URL, "/{{"+v.Key+"}}$1")
+		if _, ok := g.Vars[v.Key]; !ok {
+			g.addVar(g.Vars, v.Key, string(v.Value))
+		}
+	}
+	header := map[string]string{}
+	for _, h := range r.Header {
+		if !h.Disabled {
+			header[h.Key] = string(h.Value)
+		}
+	}
+	if b := r.Body; b != nil && !b.Disabled {
+		switch b.Mode {
+		case "raw":
+			sr.Body = b.Raw
+			if b.Options != nil {
+				if ct, ok := postmanLanguages[b.Options.Raw.Language]; ok && b.Raw != "" {
+					setContentType(header, ct, false)
+				}
+			}
+		case "urlencoded":
+			var fields [][2]string
+			for _, kv := range b.URLEncoded {
+				if !kv.Disabled {
+					fields = append(fields, [2]string{kv.Key, string(kv.Value)})
+				}
+			}
+			sr.Body = formEncode(fields)
+			setContentType(header, "application/x-www-form-urlencoded", false)
+		case "formdata":
+			var fields [][2]string
+			for _, kv := range b.FormData {
+				switch {
+				case kv.Disabled:
+				case kv.Type == "file":
+					g.note("%s: file field %s of the form isn't imported", name, kv.Key)
+				default:
+					fields = append(fields, [2]string{kv.Key, string(kv.Value)})
+				}
+			}
+			if body, ct, err := multipartBody(fields); err == nil {
+				sr.Body = body
+				setContentType(header, ct, true)
+			}
+		case "graphql":
+			if b.GraphQL != nil {
+				q := map[string]interface{}{"query": b.GraphQL.Query}
+				if v := strings.TrimSpace(b.GraphQL.Variables); v != "" && json.Valid([]byte(v)) {
+					q["variables"] = json.RawMessage(v)
+				}
+				body, _ := json.Marshal(q)
+				sr.Body = string(body)
+			}
+		case "file":
+			g.note("%s: a body from a file isn't imported. save it and send with -f", name)
+		}
+	}
+	if a != nil && a.Type == "apikey" {
+		key := postmanGet(a.APIKey, "key")
+		val := g.secretRef(key, postmanGet(a.APIKey, "value"))
+		if postmanGet(a.APIKey, "in") == "query" {
+			sr.URL = addQuery(sr.URL, formEncode([][2]string{{key, val}}))
+		} else {
+			header[key] = val
+		}
+	}
+	var auth *Auth
+	if a != nil && a != p.root {
+		if auth = p.auth(name, a); auth == nil && (a.Type == "noauth" || a.Type == "apikey") && g.Auth != nil {
+			g.note("%s: has no %s auth in Postman but is sent with it, as the auth of %s", name, g.Auth.AuthType, g.Name)
+		}
+	}
+	auth = g.secretHeaders(header, auth)
+	if len(header) > 0 {
+		sr.Header = header
+	}
+	g.checkVars(name, sr.URL)
+	g.checkVars(name, sr.Body)
+	for _, k := range sortedKeys(sr.Header) {
+		g.checkVars(name, sr.Header[k])
+	}
+	g.addRequest(path, sr, auth)
+}
+
+// ExportPostman writes the saved requests of group as a Postman v2.1 collection. Nested
+// requests become folders, and the group's baseUrl, vars and auth become the collection's.
+// $ENV references become {{ENV}} variables to fill in. Creds are redacted unless withSecrets.
+// Environments, captures and expects aren't exported.
+func ExportPostman(group string, withSecrets bool) (string, error) {
+	if err := config.LoadRequests(); err != nil {
+		return "", err
+	}
+	reqs := config.Requests.Lookup(group, "requests")
+	if reqs == nil {
+		return "", fmt.Errorf("no saved requests found for group %s", group)
+	}
+	g, err := loadGroup(group)
+	if err != nil {
+		return "", err
+	}
+	pe := &postmanExport{vars: map[string]string{}, withSecrets: withSecrets}
+	c := postmanCollection{}
+	c.Info.Name, c.Info.Schema = group, postmanSchema
+	if g.BaseURL != "" {
+		pe.vars["baseUrl"] = g.BaseURL
+	}
+	for k, v := range g.Vars {
+		if isSecretName(k) && !isRef(v) && !withSecrets {
+			v = redacted
+		}
+		pe.vars[k] = pe.value(v)
+	}
+	c.Auth = pe.auth(&g.Auth)
+	var walkErr error
+	walkSavedRequests(reqs, nil, func(p []string) {
+		n := config.Requests.Lookup(append([]string{group, "requests"}, p...)...)
+		var sr SavedRequestSet
+		if n.Kind == yaml.ScalarNode {
+			sr.URL = n.Value
+		} else if err := decodeNode(n, &sr); err != nil {
+			walkErr = err
+			return
+		}
+		addPostmanItem(&c.Item, p[:len(p)-1], postmanItem{Name: p[len(p)-1], Request: pe.request(sr)})
+	})
+	if walkErr != nil {
+		return "", walkErr
+	}
+	for _, k := range sortedKeys(pe.vars) {
+		c.Variable = append(c.Variable, postmanKV{Key: k, Value: postmanValue(pe.vars[k])})
+	}
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(c); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// Adds it to the folder at path in items, making the folders as needed
+func addPostmanItem(items *[]postmanItem, path []string, it postmanItem) {
+	if len(path) == 0 {
+		*items = append(*items, it)
+		return
+	}
+	for i := range *items {
+		if f := &(*items)[i]; f.Request == nil && f.Name == path[0] {
+			addPostmanItem(&f.Item, path[1:], it)
+			return
+		}
+	}
+	*items = append(*items, postmanItem{Name: path[0]})
+	addPostmanItem(&(*items)[len(*items)-1].Item, path[1:], it)
+}
+
+type postmanExport struct {
+	// variables of the collection
+	vars        map[string]string
+	withSecrets bool
+}
+
+// Makes ${ENV} and ${ENV:-default} into {{ENV}}, adding ENV to the variables
+func (pe *postmanExport) value(s string) string {
+	return envRef.ReplaceAllStringFunc(s, func(m string) string {
+		sm := envRef.FindStringSubmatch(m)
+		if _, ok := pe.vars[sm[1]]; !ok {
+			pe.vars[sm[1]] = sm[3]
+		}
+		return "{{" + sm[1] + "}}"
+	})
+}
+
+// Returns the cred of an auth as a {{var}}, or redacted when plain text. A $ENV
+// reference becomes the variable ENV
+func (pe *postmanExport) cred(s string) string {
+	if env, ok := strings.CutPrefix(s, "$"); ok && !strings.HasPrefix(env, "{") {
+		if _, ok := pe.vars[env]; !ok {
+			pe.vars[env] = ""
+		}
+		return "{{" + env + "}}"
+	}
+	if s != "" && !isRef(s) && !pe.withSecrets {
+		return redacted
+	}
+	return pe.value(s)
+}
+
+func (pe *postmanExport) auth(a *Auth) *postmanAuth {
+	switch a.AuthType {
+	case "Bearer":
+		return &postmanAuth{Type: "bearer", Bearer: []postmanKV{{Key: "token", Value: postmanValue(pe.cred(a.Token)), Type: "string"}}}
+	case "Token":
+		return &postmanAuth{Type: "apikey", APIKey: []postmanKV{
+			{Key: "key", Value: "Authorization", Type: "string"},
+			{Key: "value", Value: postmanValue("Token " + pe.cred(a.Token)), Type: "string"},
+			{Key: "in", Value: "header", Type: "string"},
+		}}
+	case "Password", "Basic":
+		return &postmanAuth{Type: "basic", Basic: []postmanKV{
+			{Key: "username", Value: postmanValue(pe.cred(a.Username)), Type: "string"},
+			{Key: "password", Value: postmanValue(pe.cred(a.Password)), Type: "string"},
+		}}
+	}
+	return nil
+}
+
+func (pe *postmanExport) request(sr SavedRequestSet) *postmanRequest {
+	r := &postmanRequest{Method: http.MethodGet, URL: postmanURL{Raw: pe.value(sr.URL)}}
+	if sr.Method != "" {
+		r.Method = strings.ToUpper(sr.Method)
+	}
+	if strings.HasPrefix(r.URL.Raw, "/") {
+		r.URL.Raw = "{{baseUrl}}" + r.URL.Raw
+	}
+	ct := ""
+	for _, k := range sortedKeys(sr.Header) {
+		v := sr.Header[k]
+		if isSecretName(k) && !isRef(v) && !pe.withSecrets {
+			v = redactValue(k, v)
+		}
+		if strings.EqualFold(k, "Content-Type") {
+			ct = v
+		}
+		r.Header = append(r.Header, postmanKV{Key: k, Value: postmanValue(pe.value(v)), Type: "text"})
+	}
+	if sr.Body != "" {
+		r.Body = &postmanBody{Mode: "raw", Raw: pe.value(sr.Body)}
+		// brang sends json when no Content-Type is set
+		if ct == "" || strings.Contains(ct, "json") {
+			r.Body.Options = &postmanBodyOptions{}
+			r.Body.Options.Raw.Language = "json"
+		}
+	}
+	return r
+}
@@ -0,0 +1,148 @@
+package client
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jerempy/brang/config"
+)
+
+const testPostmanCollection = `{
+  "info": {"name": "My Shop API", "schema": "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"},
+  "auth": {"type": "bearer", "bearer": [{"key": "token", "value": "{{token}}"}]},
+  "variable": [
+    {"key": "baseUrl", "value": "https://shop.example.com/api"},
+    {"key": "token", "value": "abc123"},
+    {"key": "limit", "value": 10}
+  ],
+  "item": [
+    {"name": "Users", "item": [
+      {"name": "List users", "request": {"method": "GET", "url": {"raw": "{{baseUrl}}/users?limit={{limit}}"}}},
+      {"name": "Get user", "request": {"method": "GET", "url": {"raw": "{{baseUrl}}/users/:id", "variable": [{"key": "id", "value": "7"}]}}},
+      {"name": "Create user", "request": {"method": "POST",
+        "header": [{"key": "X-Trace", "value": "{{$guid}}"}, {"key": "X-Old", "value": "1", "disabled": true}],
+        "body": {"mode": "raw", "raw": "{\"name\": \"joe\"}", "options": {"raw": {"language": "json"}}},
+        "url": "{{baseUrl}}/users"}}
+    ]},
+    {"name": "Login", "request": {"method": "POST",
+      "auth": {"type": "basic", "basic": [{"key": "username", "value": "joe"}, {"key": "password", "value": "pw"}]},
+      "body": {"mode": "urlencoded", "urlencoded": [{"key": "grant type", "value": "password"}, {"key": "scope", "value": "{{scope}}"}]},
+      "url": "{{baseUrl}}/login"}},
+    {"name": "Search", "request": {"method": "GET",
+      "auth": {"type": "apikey", "apikey": [{"key": "key", "value": "api_key"}, {"key": "value", "value": "k-1"}, {"key": "in", "value": "query"}]},
+      "url": {"protocol": "https", "host": ["search", "example", "com"], "path": ["find"], "query": [{"key": "q", "value": "a"}]}}},
+    {"name": "Health", "request": "https://status.example.com/health"},
+    {"name": "Orders", "request": {"method": "GET",
+      "header": [{"key": "Authorization", "value": "Bearer plainsecret"}, {"key": "X-Api-Key", "value": "k-plain"}],
+      "url": "{{baseUrl}}/orders"}}
+  ]
+}`
+
+func TestParsePostman(t *testing.T) {
+	g, err := ParsePostman([]byte(testPostmanCollection), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Name != "my-shop-api" || g.BaseURL != "https://shop.example.com/api" {
+		t.Errorf("group: got %v %v - want my-shop-api https://shop.example.com/api", g.Name, g.BaseURL)
+	}
+	wantVars := Vars{"token": "${MY_SHOP_API_TOKEN}", "limit": "10", "id": "7"}
+	if !reflect.DeepEqual(g.Vars, wantVars) {
+		t.Errorf("vars: got %v - want %v", g.Vars, wantVars)
+	}
+	if g.Auth == nil || *g.Auth != (Auth{AuthType: "Bearer", Token: "{{token}}"}) {
+		t.Errorf("auth: got %+v - want Bearer of {{token}}", g.Auth)
+	}
+	want := map[string]SavedRequestSet{
+		"users.list-users":  {URL: "/users?limit={{limit}}"},
+		"users.get-user":    {URL: "/users/{{id}}"},
+		"users.create-user": {URL: "/users", Method: "POST", Body: `{"name": "joe"}`, Header: map[string]string{"X-Trace": "{{$guid}}"}},
+		"login": {URL: "/login", Method: "POST", Body: "grant+type=password&scope={{scope}}",
+			Header: map[string]string{"Content-Type": "application/x-www-form-urlencoded"}},
+		"search": {URL: "https://search.example.com/find?q=a&api_key=${MY_SHOP_API_API_KEY}"},
+		"health": {URL: "https://status.example.com/health"},
+		"orders": {URL: "/orders", Header: map[string]string{"X-Api-Key": "${MY_SHOP_API_X_API_KEY}"}},
+	}
+	if len(g.Requests) != len(want) {
+		t.Errorf("requests: got %v - want %v", len(g.Requests), len(want))
+	}
+	for _, r := range g.Requests {
+		name := strings.Join(r.Path, ".")
+		if w, ok := want[name]; !ok || !reflect.DeepEqual(r.Request, w) {
+			t.Errorf("%s: got %+v - want %+v", name, r.Request, w)
+		}
+	}
+	wantEnvs := []string{"MY_SHOP_API_TOKEN", "MY_SHOP_API_API_KEY", "MY_SHOP_API_X_API_KEY"}
+	if !reflect.DeepEqual(g.Envs, wantEnvs) {
+		t.Errorf("envs: got %v - want %v", g.Envs, wantEnvs)
+	}
+	notes := strings.Join(g.Notes, "\n")
+	for _, n := range []string{"{{$guid}}", "login: its Password auth isn't imported", "search: has no Bearer auth", "orders: its Bearer auth isn't imported"} {
+		if !strings.Contains(notes, n) {
+			t.Errorf("missing a note of %q in:\n%s", n, notes)
+		}
+	}
+	if s := notes + fmt.Sprint(g.Vars) + fmt.Sprint(g.Requests); strings.Contains(s, "abc123") || strings.Contains(s, "plain") {
+		t.Errorf("cred kept as plain text")
+	}
+}
+
+func TestParsePostmanErrs(t *testing.T) {
+	tests := map[string]string{
+		"not json": `{"info":`,
+		"v1":       `{"name": "old", "requests": []}`,
+	}
+	for name, in := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParsePostman([]byte(in), ""); err == nil {
+				t.Errorf("%v: should err", name)
+			}
+		})
+	}
+}
+
+func TestExportPostmanRoundTrip(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "requests.yaml")
+	os.WriteFile(f, []byte(""), 0644)
+	config.Requests.SetConfigFile(f)
+	defer config.Requests.SetConfigFile("")
+
+	g, err := ParsePostman([]byte(testPostmanCollection), "shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Save(); err != nil {
+		t.Fatal(err)
+	}
+	out, err := ExportPostman("shop", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"schema": "` + postmanSchema + `"`, `"url": "{{baseUrl}}/users/{{id}}"`, `"key": "SHOP_TOKEN"`, `"language": "json"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("export missing %s:\n%s", want, out)
+		}
+	}
+	back, err := ParsePostman([]byte(out), "shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(back.Requests) != len(g.Requests) || back.BaseURL != g.BaseURL || *back.Auth != *g.Auth {
+		t.Fatalf("got %+v - want the same group back", back)
+	}
+	for i, r := range back.Requests {
+		// ${ENV} comes back as {{ENV}}, which is also filled in from the env variable
+		want := g.Requests[i]
+		want.Request.URL = envRef.ReplaceAllString(want.Request.URL, "{{$1}}")
+		for k, v := range want.Request.Header {
+			want.Request.Header[k] = envRef.ReplaceAllString(v, "{{$1}}")
+		}
+		if !reflect.DeepEqual(r, want) {
+			t.Errorf("got %+v - want %+v", r, want)
+		}
+	}
+}
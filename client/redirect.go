@@ -0,0 +1,77 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// Redirect is one hop of a redirect chain: the request sent and the redirect it got back
+type Redirect struct {
+	Method   string `json:"method"`
+	URL      string `json:"url"`
+	Status   int    `json:"status"`
+	Location string `json:"location"`
+	// the Authorization header was sent on this hop but not on the next, as the next
+	// went to another host
+	AuthDropped bool `json:"authDropped,omitempty"`
+}
+
+const defaultMaxRedirects = 10
+
+type redirectsKey struct{}
+
+// redirectLog collects the hops of a request as it is sent
+type redirectLog struct {
+	mu   sync.Mutex
+	hops []Redirect
+}
+
+// Returns r with a log for the hops of its redirects
+func withRedirectLog(r *http.Request) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), redirectsKey{}, &redirectLog{}))
+}
+
+// Returns the hops logged for the request of resp
+func redirectsOf(resp *http.Response) []Redirect {
+	if resp == nil || resp.Request == nil {
+		return nil
+	}
+	l, ok := resp.Request.Context().Value(redirectsKey{}).(*redirectLog)
+	if !ok {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]Redirect{}, l.hops...)
+}
+
+// Logs each hop and stops per the redirect settings. With NoFollow the redirect
+// itself is the response.
+func (t Transport) checkRedirect(req *http.Request, via []*http.Request) error {
+	if t.noFollow() {
+		return http.ErrUseLastResponse
+	}
+	max := t.MaxRedirects
+	if max <= 0 {
+		max = defaultMaxRedirects
+	}
+	if len(via) > max {
+		// the last redirect is the response, so it isn't a hop
+		return fmt.Errorf("stopped after %d redirects. allow more with --max-redirects", max)
+	}
+	prev := via[len(via)-1]
+	if l, ok := req.Context().Value(redirectsKey{}).(*redirectLog); ok && req.Response != nil {
+		l.mu.Lock()
+		l.hops = append(l.hops, Redirect{
+			Method:      prev.Method,
+			URL:         prev.URL.String(),
+			Status:      req.Response.StatusCode,
+			Location:    req.Response.Header.Get("Location"),
+			AuthDropped: prev.Header.Get("Authorization") != "" && req.Header.Get("Authorization") == "",
+		})
+		l.mu.Unlock()
+	}
+	return nil
+}
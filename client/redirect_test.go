@@ -0,0 +1,125 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRedirects(t *testing.T) {
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer other.Close()
+	// localhost is another host than the 127.0.0.1 of ts, so auth is dropped going there
+	otherURL := strings.Replace(other.URL, "127.0.0.1", "localhost", 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/a":
+			http.Redirect(w, r, "/b", http.StatusFound)
+		case "/b":
+			http.Redirect(w, r, otherURL+"/c", http.StatusMovedPermanently)
+		}
+	}))
+	defer ts.Close()
+	tests := map[string]struct {
+		transport  Transport
+		wantStatus int
+		wantHops   []Redirect
+		wantErr    bool
+	}{
+		"follows": {
+			wantStatus: 200,
+			wantHops: []Redirect{
+				{Method: "GET", URL: ts.URL + "/a", Status: 302, Location: "/b"},
+				{Method: "GET", URL: ts.URL + "/b", Status: 301, Location: otherURL + "/c", AuthDropped: true},
+			},
+		},
+		"no follow": {transport: Transport{NoFollow: boolPtr(true)}, wantStatus: 302},
+		"max redirects": {
+			transport: Transport{MaxRedirects: 1}, wantStatus: 301, wantErr: true,
+			wantHops: []Redirect{{Method: "GET", URL: ts.URL + "/a", Status: 302, Location: "/b"}},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			c, err := tc.transport.Client(0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req, _ := http.NewRequest("GET", ts.URL+"/a", nil)
+			req.Header.Set("Authorization", "Bearer abc")
+			br := NewBResponse()
+			resp, err := c.do(req)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("got err %v - want err %v", err, tc.wantErr)
+			}
+			br.CaptureResponse(resp, err)
+			if br.StatusCode != tc.wantStatus {
+				t.Errorf("status: got %v - want %v", br.StatusCode, tc.wantStatus)
+			}
+			if len(br.Redirects) != len(tc.wantHops) {
+				t.Fatalf("hops: got %+v - want %+v", br.Redirects, tc.wantHops)
+			}
+			for i, h := range br.Redirects {
+				if h != tc.wantHops[i] {
+					t.Errorf("hop %d: got %+v - want %+v", i, h, tc.wantHops[i])
+				}
+			}
+			if tc.wantStatus == 200 && br.StringResponseBody() != "" {
+				t.Errorf("auth on another host: got %v - want it dropped", br.StringResponseBody())
+			}
+		})
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	tests := map[string]struct {
+		body     string
+		wantBody string
+	}{
+		"json body": {body: `{"id": 27}`, wantBody: `{"id":27}`},
+		"text body": {body: "hello", wantBody: `"hello"`},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "https://mysite.com/b", nil)
+			req.Header.Set("Authorization", "Bearer abc")
+			br := NewBResponse()
+			br.Response = &http.Response{StatusCode: 200, Status: "200 OK", Header: http.Header{}, Request: req}
+			br.OutBody.WriteString(tc.body)
+			br.bodyRead = true
+			br.Redirects = []Redirect{{Method: "GET", URL: "https://mysite.com/a", Status: 302, Location: "/b"}}
+			var b bytes.Buffer
+			w := bufio.NewWriter(&b)
+			br.writeTo(w, "json")
+			w.Flush()
+			var got struct {
+				Request   jsonRequest
+				Redirects []Redirect
+				Response  struct {
+					StatusCode int
+					Body       json.RawMessage
+				}
+			}
+			if err := json.Unmarshal(b.Bytes(), &got); err != nil {
+				t.Fatalf("%v in: %s", err, b.String())
+			}
+			var compact bytes.Buffer
+			json.Compact(&compact, got.Response.Body)
+			if compact.String() != tc.wantBody {
+				t.Errorf("body: got %v - want %v", compact.String(), tc.wantBody)
+			}
+			if got.Request.Header.Get("Authorization") != "******" {
+				t.Errorf("Authorization: got %v - want ******", got.Request.Header.Get("Authorization"))
+			}
+			if got.Response.StatusCode != 200 || len(got.Redirects) != 1 || got.Redirects[0].Location != "/b" {
+				t.Errorf("got json %s - want status 200 and the redirect to /b", b.String())
+			}
+		})
+	}
+}
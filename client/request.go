@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"os"
 	"regexp"
+	"time"
 )
 
 type RequestSet struct {
@@ -17,11 +18,50 @@ type RequestSet struct {
 	Params,
 	Body string
 	HeaderSlice []string
+	// name=value pairs from --var flags
+	VarSlice []string
+	// environment of the saved request's group. After loading it is the one used, if any
+	Env string
+	// transport settings from flags
+	Transport Transport
+	// writes the timing of the request in the pretty output
+	ShowTiming bool
+	// session to keep cookies and sticky headers in, and more headers to make sticky
+	Session        string
+	SessionHeaders []string
+	// vars of the saved request's group
+	vars Vars
+	// group of the saved request, and the saved request as loaded
+	group string
+	saved *SavedRequestSet
+	// name of the saved request in dot.notation
+	name string
+	// transport: and session: of the saved request's group
+	groupTransport Transport
+	groupSession   GroupSession
+	// gets the token of a group's OAuth2 auth again when the request gets a 401
+	oauth *oauthSource
+	// signs the request for a group's AWSSigV4 auth
+	sigv4 *sigV4Signer
+	// answers the Digest challenges of the server for Digest auth
+	digest *digestAuth
 }
 
-// Creates new *http.Request and attaches a *http.Header
+// Creates new *http.Request and attaches a *http.Header. Placeholders are only filled in
+// for saved requests or with --var, so the body of a url request is sent as it is given.
 func (rset *RequestSet) BuildRequest() (*http.Request, error) {
+	if rset.saved != nil || len(rset.VarSlice) > 0 {
+		if err := rset.expandVars(); err != nil {
+			return nil, err
+		}
+	}
 	rset.URL += rset.Params
+	if rset.Method == "" {
+		rset.Method = http.MethodGet
+	}
+	if len(rset.Body) > 0 && !allowsBody(rset.Method) {
+		return nil, fmt.Errorf("%s requests can't have a body", rset.Method)
+	}
 	body := rset.bufBodyBuilder()
 	req, err := http.NewRequest(rset.Method, rset.URL, body)
 	if err != nil {
@@ -32,6 +72,15 @@ func (rset *RequestSet) BuildRequest() (*http.Request, error) {
 		return nil, fmt.Errorf("err building header: %w", err)
 	}
 	req.Header = *header
+	if rset.AuthType == "AWSSigV4" {
+		if rset.sigv4 == nil {
+			return nil, fmt.Errorf("AWSSigV4 auth needs to be set in the auth: of a group")
+		}
+		rset.sigv4.sign(req, []byte(rset.Body), time.Now())
+	}
+	if rset.AuthType == "Digest" {
+		rset.digest = newDigestAuth(rset.Cred, rset.group, req.URL.Host)
+	}
 	return req, nil
 }
 
@@ -43,23 +92,67 @@ func (rset *RequestSet) bufBodyBuilder() io.Reader {
 }
 
 func (rset *RequestSet) Send() {
-	var req *http.Request
-	if !isHttp(rset.URL) {
-		r, err := LoadSavedRequest(rset)
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
-		req = r
-	} else {
-		r, err := rset.BuildRequest()
-		if err != nil {
-			fmt.Println("error building request from data provided: ", err)
-			return
+	req, err := rset.Prepare()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	rset.send(req)
+}
+
+// Sends req, writing the response and recording it in the history
+func (rset *RequestSet) send(req *http.Request) {
+	c, err := rset.ActiveTransport().Client(0)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	sess, err := rset.ActiveSession(nil)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	br, brh := rset.handler()
+	c.withSession(sess).withOAuth(rset.oauth).withDigest(rset.digest).DoRequest(req, brh)
+	if err := recordHistory(rset.name, req, br); err != nil {
+		fmt.Println(err)
+	}
+	if sess != nil {
+		if err := sess.Save(); err != nil {
+			fmt.Printf("err saving session %s: %v\n", sess.Name, err)
 		}
-		req = r
 	}
-	NewClient().DoRequest(req, NewBResponse())
+}
+
+// Prepare builds the *http.Request from a url, or loads it from requests.yaml for a SavedRequest
+func (rset *RequestSet) Prepare() (*http.Request, error) {
+	if !isHttp(rset.URL) {
+		return LoadSavedRequest(rset)
+	}
+	req, err := rset.BuildRequest()
+	if err != nil {
+		return nil, fmt.Errorf("error building request from data provided: %w", err)
+	}
+	return req, nil
+}
+
+// Returns the BResponse for the request, and the handler to capture it with
+func (rset *RequestSet) handler() (*BResponse, BResponseHandler) {
+	br := NewBResponse()
+	br.Env, br.ShowTiming = rset.Env, rset.ShowTiming
+	if rset.saved != nil && len(rset.saved.Capture) > 0 {
+		return br, &captureHandler{br, rset.group, rset.saved.Capture}
+	}
+	return br, br
+}
+
+// Returns a copy of rset that can be changed without changing rset
+func (rset *RequestSet) clone() *RequestSet {
+	c := *rset
+	c.HeaderSlice = append([]string{}, rset.HeaderSlice...)
+	c.VarSlice = append([]string{}, rset.VarSlice...)
+	c.SessionHeaders = append([]string{}, rset.SessionHeaders...)
+	return &c
 }
 
 // Takes path to a file with body for a request. Reads it and attaches to request.
@@ -72,6 +165,20 @@ func (rset *RequestSet) BodyFile(f string) error {
 	return nil
 }
 
+// HEAD and TRACE requests must not carry a body (RFC 9110)
+func allowsBody(method string) bool {
+	switch method {
+	case http.MethodHead, http.MethodTrace:
+		return false
+	}
+	return true
+}
+
+// IsURL tells if arg of a request command is a url rather than a SavedRequest
+func IsURL(arg string) bool {
+	return isHttp(arg)
+}
+
 func isHttp(u string) bool {
 	startsHttpOrWww, _ := regexp.Compile(`^(?:https?:\/\/|www\.)`)
 	return startsHttpOrWww.MatchString(u)
@@ -87,9 +87,10 @@ deleteTempFileOnClose: false
 outWriterFileName: testfile
 outWriterFilePath: %v
 `, os.TempDir()))
-	config.Requests.SetConfigType("yaml")
 	config.Brang.SetConfigType("yaml")
 	config.Requests.ReadConfig(bytes.NewBuffer(testDoReqYml))
+	defer func(old string) { config.HistoryPath = old }(config.HistoryPath)
+	config.HistoryPath = t.TempDir()
 	config.Brang.ReadConfig(bytes.NewBuffer(testConfigyml))
 	rset1 := mockRSet("testspace.site")
 	rset2 := mockRSet(ts.URL)
@@ -136,3 +137,37 @@ func TestBodyFile(t *testing.T) {
 		t.Errorf("testBodyFile: got %v - want %v", r.Body, want)
 	}
 }
+
+func TestBuildRequestMethods(t *testing.T) {
+	tests := map[string]struct {
+		method  string
+		body    string
+		wantErr bool
+		wantCT  string
+	}{
+		"head":         {method: "HEAD", wantCT: ""},
+		"head body":    {method: "HEAD", body: "test body", wantErr: true},
+		"options":      {method: "OPTIONS", wantCT: "application/json; charset=UTF-8"},
+		"custom verb":  {method: "PROPFIND", body: "<propfind/>", wantCT: "application/json; charset=UTF-8"},
+		"invalid verb": {method: "BAD VERB", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			rset := mockRSet("https://mysite.com/posts/1")
+			rset.Method, rset.Body = tc.method, tc.body
+			got, err := rset.BuildRequest()
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("%v: got err %v - want err %v", name, err, tc.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.Method != tc.method {
+				t.Errorf("got %v - want %v", got.Method, tc.method)
+			}
+			if ct := got.Header.Get("Content-Type"); ct != tc.wantCT {
+				t.Errorf("Content-Type: got %v - want %v", ct, tc.wantCT)
+			}
+		})
+	}
+}
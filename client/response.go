@@ -1,7 +1,9 @@
 package client
 
 import (
+	"bufio"
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
@@ -14,6 +16,14 @@ type BResponse struct {
 	*http.Response
 	OutBody bytes.Buffer
 	errs    []error
+	// environment the saved request was sent with
+	Env string
+	// hops of the redirects followed, in order
+	Redirects []Redirect
+	// writes the Timing in the pretty output
+	ShowTiming bool
+	trace      *tracer
+	bodyRead   bool
 }
 
 type BResponseWriter interface {
@@ -33,16 +43,31 @@ func (br *BResponse) Write(p []byte) (n int, err error) {
 	return br.OutBody.Write(p)
 }
 
+// Reads the response body into OutBody the first time, so it can be called again
+// such as by capture rules and then the output template.
 func (br *BResponse) StringResponseBody() string {
-	_, err := io.Copy(&br.OutBody, br.Body)
-	if err != nil {
-		br.AddError(fmt.Errorf("error reading the body: %v", err))
+	if !br.bodyRead && br.Body != nil {
+		br.bodyRead = true
+		_, err := io.Copy(&br.OutBody, br.Body)
+		if err != nil {
+			br.AddError(fmt.Errorf("error reading the body: %v", err))
+		}
+		br.Body.Close()
 	}
 	return br.OutBody.String()
 }
 
 func NewBResponse() *BResponse {
-	return &BResponse{&http.Response{}, bytes.Buffer{}, []error{}}
+	return &BResponse{Response: &http.Response{}, errs: []error{}}
+}
+
+// Timing returns where the time of the request went. Transfer and Total are
+// known once the body is read. nil when the request wasn't traced.
+func (br *BResponse) Timing() *Timing {
+	if br.trace == nil {
+		return nil
+	}
+	return br.trace.timing()
 }
 
 func (br *BResponse) AddError(e error) {
@@ -59,6 +84,8 @@ func (br *BResponse) writeOutErrors() string {
 
 func (br *BResponse) CaptureResponse(r *http.Response, e error) {
 	br.Response = r
+	br.Redirects = redirectsOf(r)
+	br.trace = tracerOf(r)
 	if e != nil {
 		br.AddError(e)
 	}
@@ -74,18 +101,28 @@ func (br *BResponse) WriteResponse() {
 	if w.Fn != nil {
 		defer w.Fn()
 	}
-	switch w.Format {
+	br.writeTo(w.Writer, w.Format)
+}
+
+// Writes the response to w in the format: raw|basic|json|pretty
+func (br *BResponse) writeTo(w *bufio.Writer, format string) {
+	switch format {
 	case "raw":
-		br.Header.Write(w.Writer)
-		w.Writer.WriteString(br.StringResponseBody())
+		br.Header.Write(w)
+		w.WriteString(br.StringResponseBody())
+	case "json":
+		if err := br.writeJSON(w); err != nil {
+			br.AddError(err)
+			w.WriteString(br.writeOutErrors())
+		}
 	case "basic":
 		t, err := template.New("basic").Parse(basicTmpl)
 		if err != nil {
 			br.AddError(err)
-			w.Writer.WriteString(br.writeOutErrors())
+			w.WriteString(br.writeOutErrors())
 			break
 		}
-		t.Execute(w.Writer, br)
+		t.Execute(w, br)
 	default:
 		t, err := template.New("pretty").Funcs(template.FuncMap{
 			"headerToStringForPrint": headerToStringForPrint,
@@ -93,22 +130,33 @@ func (br *BResponse) WriteResponse() {
 		}).Parse(prettyTmpl)
 		if err != nil {
 			br.AddError(err)
-			w.Writer.WriteString(br.writeOutErrors())
+			w.WriteString(br.writeOutErrors())
 			break
 		}
-		t.Execute(w.Writer, br)
+		t.Execute(w, br)
 	}
 }
 
 const (
-	prettyTmpl = `---| Request: {{.Request.Method}} --- url={{.Request.URL}}
+	prettyTmpl = `
+{{- range .Redirects }}---| Redirect: {{.Method}} --- url={{.URL}}
+   | {{.Status}} Location: {{.Location}}{{ if .AuthDropped }} - Authorization dropped: redirected to another host{{ end }} |---
+{{ end -}}
+---| Request: {{.Request.Method}} --- url={{.Request.URL}}
    | Request Header:  {{headerToStringForPrint .Request.Header}} |---
+{{- if .Env }}
+   | Environment: {{.Env}} |---
+{{- end }}
 ---| Response --- Status Code: {{.StatusCode}} |---
+   | Response Header: {{headerToStringForPrint .Header}} |---
 {{ .StringResponseBody }}
  ---| End Response |---
-{{ $length := len .errs }} {{ if gt $length 0 }}
+{{- if .ShowTiming }}{{ with .Timing }}
+---| Timing --- {{ . }} |---
+{{- end }}{{ end }}
+{{ with writeErrors }}
 Errors:
-{{ .writeOutErrors }}
+{{ . }}
 {{ end }}
 `
 	basicTmpl = `Status Code: {{.StatusCode}}
@@ -120,7 +168,7 @@ Errors:
 func headerToStringForPrint(h *http.Header) string {
 	var s string
 	for k, v := range *h {
-		if k == "Authorization" {
+		if isSecretName(k) {
 			s += fmt.Sprintf(`- %s: [******] -`, k)
 		} else {
 			s += fmt.Sprintf(`- %s: %v -`, k, v)
@@ -128,3 +176,80 @@ func headerToStringForPrint(h *http.Header) string {
 	}
 	return s
 }
+
+type jsonRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header"`
+}
+
+type jsonResponse struct {
+	Status     string      `json:"status"`
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header"`
+	// the body as json when it is, otherwise as a string
+	Body json.RawMessage `json:"body"`
+}
+
+type jsonTiming struct {
+	DNSMs      float64 `json:"dnsMs"`
+	ConnectMs  float64 `json:"connectMs"`
+	TLSMs      float64 `json:"tlsMs"`
+	TTFBMs     float64 `json:"ttfbMs"`
+	TransferMs float64 `json:"transferMs"`
+	TotalMs    float64 `json:"totalMs"`
+	Reused     bool    `json:"reusedConnection"`
+}
+
+type jsonOutput struct {
+	Request     jsonRequest  `json:"request"`
+	Redirects   []Redirect   `json:"redirects"`
+	Response    jsonResponse `json:"response"`
+	Timing      *jsonTiming  `json:"timing,omitempty"`
+	Environment string       `json:"environment,omitempty"`
+	Errors      []string     `json:"errors,omitempty"`
+}
+
+// Writes the request and response as json, for other tools to read
+func (br *BResponse) writeJSON(w io.Writer) error {
+	out := jsonOutput{
+		Redirects:   br.Redirects,
+		Environment: br.Env,
+		Response:    jsonResponse{Status: br.Status, StatusCode: br.StatusCode, Header: br.Header},
+	}
+	if out.Redirects == nil {
+		out.Redirects = []Redirect{}
+	}
+	if r := br.Request; r != nil {
+		out.Request = jsonRequest{Method: r.Method, URL: r.URL.String(), Header: redactHeader(r.Header)}
+	}
+	body := br.StringResponseBody()
+	if json.Valid([]byte(body)) {
+		out.Response.Body = json.RawMessage(body)
+	} else {
+		out.Response.Body, _ = json.Marshal(body)
+	}
+	if tm := br.Timing(); tm != nil {
+		out.Timing = &jsonTiming{
+			DNSMs: ms(tm.DNS), ConnectMs: ms(tm.Connect), TLSMs: ms(tm.TLS),
+			TTFBMs: ms(tm.TTFB), TransferMs: ms(tm.Transfer), TotalMs: ms(tm.Total), Reused: tm.Reused,
+		}
+	}
+	for _, e := range br.errs {
+		out.Errors = append(out.Errors, e.Error())
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// Returns a copy of h with the values of creds hidden, as in the pretty output
+func redactHeader(h http.Header) http.Header {
+	c := h.Clone()
+	for k := range c {
+		if isSecretName(k) {
+			c.Set(k, "******")
+		}
+	}
+	return c
+}
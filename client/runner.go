@@ -0,0 +1,243 @@
+package client
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jerempy/brang/config"
+	"gopkg.in/yaml.v3"
+)
+
+// RunResult is how one saved request of a collection run went
+type RunResult struct {
+	Name    string
+	Method  string
+	Status  int
+	Latency time.Duration
+	Size    int
+	Err     error
+	// response of the request, with the body read into OutBody
+	Response *BResponse
+	// expect: of the saved request
+	Expect *Expect
+}
+
+// Failed is true when the request couldn't be sent or got a 4xx|5xx status
+func (r *RunResult) Failed() bool {
+	return r.Err != nil || r.Status >= 400
+}
+
+// IsCollection is true when name is a whole group or has a * glob, such as mysite.posts.*
+func IsCollection(name string) bool {
+	p, err := config.ParseRequestPath(name)
+	return err == nil && (len(p) == 1 || strings.ContainsAny(name, "*?["))
+}
+
+// MatchSavedRequests lists the names of the saved requests matching pattern, in file order.
+// Keys of pattern can be globs (see path.Match) and a last key of * matches at any depth,
+// so mysite.* is every request of mysite. A pattern of only a group matches all of its requests.
+func MatchSavedRequests(pattern string) ([]string, error) {
+	if err := config.LoadRequests(); err != nil {
+		return nil, err
+	}
+	pat, err := config.ParseRequestPath(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if len(pat) == 1 {
+		pat = append(pat, "*")
+	}
+	var names []string
+	for _, g := range config.Requests.Keys() {
+		if ok, _ := path.Match(pat[0], g); !ok {
+			continue
+		}
+		walkSavedRequests(config.Requests.Lookup(g, "requests"), []string{g}, func(p []string) {
+			if matchPath(pat[1:], p[1:]) {
+				names = append(names, config.RequestName(p))
+			}
+		})
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no saved requests match %s. type 'brang config -h' for help in checking config", pattern)
+	}
+	return names, nil
+}
+
+// Calls fn with the path of every saved request under n. A saved request is a url, or a map with url:
+func walkSavedRequests(n *yaml.Node, p []string, fn func([]string)) {
+	if n == nil {
+		return
+	}
+	if n.Kind == yaml.ScalarNode && n.Value != "" {
+		fn(p)
+		return
+	}
+	if n.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == "url" {
+			fn(p)
+			return
+		}
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		walkSavedRequests(n.Content[i+1], append(append([]string{}, p...), n.Content[i].Value), fn)
+	}
+}
+
+func matchPath(pat, p []string) bool {
+	for i, s := range pat {
+		if i == len(pat)-1 && s == "*" {
+			return len(p) > i
+		}
+		if i >= len(p) {
+			return false
+		}
+		if ok, _ := path.Match(s, p[i]); !ok {
+			return false
+		}
+	}
+	return len(p) == len(pat)
+}
+
+// Runner sends many saved requests, such as every request of a group
+type Runner struct {
+	// flags shared by all requests. the method of each saved request is used
+	Base *RequestSet
+	// how many requests to send at once. 1 or less sends them one at a time in file order
+	Parallel int
+	// skips writing each response to the OutWriter
+	Quiet bool
+	// a client for each transport settings in use, so connections are reused
+	clients map[transportKey]*brangClient
+	// sessions in use, shared by the requests sent with them
+	sessions map[string]*Session
+}
+
+// Returns the client for the transport settings t, making it the first time
+func (rn *Runner) client(t Transport) (*brangClient, error) {
+	if c, ok := rn.clients[t.key()]; ok {
+		return c, nil
+	}
+	c, err := t.Client(rn.Parallel)
+	if err != nil {
+		return nil, err
+	}
+	rn.clients[t.key()] = c
+	return c, nil
+}
+
+// Run sends the saved requests, writing each response through the configured OutWriter.
+// Results are in the order of names.
+func (rn *Runner) Run(names []string) []*RunResult {
+	var w *bufio.Writer
+	format := ""
+	if !rn.Quiet {
+		bw := config.OutputWriter().Init()
+		if bw.Err != nil {
+			fmt.Println(bw.Err)
+		} else {
+			w, format = bw.Writer, bw.Format
+			if bw.Fn != nil {
+				defer bw.Fn()
+			}
+		}
+	}
+	rn.clients = map[transportKey]*brangClient{}
+	rn.sessions = map[string]*Session{}
+	results := make([]*RunResult, len(names))
+	var mu sync.Mutex
+	parallel := rn.Parallel
+	if parallel < 1 {
+		parallel = 1
+	}
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, name string) {
+			defer func() { <-sem; wg.Done() }()
+			res := rn.send(name, &mu)
+			results[i] = res
+			if w != nil && res.Response != nil {
+				mu.Lock()
+				res.Response.writeTo(w, format)
+				mu.Unlock()
+			}
+		}(i, name)
+	}
+	wg.Wait()
+	for _, s := range rn.sessions {
+		if err := s.Save(); err != nil {
+			fmt.Printf("err saving session %s: %v\n", s.Name, err)
+		}
+	}
+	return results
+}
+
+// Sends one saved request. mu guards loading it, as that reads requests.yaml into config.Requests
+func (rn *Runner) send(name string, mu *sync.Mutex) *RunResult {
+	rset := rn.Base.clone()
+	rset.URL, rset.Method = name, ""
+	res := &RunResult{Name: name}
+	mu.Lock()
+	req, err := LoadSavedRequest(rset)
+	var c *brangClient
+	var sess *Session
+	if err == nil {
+		c, err = rn.client(rset.ActiveTransport())
+	}
+	if err == nil {
+		sess, err = rset.ActiveSession(rn.sessions)
+	}
+	mu.Unlock()
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	res.Method, res.Expect = req.Method, rset.saved.Expect
+	br, brh := rset.handler()
+	start := time.Now()
+	resp, err := c.withSession(sess).withOAuth(rset.oauth).withDigest(rset.digest).do(req)
+	brh.CaptureResponse(resp, err)
+	res.Size = len(br.StringResponseBody())
+	res.Latency = time.Since(start)
+	if err := recordHistory(name, req, br); err != nil {
+		br.AddError(err)
+	}
+	res.Status, res.Err, res.Response = resp.StatusCode, err, br
+	return res
+}
+
+// WriteSummary writes a table of the results, and how many failed
+func WriteSummary(w io.Writer, results []*RunResult) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tMETHOD\tSTATUS\tLATENCY\tSIZE\t")
+	failed := 0
+	var errs []string
+	for _, r := range results {
+		status := fmt.Sprint(r.Status)
+		if r.Err != nil {
+			status = "err"
+			errs = append(errs, fmt.Sprintf("%s: %v", r.Name, r.Err))
+		}
+		if r.Failed() {
+			failed++
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%v\t%d\t\n", r.Name, r.Method, status, r.Latency.Round(time.Millisecond), r.Size)
+	}
+	tw.Flush()
+	for _, e := range errs {
+		fmt.Fprintln(w, e)
+	}
+	fmt.Fprintf(w, "%d requests, %d failed\n", len(results), failed)
+}
@@ -0,0 +1,99 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jerempy/brang/config"
+)
+
+var mockRunYml = `
+testspace:
+  baseUrl: %v
+  requests:
+    home: /
+    posts:
+      all: /posts
+      create:
+        url: /posts
+        method: POST
+      comments:
+        list: /comments
+    "v1.2":
+      missing: /missing
+testspace2:
+  requests:
+    other: %[1]v/other
+`
+
+func TestMatchSavedRequests(t *testing.T) {
+	config.Requests.ReadConfig(bytes.NewBufferString(fmt.Sprintf(mockRunYml, "https://mysite.com")))
+	tests := map[string]struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		"group":       {in: "testspace", want: `testspace.home|testspace.posts.all|testspace.posts.create|testspace.posts.comments.list|testspace."v1.2".missing`},
+		"group glob":  {in: "testspace.*", want: `testspace.home|testspace.posts.all|testspace.posts.create|testspace.posts.comments.list|testspace."v1.2".missing`},
+		"sub glob":    {in: "testspace.posts.*", want: "testspace.posts.all|testspace.posts.create|testspace.posts.comments.list"},
+		"key glob":    {in: "testspace.posts.c*", want: "testspace.posts.create"},
+		"group names": {in: "testspace*.other", want: "testspace2.other"},
+		"no match":    {in: "nope.*", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := MatchSavedRequests(tc.in)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("got err %v - want err %v", err, tc.wantErr)
+			}
+			if strings.Join(got, "|") != tc.want {
+				t.Errorf("%v: got %v - want %v", tc.in, strings.Join(got, "|"), tc.want)
+			}
+		})
+	}
+}
+
+func TestRunnerRun(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		fmt.Fprint(w, r.Method)
+	}))
+	defer ts.Close()
+	config.Requests.ReadConfig(bytes.NewBufferString(fmt.Sprintf(mockRunYml, ts.URL)))
+	defer func(old string) { config.HistoryPath = old }(config.HistoryPath)
+	config.HistoryPath = t.TempDir()
+	names, err := MatchSavedRequests("testspace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, parallel := range []int{1, 3} {
+		rn := &Runner{Base: mockRSet(""), Parallel: parallel, Quiet: true}
+		results := rn.Run(names)
+		if len(results) != len(names) {
+			t.Fatalf("got %d results - want %d", len(results), len(names))
+		}
+		for i, r := range results {
+			if r.Name != names[i] {
+				t.Errorf("results out of order: got %v - want %v", r.Name, names[i])
+			}
+		}
+		create := results[2]
+		if create.Method != "POST" || create.Status != 200 || create.Size != 4 || create.Failed() {
+			t.Errorf("create: got %+v", create)
+		}
+		if missing := results[4]; missing.Status != 404 || !missing.Failed() {
+			t.Errorf("missing should fail. got %+v", missing)
+		}
+		var b bytes.Buffer
+		WriteSummary(&b, results)
+		if !strings.Contains(b.String(), "5 requests, 1 failed") {
+			t.Errorf("summary: got %v", b.String())
+		}
+	}
+}
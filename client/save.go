@@ -0,0 +1,157 @@
+package client
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/jerempy/brang/config"
+)
+
+// SaveRequest writes the request made from rset to the requests.yaml as name, in dot.notation
+// of <group>.<path.to.request>. Creds are never written as plain text: they are written to the
+// group's auth as $ENV references, and the names of the env variables to set are returned, with
+// a notice when the group's auth of another type was replaced.
+func SaveRequest(rset *RequestSet, name string) ([]string, string, error) {
+	if !isHttp(rset.URL) {
+		return nil, "", fmt.Errorf("only requests made from a url can be saved. was given: %s", rset.URL)
+	}
+	path, err := config.ParseRequestPath(name)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(path) < 2 {
+		return nil, "", fmt.Errorf("save name needs to be <group>.<request> - ex: mysite.users.create. was given: %s", name)
+	}
+	group := path[0]
+	sr := SavedRequestSet{URL: rset.URL + rset.Params, Body: rset.Body}
+	if m := strings.ToUpper(rset.Method); m != "" && m != http.MethodGet {
+		sr.Method = m
+	}
+	h := &http.Header{}
+	if err := mapHeaderSliceToHeader(rset.HeaderSlice, h); err != nil {
+		return nil, "", err
+	}
+	authType, cred := rset.AuthType, rset.Cred
+	if a := h.Get("Authorization"); a != "" {
+		var err error
+		if authType, cred, err = authFromHeader(a); err != nil {
+			return nil, "", err
+		}
+		h.Del("Authorization")
+	}
+	for k := range *h {
+		if sr.Header == nil {
+			sr.Header = map[string]string{}
+		}
+		sr.Header[k] = h.Get(k)
+	}
+
+	if err := config.LoadRequests(); err != nil {
+		return nil, "", err
+	}
+	e := config.Requests
+	if err := e.Set(savedValue(sr), append([]string{group, "requests"}, path[1:]...)...); err != nil {
+		return nil, "", err
+	}
+	var envs []string
+	var notice string
+	if authType != "" && cred != "" {
+		a, replaced, err := envAuth(e, group, authType)
+		if err != nil {
+			return nil, "", err
+		}
+		if a != nil {
+			if err := e.Set(a, group, "auth"); err != nil {
+				return nil, "", err
+			}
+		} else if a, err = savedAuth(e, group); err != nil {
+			return nil, "", err
+		}
+		if replaced != "" {
+			notice = fmt.Sprintf("replaced %s auth of %s with %s", replaced, group, authType)
+		}
+		envs = authEnvs(a)
+	}
+	return envs, notice, e.WriteConfig()
+}
+
+// Splits the value of an Authorization header to an auth type and cred
+func authFromHeader(v string) (string, string, error) {
+	scheme, cred, _ := strings.Cut(v, " ")
+	switch scheme {
+	case "Bearer", "Token":
+		return scheme, cred, nil
+	case "Basic":
+		b, err := base64.StdEncoding.DecodeString(cred)
+		if err != nil {
+			return "", "", fmt.Errorf("err reading Basic Authorization header: %w", err)
+		}
+		return "Password", string(b), nil
+	default:
+		return "", "", fmt.Errorf("can't save Authorization header of type %s without saving the cred as plain text. remove it and set it in requests.yaml after saving", scheme)
+	}
+}
+
+// Returns the group auth to write, as $ENV references, or nil to keep the group's existing
+// auth when it is the same type. Also returns the type of the existing auth it replaces.
+func envAuth(e *config.RequestsStore, group, authType string) (*Auth, string, error) {
+	cur, err := savedAuth(e, group)
+	if err != nil {
+		return nil, "", err
+	}
+	if cur != nil && sameAuthType(cur.AuthType, authType) {
+		return nil, "", nil
+	}
+	a := &Auth{AuthType: authType}
+	switch authType {
+	case "Bearer", "Token":
+		a.Token = "$" + envName(group, "TOKEN")
+	case "Password", "Basic", "Digest":
+		a.Username = "$" + envName(group, "USERNAME")
+		a.Password = "$" + envName(group, "PASSWORD")
+	default:
+		return nil, "", fmt.Errorf("wrong auth type. accepts: Password|Bearer|Token|Digest. was given: %v", authType)
+	}
+	if cur != nil {
+		return a, cur.AuthType, nil
+	}
+	return a, "", nil
+}
+
+func savedAuth(e *config.RequestsStore, group string) (*Auth, error) {
+	n := e.Lookup(group, "auth")
+	if n == nil {
+		return nil, nil
+	}
+	a := &Auth{}
+	if err := decodeNode(n, a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func sameAuthType(a, b string) bool {
+	basic := func(s string) bool { return s == "Password" || s == "Basic" }
+	return a == b || basic(a) && basic(b)
+}
+
+// Returns the env variables referenced by the auth values
+func authEnvs(a *Auth) []string {
+	var envs []string
+	for _, v := range []string{a.Token, a.Username, a.Password, a.ClientID, a.ClientSecret, a.RefreshToken, a.AccessKey, a.SecretKey, a.SessionToken} {
+		if e, ok := strings.CutPrefix(v, "$"); ok {
+			envs = append(envs, e)
+		}
+	}
+	return envs
+}
+
+var notEnvChars = regexp.MustCompile(`[^A-Z0-9]+`)
+
+// Makes an env variable name like MYSITE_TOKEN
+func envName(group, key string) string {
+	return notEnvChars.ReplaceAllString(strings.ToUpper(group), "_") + "_" + key
+}
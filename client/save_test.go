@@ -0,0 +1,90 @@
+package client
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jerempy/brang/config"
+)
+
+func TestSaveRequest(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "requests.yaml")
+	os.WriteFile(f, []byte(`# keep this comment
+testspace:
+  requests:
+    posts: https://mysite.com/posts/ # and this one
+`), 0644)
+	config.Requests.SetConfigFile(f)
+	defer config.Requests.SetConfigFile("")
+
+	rset := mockRSet("https://mysite.com/users/")
+	rset.Method, rset.Body = "POST", `{"name": "joe"}`
+	rset.HeaderSlice = []string{"X-Custom:value"}
+	envs, notice, err := SaveRequest(rset, "testspace.users.getUserByID")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(envs) != 1 || envs[0] != "TESTSPACE_TOKEN" {
+		t.Errorf("envs: got %v - want [TESTSPACE_TOKEN]", envs)
+	}
+	if notice != "" {
+		t.Errorf("notice: got %v - want none", notice)
+	}
+	b, _ := os.ReadFile(f)
+	got := string(b)
+	for _, want := range []string{
+		"# keep this comment",
+		"posts: https://mysite.com/posts/ # and this one",
+		"getUserByID:",
+		"method: POST",
+		"X-Custom: value",
+		"token: $TESTSPACE_TOKEN",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("saved file missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "ABC-456") {
+		t.Errorf("cred saved as plain text:\n%s", got)
+	}
+}
+
+func TestSaveRequestReplacesAuth(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "requests.yaml")
+	os.WriteFile(f, []byte(`testspace:
+  auth:
+    authType: Password
+    username: $TESTSPACE_USERNAME
+    password: $TESTSPACE_PASSWORD
+`), 0644)
+	config.Requests.SetConfigFile(f)
+	defer config.Requests.SetConfigFile("")
+
+	_, notice, err := SaveRequest(mockRSet("https://mysite.com/users/"), "testspace.users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "replaced Password auth of testspace with Bearer"; notice != want {
+		t.Errorf("notice: got %v - want %v", notice, want)
+	}
+}
+
+func TestSaveRequestErrs(t *testing.T) {
+	tests := map[string]struct {
+		rset *RequestSet
+		name string
+	}{
+		"not a url":      {rset: mockRSet("testspace.posts"), name: "testspace.copy"},
+		"no group":       {rset: mockRSet("https://mysite.com"), name: "nogroup"},
+		"unknown scheme": {rset: &RequestSet{URL: "https://mysite.com", HeaderSlice: []string{"Authorization:Digest abc"}}, name: "testspace.x"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, _, err := SaveRequest(tc.rset, tc.name); err == nil {
+				t.Errorf("%v: should err", name)
+			}
+		})
+	}
+}
@@ -0,0 +1,342 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jerempy/brang/config"
+)
+
+// Session keeps cookies and sticky headers between runs, in a file under config.SessionsPath.
+// It is the cookie jar of the requests sent with it. Cookies follow the usual rules: Secure
+// ones are only sent over https and expired ones are dropped. Cookies without an expiry
+// last until the session is cleared.
+type Session struct {
+	Name    string           `json:"-"`
+	Cookies []*SessionCookie `json:"cookies"`
+	// response headers sent back on later requests, with the last value seen. ex: X-CSRF-Token
+	Headers map[string]string `json:"headers"`
+	// host of the response that set each sticky header, the only one it is sent back to
+	HeaderHosts map[string]string `json:"headerHosts,omitempty"`
+	mu          sync.Mutex
+}
+
+// SessionCookie is a cookie as stored by a Session
+type SessionCookie struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+	// sent to Domain and its subdomains, or to Domain only when HostOnly
+	Domain   string `json:"domain"`
+	HostOnly bool   `json:"hostOnly,omitempty"`
+	Path     string `json:"path"`
+	// zero for a cookie without an expiry
+	Expires  time.Time `json:"expires,omitempty"`
+	Secure   bool      `json:"secure,omitempty"`
+	HttpOnly bool      `json:"httpOnly,omitempty"`
+}
+
+// GroupSession is the session: of a group in requests.yaml, either a name or
+//
+//	session:
+//	  name: mysite
+//	  headers: [X-CSRF-Token] # sticky headers
+type GroupSession struct {
+	Name    string   `yaml:"name,omitempty"`
+	Headers []string `yaml:"headers,omitempty"`
+}
+
+func sessionFile(name string) string {
+	return filepath.Join(config.SessionsPath, name+".json")
+}
+
+// LoadSession reads the session of name. A session not yet saved is empty.
+func LoadSession(name string) (*Session, error) {
+	if err := checkStoreName("session", name); err != nil {
+		return nil, err
+	}
+	s := &Session{Name: name, Headers: map[string]string{}, HeaderHosts: map[string]string{}}
+	b, err := os.ReadFile(sessionFile(name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("err reading session %s: %w", name, err)
+	}
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, fmt.Errorf("err reading session %s: %w", name, err)
+	}
+	if s.Headers == nil {
+		s.Headers = map[string]string{}
+	}
+	if s.HeaderHosts == nil {
+		s.HeaderHosts = map[string]string{}
+	}
+	s.dropExpired(time.Now())
+	return s, nil
+}
+
+// Save writes the session to its file
+func (s *Session) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(config.SessionsPath, 0700); err != nil {
+		return err
+	}
+	// cookies are often logins, so only the user can read them
+	return os.WriteFile(sessionFile(s.Name), b, 0600)
+}
+
+// ClearSession removes the session's cookies and headers
+func ClearSession(name string) error {
+	if err := checkStoreName("session", name); err != nil {
+		return err
+	}
+	err := os.Remove(sessionFile(name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// SessionNames lists the saved sessions
+func SessionNames() ([]string, error) {
+	m, err := filepath.Glob(filepath.Join(config.SessionsPath, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, f := range m {
+		names = append(names, strings.TrimSuffix(filepath.Base(f), ".json"))
+	}
+	return names, nil
+}
+
+// Makes the headers sticky: once a response sets one, it is sent on later requests
+func (s *Session) stick(headers []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, h := range headers {
+		h = http.CanonicalHeaderKey(strings.TrimSpace(h))
+		if _, ok := s.Headers[h]; !ok && h != "" {
+			s.Headers[h] = ""
+		}
+	}
+}
+
+// sessionJar is the http.CookieJar of a Session
+type sessionJar struct{ *Session }
+
+// SetCookies keeps the cookies of a response from u
+func (j sessionJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	s := j.Session
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	host := strings.ToLower(u.Hostname())
+	now := time.Now()
+	for _, c := range cookies {
+		sc := &SessionCookie{Name: c.Name, Value: c.Value, Path: c.Path, Secure: c.Secure, HttpOnly: c.HttpOnly}
+		sc.Domain = strings.TrimPrefix(strings.ToLower(c.Domain), ".")
+		if sc.Domain == "" {
+			sc.Domain, sc.HostOnly = host, true
+		} else if !domainMatch(host, sc.Domain) {
+			// a site can't set cookies for another
+			continue
+		}
+		if sc.Secure && u.Scheme != "https" {
+			continue
+		}
+		if !strings.HasPrefix(sc.Path, "/") {
+			sc.Path = defaultCookiePath(u.Path)
+		}
+		remove := false
+		switch {
+		case c.MaxAge < 0:
+			remove = true
+		case c.MaxAge > 0:
+			sc.Expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+		case !c.Expires.IsZero():
+			sc.Expires = c.Expires
+			remove = !c.Expires.After(now)
+		}
+		s.removeCookie(sc)
+		if !remove {
+			s.Cookies = append(s.Cookies, sc)
+		}
+	}
+}
+
+// Removes the cookie of the same name, domain and path as c
+func (s *Session) removeCookie(c *SessionCookie) {
+	kept := s.Cookies[:0]
+	for _, o := range s.Cookies {
+		if o.Name != c.Name || o.Domain != c.Domain || o.Path != c.Path {
+			kept = append(kept, o)
+		}
+	}
+	s.Cookies = kept
+}
+
+// Cookies returns the cookies to send to u
+func (j sessionJar) Cookies(u *url.URL) []*http.Cookie {
+	s := j.Session
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dropExpired(time.Now())
+	host := strings.ToLower(u.Hostname())
+	var found []*SessionCookie
+	for _, c := range s.Cookies {
+		if c.HostOnly && host != c.Domain || !c.HostOnly && !domainMatch(host, c.Domain) {
+			continue
+		}
+		if !pathMatch(u.Path, c.Path) || c.Secure && u.Scheme != "https" {
+			continue
+		}
+		found = append(found, c)
+	}
+	// cookies with longer paths go first
+	sort.SliceStable(found, func(i, j int) bool { return len(found[i].Path) > len(found[j].Path) })
+	cookies := make([]*http.Cookie, len(found))
+	for i, c := range found {
+		cookies[i] = &http.Cookie{Name: c.Name, Value: c.Value}
+	}
+	return cookies
+}
+
+func (s *Session) dropExpired(now time.Time) {
+	kept := s.Cookies[:0]
+	for _, c := range s.Cookies {
+		if c.Expires.IsZero() || c.Expires.After(now) {
+			kept = append(kept, c)
+		}
+	}
+	s.Cookies = kept
+}
+
+// A host matches a domain when it is the domain or a subdomain of it. IPs only match themselves.
+func domainMatch(host, domain string) bool {
+	if host == domain {
+		return true
+	}
+	return net.ParseIP(host) == nil && strings.HasSuffix(host, "."+domain)
+}
+
+func pathMatch(reqPath, cookiePath string) bool {
+	if reqPath == "" {
+		reqPath = "/"
+	}
+	if reqPath == cookiePath {
+		return true
+	}
+	return strings.HasPrefix(reqPath, cookiePath) &&
+		(strings.HasSuffix(cookiePath, "/") || reqPath[len(cookiePath)] == '/')
+}
+
+// The path of a cookie that doesn't set one is the directory of the request path
+func defaultCookiePath(p string) string {
+	i := strings.LastIndex(p, "/")
+	if i <= 0 {
+		return "/"
+	}
+	return p[:i]
+}
+
+// sessionTransport sends the sticky headers of its session and keeps their values from responses.
+// Like a host-only cookie, a sticky header is only sent to the host that set it, so a token
+// isn't leaked on redirects to other hosts or to other groups sharing the session.
+type sessionTransport struct {
+	session *Session
+	base    http.RoundTripper
+}
+
+func (st *sessionTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	s := st.session
+	host := strings.ToLower(r.URL.Host)
+	s.mu.Lock()
+	var add http.Header
+	for k, v := range s.Headers {
+		if v != "" && s.HeaderHosts[k] == host && r.Header.Get(k) == "" {
+			if add == nil {
+				r = r.Clone(r.Context())
+				add = r.Header
+			}
+			add.Set(k, v)
+		}
+	}
+	s.mu.Unlock()
+	resp, err := st.base.RoundTrip(r)
+	if err != nil {
+		return resp, err
+	}
+	s.mu.Lock()
+	for k := range s.Headers {
+		if v := resp.Header.Get(k); v != "" {
+			s.Headers[k] = v
+			if s.HeaderHosts == nil {
+				s.HeaderHosts = map[string]string{}
+			}
+			s.HeaderHosts[k] = host
+		}
+	}
+	s.mu.Unlock()
+	return resp, nil
+}
+
+// Returns a copy of c that uses the session for cookies and sticky headers. The copy
+// shares the transport of c, so connections are still reused.
+func (c *brangClient) withSession(s *Session) *brangClient {
+	if s == nil {
+		return c
+	}
+	hc := *c.Client
+	hc.Jar = sessionJar{s}
+	base := hc.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	hc.Transport = &sessionTransport{s, base}
+	return &brangClient{&hc}
+}
+
+// ActiveSession returns the session to send the request with: --session, else session: of its group.
+// The sticky headers of both are added to it. nil when there is no session. Sessions in
+// loaded are reused, so requests sent together share one; new ones are added to it.
+func (rset *RequestSet) ActiveSession(loaded map[string]*Session) (*Session, error) {
+	name := rset.Session
+	if name == "" {
+		name = rset.groupSession.Name
+	}
+	if name == "" {
+		if len(rset.SessionHeaders) > 0 {
+			return nil, fmt.Errorf("--session-header needs a session. set one with --session or session: on the group")
+		}
+		return nil, nil
+	}
+	s, ok := loaded[name]
+	if !ok {
+		var err error
+		if s, err = LoadSession(name); err != nil {
+			return nil, err
+		}
+		if loaded != nil {
+			loaded[name] = s
+		}
+	}
+	s.stick(rset.groupSession.Headers)
+	s.stick(rset.SessionHeaders)
+	return s, nil
+}
@@ -0,0 +1,190 @@
+package client
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jerempy/brang/config"
+)
+
+func TestSessionJar(t *testing.T) {
+	future := time.Now().Add(time.Hour)
+	tests := map[string]struct {
+		from    string
+		cookies []*http.Cookie
+		to      string
+		want    []string
+	}{
+		"same host": {
+			from: "https://mysite.com/login", cookies: []*http.Cookie{{Name: "sid", Value: "1"}},
+			to: "https://mysite.com/", want: []string{"sid=1"},
+		},
+		"host only not to subdomain": {
+			from: "https://mysite.com/", cookies: []*http.Cookie{{Name: "sid", Value: "1"}},
+			to: "https://api.mysite.com/", want: nil,
+		},
+		"domain to subdomain": {
+			from: "https://mysite.com/", cookies: []*http.Cookie{{Name: "sid", Value: "1", Domain: ".mysite.com"}},
+			to: "https://api.mysite.com/", want: []string{"sid=1"},
+		},
+		"other domain refused": {
+			from: "https://mysite.com/", cookies: []*http.Cookie{{Name: "sid", Value: "1", Domain: "other.com"}},
+			to: "https://other.com/", want: nil,
+		},
+		"secure not over http": {
+			from: "https://mysite.com/", cookies: []*http.Cookie{{Name: "sid", Value: "1", Secure: true}},
+			to: "http://mysite.com/", want: nil,
+		},
+		"secure not set over http": {
+			from: "http://mysite.com/", cookies: []*http.Cookie{{Name: "sid", Value: "1", Secure: true}},
+			to: "https://mysite.com/", want: nil,
+		},
+		"path": {
+			from: "https://mysite.com/", cookies: []*http.Cookie{{Name: "a", Value: "1", Path: "/api"}},
+			to: "https://mysite.com/apis", want: nil,
+		},
+		"default path": {
+			from: "https://mysite.com/api/login", cookies: []*http.Cookie{{Name: "a", Value: "1"}},
+			to: "https://mysite.com/api/users", want: []string{"a=1"},
+		},
+		"longer path first": {
+			from: "https://mysite.com/", cookies: []*http.Cookie{{Name: "a", Value: "1", Path: "/"}, {Name: "a", Value: "2", Path: "/api"}},
+			to: "https://mysite.com/api/users", want: []string{"a=2", "a=1"},
+		},
+		"expired": {
+			from: "https://mysite.com/", cookies: []*http.Cookie{{Name: "a", Value: "1", Expires: time.Now().Add(-time.Hour)}},
+			to: "https://mysite.com/", want: nil,
+		},
+		"expires later": {
+			from: "https://mysite.com/", cookies: []*http.Cookie{{Name: "a", Value: "1", Expires: future}},
+			to: "https://mysite.com/", want: []string{"a=1"},
+		},
+		"max age removes": {
+			from: "https://mysite.com/", cookies: []*http.Cookie{{Name: "a", Value: "1"}, {Name: "a", MaxAge: -1}},
+			to: "https://mysite.com/", want: nil,
+		},
+		"replaced": {
+			from: "https://mysite.com/", cookies: []*http.Cookie{{Name: "a", Value: "1"}, {Name: "a", Value: "2"}},
+			to: "https://mysite.com/", want: []string{"a=2"},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			j := sessionJar{&Session{Headers: map[string]string{}}}
+			from, _ := url.Parse(tc.from)
+			to, _ := url.Parse(tc.to)
+			j.SetCookies(from, tc.cookies)
+			got := j.Cookies(to)
+			if len(got) != len(tc.want) {
+				t.Fatalf("got %v - want %v", got, tc.want)
+			}
+			for i, c := range got {
+				if c.String() != tc.want[i] {
+					t.Errorf("got %v - want %v", got, tc.want)
+				}
+			}
+		})
+	}
+}
+
+func TestSessionPersists(t *testing.T) {
+	defer func(old string) { config.SessionsPath = old }(config.SessionsPath)
+	config.SessionsPath = t.TempDir()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "sid", Value: "abc", HttpOnly: true})
+			w.Header().Set("X-CSRF-Token", "csrf-1")
+		case "/me":
+			c, err := r.Cookie("sid")
+			if err != nil || c.Value != "abc" || r.Header.Get("X-CSRF-Token") != "csrf-1" {
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+		}
+	}))
+	defer ts.Close()
+	// another host, which a redirect of ts leads to
+	var leaked string
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		leaked = r.Header.Get("X-CSRF-Token")
+	}))
+	defer other.Close()
+	send := func(path string) int {
+		u := ts.URL + path
+		if strings.HasPrefix(path, "http") {
+			u = path
+		}
+		rset := &RequestSet{URL: u, Session: "testspace", SessionHeaders: []string{"x-csrf-token"}}
+		req, err := rset.Prepare()
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := rset.ActiveSession(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := NewClient().withSession(s).do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Save(); err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode
+	}
+	if got := send("/me"); got != http.StatusUnauthorized {
+		t.Errorf("before login: got %v - want 401", got)
+	}
+	send("/login")
+	if got := send("/me"); got != http.StatusOK {
+		t.Errorf("with the session: got %v - want 200", got)
+	}
+	if send(other.URL + "/me"); leaked != "" {
+		t.Errorf("sticky header on another host: got %v - want it only sent to the host that set it", leaked)
+	}
+	if names, _ := SessionNames(); len(names) != 1 || names[0] != "testspace" {
+		t.Errorf("got %v - want [testspace]", names)
+	}
+	ClearSession("testspace")
+	if got := send("/me"); got != http.StatusUnauthorized {
+		t.Errorf("after clear: got %v - want 401", got)
+	}
+	if _, err := LoadSession("../escape"); err == nil {
+		t.Error("should err for a name with a path")
+	}
+}
+
+func TestGroupSession(t *testing.T) {
+	config.Requests.ReadConfig(bytes.NewBufferString(`
+testspace:
+  session: mine
+testspace2:
+  session:
+    name: other
+    headers: [X-CSRF-Token]
+`))
+	tests := map[string]struct {
+		group string
+		want  GroupSession
+	}{
+		"name":         {group: "testspace", want: GroupSession{Name: "mine"}},
+		"with headers": {group: "testspace2", want: GroupSession{Name: "other", Headers: []string{"X-CSRF-Token"}}},
+		"none":         {group: "testspace3"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			g, err := loadGroup(tc.group)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if g.Session.Name != tc.want.Name || len(g.Session.Headers) != len(tc.want.Headers) {
+				t.Errorf("got %+v - want %+v", g.Session, tc.want)
+			}
+		})
+	}
+}
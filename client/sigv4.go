@@ -0,0 +1,202 @@
+package client
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	sigV4Algorithm  = "AWS4-HMAC-SHA256"
+	sigV4TimeFormat = "20060102T150405Z"
+)
+
+// sigV4Signer signs requests with AWS Signature Version 4, for API Gateway, S3 and the
+// stores compatible with it such as MinIO
+type sigV4Signer struct {
+	accessKey, secretKey, sessionToken, region, service string
+}
+
+// Returns the signer of the AWSSigV4 auth a, with its $ENV values filled in
+func newSigV4Signer(a Auth, group string) (*sigV4Signer, error) {
+	s := &sigV4Signer{checkEnv(a.AccessKey), checkEnv(a.SecretKey), checkEnv(a.SessionToken), checkEnv(a.Region), checkEnv(a.Service)}
+	if s.accessKey == "" || s.secretKey == "" || s.region == "" || s.service == "" {
+		return nil, fmt.Errorf("AWSSigV4 auth of %s needs an accessKey, secretKey, region and service", group)
+	}
+	return s, nil
+}
+
+// Signs req, whose body is body, at t: the X-Amz-* headers are set, then all the headers of
+// req and its host are signed in its Authorization header. Headers the client adds when
+// sending, such as User-Agent and cookies, aren't signed, which AWS allows.
+func (s *sigV4Signer) sign(req *http.Request, body []byte, t time.Time) {
+	t = t.UTC()
+	amzDate, date := t.Format(sigV4TimeFormat), t.Format("20060102")
+	sum := sha256.Sum256(body)
+	payload := hex.EncodeToString(sum[:])
+	req.Header.Del("Authorization")
+	req.Header.Set("X-Amz-Date", amzDate)
+	if s.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.sessionToken)
+	}
+	// only s3 wants the hash of the payload sent as a header
+	if s.service == "s3" {
+		req.Header.Set("X-Amz-Content-Sha256", payload)
+	}
+
+	headers := map[string]string{"host": sigV4Host(req)}
+	for k, v := range req.Header {
+		vals := make([]string, len(v))
+		for i, val := range v {
+			vals[i] = strings.Join(strings.Fields(val), " ")
+		}
+		headers[strings.ToLower(k)] = strings.Join(vals, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonHeaders strings.Builder
+	for _, k := range names {
+		canonHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signed := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{
+		req.Method,
+		s.canonicalPath(req.URL),
+		canonicalQuery(req.URL),
+		canonHeaders.String(),
+		signed,
+		payload,
+	}, "\n")
+	scope := strings.Join([]string{date, s.region, s.service, "aws4_request"}, "/")
+	reqSum := sha256.Sum256([]byte(canonical))
+	toSign := strings.Join([]string{sigV4Algorithm, amzDate, scope, hex.EncodeToString(reqSum[:])}, "\n")
+
+	key := []byte("AWS4" + s.secretKey)
+	for _, v := range []string{date, s.region, s.service, "aws4_request"} {
+		key = hmacSHA256(key, v)
+	}
+	sig := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s", sigV4Algorithm, s.accessKey, scope, signed, sig))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// Returns the host the request is sent to, without the default port of its scheme
+func sigV4Host(req *http.Request) string {
+	h := req.Host
+	if h == "" {
+		h = req.URL.Host
+	}
+	if req.URL.Scheme == "http" {
+		return strings.TrimSuffix(h, ":80")
+	}
+	return strings.TrimSuffix(h, ":443")
+}
+
+// Returns the path of u with each segment escaped. Services other than s3 escape it twice
+func (s *sigV4Signer) canonicalPath(u *url.URL) string {
+	p := u.Path
+	if p == "" {
+		return "/"
+	}
+	segs := strings.Split(p, "/")
+	for i, seg := range segs {
+		segs[i] = sigV4Escape(seg)
+		if s.service != "s3" {
+			segs[i] = sigV4Escape(segs[i])
+		}
+	}
+	return strings.Join(segs, "/")
+}
+
+// Returns the query of u sorted by name then value, escaped the way AWS does
+func canonicalQuery(u *url.URL) string {
+	q, _ := url.ParseQuery(u.RawQuery)
+	esc := map[string][]string{}
+	keys := make([]string, 0, len(q))
+	for k, vals := range q {
+		ek := sigV4Escape(k)
+		keys = append(keys, ek)
+		for _, v := range vals {
+			esc[ek] = append(esc[ek], sigV4Escape(v))
+		}
+	}
+	sort.Strings(keys)
+	var pairs []string
+	for _, k := range keys {
+		sort.Strings(esc[k])
+		for _, v := range esc[k] {
+			pairs = append(pairs, k+"="+v)
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// Escapes all but the unreserved characters of RFC 3986, as AWS wants
+func sigV4Escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// sigV4Transport signs each request again as it is sent, for requests sent more than once
+// such as by bench, since AWS rejects a signature made more than 5 minutes before
+type sigV4Transport struct {
+	signer *sigV4Signer
+	base   http.RoundTripper
+}
+
+func (t *sigV4Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	var body []byte
+	if r.Body != nil && r.Body != http.NoBody {
+		if r.GetBody == nil {
+			return nil, fmt.Errorf("can't sign a body that can't be read again")
+		}
+		rc, err := r.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		if body, err = io.ReadAll(rc); err != nil {
+			return nil, err
+		}
+	}
+	r2 := r.Clone(r.Context())
+	t.signer.sign(r2, body, time.Now())
+	return t.base.RoundTrip(r2)
+}
+
+// Returns a copy of c signing each request with s, or c when s is nil
+func (c *brangClient) withSigV4(s *sigV4Signer) *brangClient {
+	if s == nil {
+		return c
+	}
+	hc := *c.Client
+	base := hc.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	hc.Transport = &sigV4Transport{s, base}
+	return &brangClient{&hc}
+}
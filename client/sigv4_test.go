@@ -0,0 +1,139 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jerempy/brang/config"
+)
+
+// from the AWS Signature Version 4 test suite
+func TestSigV4Sign(t *testing.T) {
+	at := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	s := &sigV4Signer{accessKey: "AKIDEXAMPLE", secretKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", region: "us-east-1", service: "service"}
+	tests := map[string]struct {
+		url  string
+		want string
+	}{
+		"get-vanilla": {"https://example.amazonaws.com/",
+			"5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"},
+		"get-vanilla-query-order-key-case": {"https://example.amazonaws.com/?Param2=value2&Param1=value1",
+			"b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, tc.url, nil)
+			s.sign(req, nil, at)
+			want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=" + tc.want
+			if got := req.Header.Get("Authorization"); got != want {
+				t.Errorf("got %v - want %v", got, want)
+			}
+			if req.Header.Get("X-Amz-Content-Sha256") != "" {
+				t.Error("should have no X-Amz-Content-Sha256 header for a service other than s3")
+			}
+		})
+	}
+}
+
+func TestSigV4SavedRequest(t *testing.T) {
+	t.Setenv("TESTSPACE_AWS_SECRET", "s3cret")
+	config.Requests.ReadConfig(bytes.NewBufferString(`
+testspace:
+  baseUrl: http://localhost:9000
+  auth:
+    authType: AWSSigV4
+    accessKey: minio
+    secretKey: $TESTSPACE_AWS_SECRET
+    sessionToken: sess
+    region: us-east-1
+    service: s3
+  requests:
+    put:
+      url: /bucket/my file.txt
+      method: PUT
+      body: hello
+`))
+	rset := mockRSet("testspace.put")
+	rset.Method = ""
+	req, err := LoadSavedRequest(rset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := req.Header.Get("Authorization")
+	date := time.Now().UTC().Format("20060102")
+	if !strings.HasPrefix(auth, fmt.Sprintf("AWS4-HMAC-SHA256 Credential=minio/%s/us-east-1/s3/aws4_request, SignedHeaders=accept;content-type;host;x-amz-content-sha256;x-amz-date;x-amz-security-token, Signature=", date)) {
+		t.Errorf("Authorization: got %v - want a signature of minio for us-east-1 s3 of the signed headers", auth)
+	}
+	// sha256 of hello
+	if got := req.Header.Get("X-Amz-Content-Sha256"); got != "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824" {
+		t.Errorf("X-Amz-Content-Sha256: got %v - want the hash of hello", got)
+	}
+	if got := req.Header.Get("X-Amz-Security-Token"); got != "sess" {
+		t.Errorf("X-Amz-Security-Token: got %v - want sess", got)
+	}
+	if got := headerToStringForPrint(&req.Header); strings.Contains(got, "sess") || strings.Contains(got, "Signature") || !strings.Contains(got, "X-Amz-Date") {
+		t.Errorf("got %v - want the signed headers with creds redacted", got)
+	}
+
+	config.Requests.ReadConfig(bytes.NewBufferString(`
+testspace:
+  auth:
+    authType: AWSSigV4
+    accessKey: minio
+  requests:
+    put: http://localhost:9000/bucket
+`))
+	if _, err := LoadSavedRequest(mockRSet("testspace.put")); err == nil {
+		t.Error("should err for an auth without secretKey, region and service")
+	}
+}
+
+func TestSigV4Replay(t *testing.T) {
+	t.Setenv("TESTSPACE_AWS_SECRET", "s3cret")
+	config.Requests.ReadConfig(bytes.NewBufferString(`
+testspace:
+  baseUrl: http://localhost:9000
+  auth:
+    authType: AWSSigV4
+    accessKey: minio
+    secretKey: $TESTSPACE_AWS_SECRET
+    sessionToken: sess
+    region: us-east-1
+    service: s3
+  requests:
+    put:
+      url: /bucket/a.txt
+      method: PUT
+      body: hello
+`))
+	old := "20200101T000000Z"
+	e := &HistoryEntry{Name: "testspace.put", Method: "PUT", URL: "http://localhost:9000/bucket/a.txt", Body: "hello", Header: http.Header{
+		"Authorization":        {redacted},
+		"X-Amz-Date":           {old},
+		"X-Amz-Content-Sha256": {"2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"},
+		"X-Amz-Security-Token": {redacted},
+		"X-Trace":              {"1"},
+	}}
+	req, err := (&RequestSet{}).replayRequest(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	at, err := time.Parse(sigV4TimeFormat, req.Header.Get("X-Amz-Date"))
+	if err != nil || req.Header.Get("X-Amz-Date") == old {
+		t.Fatalf("X-Amz-Date: got %q %v - want a new one", req.Header.Get("X-Amz-Date"), err)
+	}
+	// the signature is of the headers sent, at their date
+	want := req.Clone(req.Context())
+	s, _ := newSigV4Signer(Auth{AccessKey: "minio", SecretKey: "s3cret", SessionToken: "sess", Region: "us-east-1", Service: "s3"}, "testspace")
+	s.sign(want, []byte("hello"), at)
+	if got := req.Header.Get("Authorization"); got != want.Header.Get("Authorization") || !strings.Contains(got, "x-trace") {
+		t.Errorf("got %v - want %v", got, want.Header.Get("Authorization"))
+	}
+	if req.Header.Get("X-Amz-Security-Token") != "sess" {
+		t.Errorf("got %v - want the session token sess", req.Header)
+	}
+}
@@ -0,0 +1,294 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jerempy/brang/config"
+)
+
+// SnapshotSettings are the snapshot: settings of a group in requests.yaml
+//
+//	snapshot:
+//	  headers: [Content-Type, Cache-Control] # headers recorded. default Content-Type
+//	  redact: ['$..id', '$.meta.generatedAt'] # json paths of values that change on every response
+type SnapshotSettings struct {
+	Headers []string `yaml:"headers,omitempty"`
+	Redact  []string `yaml:"redact,omitempty"`
+}
+
+// Snapshot is the recorded response of a saved request, which later responses are checked against
+type Snapshot struct {
+	Status int               `json:"status"`
+	Header map[string]string `json:"header,omitempty"`
+	// the json body, with redacted values, or the body as a string when it isn't json
+	Body interface{} `json:"body"`
+}
+
+var defaultSnapshotHeaders = []string{"Content-Type"}
+
+// Returns the snapshot file of the group
+func snapshotFile(group string) (string, error) {
+	if err := checkStoreName("group", group); err != nil {
+		return "", err
+	}
+	return filepath.Join(config.SnapshotsPath, group+".json"), nil
+}
+
+// LoadSnapshots reads the snapshots of the group's saved requests, by name.
+// A group without any gives an empty map.
+func LoadSnapshots(group string) (map[string]*Snapshot, error) {
+	snaps := map[string]*Snapshot{}
+	f, err := snapshotFile(group)
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(f)
+	if errors.Is(err, fs.ErrNotExist) {
+		return snaps, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("err reading snapshots of %s: %w", group, err)
+	}
+	if err := json.Unmarshal(b, &snaps); err != nil {
+		return nil, fmt.Errorf("err reading snapshots of %s: %w", group, err)
+	}
+	return snaps, nil
+}
+
+func saveSnapshots(group string, snaps map[string]*Snapshot) error {
+	f, err := snapshotFile(group)
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(snaps, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(config.SnapshotsPath, 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(f, append(b, '\n'), 0600)
+}
+
+// SnapshotOptions are added to the snapshot: settings of each group
+type SnapshotOptions struct {
+	Headers []string
+	Redact  []string
+	// records the snapshots of responses that changed, or have none yet, when checking
+	Update bool
+}
+
+// SnapshotResult is how the response of one saved request compared to its snapshot
+type SnapshotResult struct {
+	Name string
+	// ok|changed|new when checking, recorded|updated when writing the snapshot
+	Outcome string
+	Diff    *ResponseDiff
+	Err     error
+}
+
+// Failed is true when the request couldn't be sent, or its response didn't match its snapshot
+func (r *SnapshotResult) Failed() bool {
+	return r.Err != nil || r.Outcome == "changed" || r.Outcome == "new"
+}
+
+// RecordSnapshots sends the saved requests with rn and records their responses as snapshots
+func RecordSnapshots(rn *Runner, names []string, o SnapshotOptions) []*SnapshotResult {
+	return runSnapshots(rn, names, o, true)
+}
+
+// CheckSnapshots sends the saved requests with rn and compares their responses to their snapshots
+func CheckSnapshots(rn *Runner, names []string, o SnapshotOptions) []*SnapshotResult {
+	return runSnapshots(rn, names, o, false)
+}
+
+func runSnapshots(rn *Runner, names []string, o SnapshotOptions, record bool) []*SnapshotResult {
+	results := make([]*SnapshotResult, len(names))
+	type groupSnaps struct {
+		snaps    map[string]*Snapshot
+		settings SnapshotSettings
+		redact   []*jsonPath
+		changed  bool
+		err      error
+	}
+	groups := map[string]*groupSnaps{}
+	var order []string
+	for i, r := range rn.Run(names) {
+		res := &SnapshotResult{Name: r.Name, Err: r.Err}
+		results[i] = res
+		path, err := config.ParseRequestPath(r.Name)
+		if err != nil {
+			res.Err = err
+			continue
+		}
+		gs, ok := groups[path[0]]
+		if !ok {
+			gs = &groupSnaps{}
+			groups[path[0]] = gs
+			order = append(order, path[0])
+			gs.snaps, gs.err = LoadSnapshots(path[0])
+			if gs.err == nil {
+				gs.settings, gs.redact, gs.err = snapshotSettings(path[0], o)
+			}
+		}
+		if res.Err == nil {
+			res.Err = gs.err
+		}
+		if res.Err != nil {
+			continue
+		}
+		live := newSnapshot(r.Response, gs.settings.Headers, gs.redact)
+		old, ok := gs.snaps[r.Name]
+		switch {
+		case record:
+			res.Outcome = "recorded"
+		case !ok:
+			res.Outcome = "new"
+		default:
+			if res.Diff, res.Err = DiffResponses(old.side("snapshot"), live.side("live"), nil); res.Err != nil {
+				continue
+			}
+			res.Outcome = "ok"
+			if !res.Diff.Same() {
+				res.Outcome = "changed"
+			}
+		}
+		if record || o.Update && res.Failed() {
+			if res.Outcome != "recorded" {
+				res.Outcome = "updated"
+			}
+			gs.snaps[r.Name] = live
+			gs.changed = true
+		}
+	}
+	for _, g := range order {
+		gs := groups[g]
+		if !gs.changed {
+			continue
+		}
+		if err := saveSnapshots(g, gs.snaps); err != nil {
+			for _, res := range results {
+				if p, _ := config.ParseRequestPath(res.Name); len(p) > 0 && p[0] == g && res.Err == nil {
+					res.Err = fmt.Errorf("err saving snapshots of %s: %w", g, err)
+				}
+			}
+		}
+	}
+	return results
+}
+
+// Returns the snapshot: settings of the group with those of o added, and its redact rules parsed
+func snapshotSettings(group string, o SnapshotOptions) (SnapshotSettings, []*jsonPath, error) {
+	g, err := loadGroup(group)
+	if err != nil {
+		return SnapshotSettings{}, nil, err
+	}
+	s := g.Snapshot
+	s.Headers = append(s.Headers, o.Headers...)
+	if len(s.Headers) == 0 {
+		s.Headers = defaultSnapshotHeaders
+	}
+	s.Redact = append(s.Redact, o.Redact...)
+	var redact []*jsonPath
+	for _, r := range s.Redact {
+		jp, err := parseJSONPath(r)
+		if err != nil {
+			return s, nil, fmt.Errorf("snapshot redact of %s: %w", group, err)
+		}
+		redact = append(redact, jp)
+	}
+	return s, redact, nil
+}
+
+// Makes the snapshot of a response, with its headers in headers and values at redact redacted
+func newSnapshot(br *BResponse, headers []string, redact []*jsonPath) *Snapshot {
+	s := &Snapshot{Status: br.StatusCode, Body: br.StringResponseBody()}
+	for _, h := range headers {
+		if v := br.Header.Values(h); len(v) > 0 {
+			if s.Header == nil {
+				s.Header = map[string]string{}
+			}
+			s.Header[http.CanonicalHeaderKey(h)] = strings.Join(v, ", ")
+		}
+	}
+	if v, err := decodeJSON([]byte(br.StringResponseBody())); err == nil {
+		s.Body = redactJSON(nil, v, redact)
+	}
+	return s
+}
+
+// Returns v with the values at paths matching redact replaced
+func redactJSON(path []pathStep, v interface{}, redact []*jsonPath) interface{} {
+	// the key stays, so it is still checked that it's there
+	for _, jp := range redact {
+		if jp.matches(path) {
+			return redacted
+		}
+	}
+	switch t := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, c := range t {
+			m[k] = redactJSON(append(path[:len(path):len(path)], pathStep{key: k}), c, redact)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(t))
+		for i, c := range t {
+			l[i] = redactJSON(append(path[:len(path):len(path)], pathStep{index: i, isIndex: true}), c, redact)
+		}
+		return l
+	}
+	return v
+}
+
+// Returns the snapshot as a side of a diff
+func (s *Snapshot) side(source string) *DiffSide {
+	side := &DiffSide{Source: source, Status: s.Status, Header: http.Header{}}
+	for k, v := range s.Header {
+		side.Header.Set(k, v)
+	}
+	if b, ok := s.Body.(string); ok {
+		side.Body = b
+	} else {
+		b, _ := json.Marshal(s.Body)
+		side.Body = string(b)
+	}
+	return side
+}
+
+// WriteSnapshotResults writes how each response compared to its snapshot, with the
+// differences of those that changed, and how many failed
+func WriteSnapshotResults(w io.Writer, results []*SnapshotResult, color bool) {
+	failed := 0
+	for _, r := range results {
+		switch {
+		case r.Err != nil:
+			failed++
+			fmt.Fprintf(w, "FAIL %s - %v\n", r.Name, r.Err)
+		case r.Outcome == "new":
+			failed++
+			fmt.Fprintf(w, "FAIL %s - no snapshot yet. record it with --update or 'brang snapshot record'\n", r.Name)
+		case r.Outcome == "changed":
+			failed++
+			fmt.Fprintf(w, "FAIL %s - response changed\n", r.Name)
+			WriteDiff(w, r.Diff, color)
+		case r.Outcome == "updated" && r.Diff != nil:
+			fmt.Fprintf(w, "updated %s\n", r.Name)
+			WriteDiff(w, r.Diff, color)
+		case r.Outcome == "ok":
+			fmt.Fprintf(w, "ok   %s\n", r.Name)
+		default:
+			fmt.Fprintf(w, "%s %s\n", r.Outcome, r.Name)
+		}
+	}
+	fmt.Fprintf(w, "%d requests, %d failed\n", len(results), failed)
+}
@@ -0,0 +1,91 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/jerempy/brang/config"
+)
+
+func TestRedactJSON(t *testing.T) {
+	v, _ := decodeJSON([]byte(`{"id": 1, "items": [{"id": 2, "at": "t"}], "name": "a"}`))
+	var redact []*jsonPath
+	for _, p := range []string{"$..id", "$.items[*].at"} {
+		jp, _ := parseJSONPath(p)
+		redact = append(redact, jp)
+	}
+	got := jsonString(redactJSON(nil, v, redact))
+	want := `{"id":"REDACTED","items":[{"at":"REDACTED","id":"REDACTED"}],"name":"a"}`
+	if got != want {
+		t.Errorf("got %v - want %v", got, want)
+	}
+}
+
+func TestSnapshots(t *testing.T) {
+	defer func(old string) { config.SnapshotsPath = old }(config.SnapshotsPath)
+	config.SnapshotsPath = t.TempDir()
+	defer func(old string) { config.HistoryPath = old }(config.HistoryPath)
+	config.HistoryPath = t.TempDir()
+	price, calls := 10, 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Request-Id", fmt.Sprint(calls))
+		fmt.Fprintf(w, `{"id": %d, "price": %d}`, calls, price)
+	}))
+	defer ts.Close()
+	config.Requests.ReadConfig(bytes.NewBufferString(fmt.Sprintf(`
+testspace:
+  baseUrl: %v
+  snapshot:
+    redact: ['$.id']
+  requests:
+    item: /item
+    text: /item?v=2
+`, ts.URL)))
+	rn := &Runner{Base: mockRSet(""), Quiet: true}
+	names := []string{"testspace.item"}
+
+	res := CheckSnapshots(rn, names, SnapshotOptions{})
+	if res[0].Outcome != "new" || !res[0].Failed() {
+		t.Errorf("got %+v - want new with no snapshot yet", res[0])
+	}
+	res = RecordSnapshots(rn, names, SnapshotOptions{})
+	if res[0].Outcome != "recorded" || res[0].Failed() {
+		t.Fatalf("got %+v - want recorded", res[0])
+	}
+	snaps, _ := LoadSnapshots("testspace")
+	if s := snaps["testspace.item"]; s == nil || s.Status != 200 || s.Header["Content-Type"] != "application/json" || len(s.Header) != 1 {
+		t.Errorf("got %+v - want a 200 with only the Content-Type header", s)
+	}
+
+	// the id differs on each response, but is redacted
+	if res = CheckSnapshots(rn, names, SnapshotOptions{}); res[0].Outcome != "ok" {
+		t.Errorf("got %+v %+v - want ok", res[0], res[0].Diff)
+	}
+	price = 12
+	res = CheckSnapshots(rn, names, SnapshotOptions{})
+	if res[0].Outcome != "changed" || len(res[0].Diff.Body) != 1 || res[0].Diff.Body[0].Path != "$.price" {
+		t.Errorf("got %+v - want $.price changed", res[0].Diff)
+	}
+	var out bytes.Buffer
+	WriteSnapshotResults(&out, res, false)
+	if !strings.Contains(out.String(), "~ $.price: 10 -> 12") {
+		t.Errorf("missing the difference in:\n%s", out.String())
+	}
+	if res = CheckSnapshots(rn, names, SnapshotOptions{Update: true}); res[0].Outcome != "updated" || res[0].Failed() {
+		t.Fatalf("got %+v - want updated", res[0])
+	}
+	if res = CheckSnapshots(rn, names, SnapshotOptions{}); res[0].Outcome != "ok" {
+		t.Errorf("after update: got %+v - want ok", res[0].Diff)
+	}
+	f, _ := snapshotFile("testspace")
+	if _, err := os.Stat(f); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,181 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jerempy/brang/config"
+)
+
+// Checks that the name of a group or session is a plain name, so the file it is kept in
+// can't be outside its folder
+func checkStoreName(kind, name string) error {
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return fmt.Errorf("%s name needs to be a plain name like mysite. was given: %s", kind, name)
+	}
+	return nil
+}
+
+// Returns the state file of the group, which holds the values captured from its responses
+func stateFile(group string) (string, error) {
+	if err := checkStoreName("group", group); err != nil {
+		return "", err
+	}
+	return filepath.Join(config.StatePath, group+".json"), nil
+}
+
+// LoadState reads the values captured from responses of the group's requests.
+// A group without any captured values gives an empty Vars.
+func LoadState(group string) (Vars, error) {
+	v := Vars{}
+	f, err := stateFile(group)
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(f)
+	if errors.Is(err, fs.ErrNotExist) {
+		return v, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("err reading state of %s: %w", group, err)
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, fmt.Errorf("err reading state of %s: %w", group, err)
+	}
+	return v, nil
+}
+
+// guards reading and writing state files, for requests sent at once by a Runner
+var stateMu sync.Mutex
+
+// SaveState adds the values to the state of the group, replacing any of the same name
+func SaveState(group string, vals Vars) error {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	f, err := stateFile(group)
+	if err != nil {
+		return err
+	}
+	v, err := LoadState(group)
+	if err != nil {
+		return err
+	}
+	for k, val := range vals {
+		v[k] = val
+	}
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(config.StatePath, 0700); err != nil {
+		return err
+	}
+	// captured values are often tokens, so only the user can read them
+	return os.WriteFile(f, b, 0600)
+}
+
+// ClearState removes all values captured for the group
+func ClearState(group string) error {
+	f, err := stateFile(group)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(f)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// StateGroups lists the groups that have captured values
+func StateGroups() ([]string, error) {
+	m, err := filepath.Glob(filepath.Join(config.StatePath, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var groups []string
+	for _, f := range m {
+		groups = append(groups, strings.TrimSuffix(filepath.Base(f), ".json"))
+	}
+	return groups, nil
+}
+
+// captureHandler captures values from the response into the group's state, per the
+// capture: rules of the saved request. ex: token: $.data.access_token
+type captureHandler struct {
+	*BResponse
+	group string
+	rules map[string]string
+}
+
+func (ch *captureHandler) CaptureResponse(r *http.Response, e error) {
+	ch.BResponse.CaptureResponse(r, e)
+	if e != nil || r == nil {
+		return
+	}
+	vals, err := captureValues(ch.BResponse, ch.rules)
+	if err != nil {
+		ch.AddError(err)
+	}
+	if len(vals) == 0 {
+		return
+	}
+	if err := SaveState(ch.group, vals); err != nil {
+		ch.AddError(fmt.Errorf("err saving captured values: %w", err))
+	}
+}
+
+// Gets the value for each capture rule. A rule is a json path of the body ($.data.id),
+// header:<name> for a response header, or status for the status code.
+func captureValues(br *BResponse, rules map[string]string) (Vars, error) {
+	vals := Vars{}
+	var body interface{}
+	var bodyErr error
+	bodyRead := false
+	var errs []string
+	for name, rule := range rules {
+		switch {
+		case rule == "status":
+			vals[name] = strconv.Itoa(br.StatusCode)
+		case strings.HasPrefix(rule, "header:"):
+			h := strings.TrimSpace(strings.TrimPrefix(rule, "header:"))
+			if v := br.Header.Get(h); v != "" {
+				vals[name] = v
+			} else {
+				errs = append(errs, fmt.Sprintf("%s: no %s header in response", name, h))
+			}
+		default:
+			jp, err := parseJSONPath(rule)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+				continue
+			}
+			if !bodyRead {
+				body, bodyErr = decodeJSON([]byte(br.StringResponseBody()))
+				bodyRead = true
+			}
+			if bodyErr != nil {
+				errs = append(errs, fmt.Sprintf("%s: %v", name, bodyErr))
+				continue
+			}
+			found := jp.Find(body)
+			if len(found) == 0 {
+				errs = append(errs, fmt.Sprintf("%s: nothing found at %s", name, rule))
+				continue
+			}
+			vals[name] = jsonString(found[0])
+		}
+	}
+	if len(errs) > 0 {
+		return vals, fmt.Errorf("err capturing values: %s", strings.Join(errs, "; "))
+	}
+	return vals, nil
+}
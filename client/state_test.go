@@ -0,0 +1,110 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jerempy/brang/config"
+)
+
+func TestStateSaveClear(t *testing.T) {
+	defer func(old string) { config.StatePath = old }(config.StatePath)
+	config.StatePath = t.TempDir()
+	if err := SaveState("testspace", Vars{"token": "abc"}); err != nil {
+		t.Fatal(err)
+	}
+	SaveState("testspace", Vars{"id": "1"})
+	got, err := LoadState("testspace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["token"] != "abc" || got["id"] != "1" {
+		t.Errorf("got %v - want token and id", got)
+	}
+	if groups, _ := StateGroups(); len(groups) != 1 || groups[0] != "testspace" {
+		t.Errorf("groups: got %v - want [testspace]", groups)
+	}
+	ClearState("testspace")
+	if got, _ := LoadState("testspace"); len(got) != 0 {
+		t.Errorf("should be cleared. got %v", got)
+	}
+}
+
+func TestStoreNames(t *testing.T) {
+	dir := t.TempDir()
+	for _, p := range []*string{&config.StatePath, &config.SessionsPath, &config.ImportsPath, &config.SnapshotsPath, &config.TokensPath} {
+		defer func(p *string, old string) { *p = old }(p, *p)
+		*p = filepath.Join(dir, "store")
+	}
+	// a file of another store, which the names below reach from the store folder
+	outside := filepath.Join(dir, "x.json")
+	os.WriteFile(outside, []byte("{}"), 0600)
+	for _, name := range []string{"../x", "..", "", "a/b", ".hidden"} {
+		t.Run(name, func(t *testing.T) {
+			ops := map[string]error{
+				"clear state":    ClearState(name),
+				"save state":     SaveState(name, Vars{"a": "1"}),
+				"logout":         Logout(name),
+				"clear session":  ClearSession(name),
+				"save snapshots": saveSnapshots(name, map[string]*Snapshot{}),
+				"save tokens":    saveTokens(name, map[string]*oauthToken{}),
+			}
+			_, ops["load state"] = LoadState(name)
+			_, ops["load import"] = loadImportState(name)
+			_, ops["load snapshots"] = LoadSnapshots(name)
+			for op, err := range ops {
+				if err == nil {
+					t.Errorf("%s: should err for %q", op, name)
+				}
+			}
+		})
+	}
+	if _, err := os.Stat(outside); err != nil {
+		t.Errorf("file outside the store should be kept: got %v", err)
+	}
+}
+
+func TestCaptureHandler(t *testing.T) {
+	defer func(old string) { config.StatePath = old }(config.StatePath)
+	config.StatePath = t.TempDir()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-CSRF-Token", "csrf-123")
+		fmt.Fprint(w, `{"data": {"access_token": "tok-456", "user": {"id": 27}}}`)
+	}))
+	defer ts.Close()
+	tests := map[string]struct {
+		rules   map[string]string
+		want    Vars
+		wantErr bool
+	}{
+		"captures": {
+			rules: map[string]string{"token": "$.data.access_token", "userId": "$.data.user.id", "csrf": "header:X-CSRF-Token", "code": "status"},
+			want:  Vars{"token": "tok-456", "userId": "27", "csrf": "csrf-123", "code": "200"},
+		},
+		"missing": {rules: map[string]string{"nope": "$.data.nope"}, want: Vars{}, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			ClearState("testspace")
+			req, _ := mockRSet(ts.URL).BuildRequest()
+			ch := &captureHandler{NewBResponse(), "testspace", tc.rules}
+			ch.CaptureResponse(http.DefaultClient.Do(req))
+			if (len(ch.errs) > 0) != tc.wantErr {
+				t.Errorf("got errs %v - want errs %v", ch.errs, tc.wantErr)
+			}
+			got, _ := LoadState("testspace")
+			if len(got) != len(tc.want) {
+				t.Errorf("got %v - want %v", got, tc.want)
+			}
+			for k, v := range tc.want {
+				if got[k] != v {
+					t.Errorf("%v: got %v - want %v", k, got[k], v)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Timing is where the time of a request went. With redirects the dns, connect and tls
+// of every hop are added up, and ttfb and transfer are of the last one.
+type Timing struct {
+	DNS     time.Duration
+	Connect time.Duration
+	TLS     time.Duration
+	// from the request being sent to the first byte of the response
+	TTFB time.Duration
+	// from the first byte to the end of the body
+	Transfer time.Duration
+	// from the start to the end of the body
+	Total time.Duration
+	// the connection was already open, so there was no dns, connect or tls
+	Reused bool
+}
+
+// String lays out the timing on one line, like: dns 2ms | connect 10ms | ... | total 120ms
+func (tm *Timing) String() string {
+	r := func(d time.Duration) time.Duration { return d.Round(10 * time.Microsecond) }
+	conn := fmt.Sprintf("dns %v | connect %v | tls %v", r(tm.DNS), r(tm.Connect), r(tm.TLS))
+	if tm.Reused {
+		conn = "reused connection"
+	}
+	return fmt.Sprintf("%s | ttfb %v | transfer %v | total %v", conn, r(tm.TTFB), r(tm.Transfer), r(tm.Total))
+}
+
+// tracer records the times of a request from httptrace as it is sent
+type tracer struct {
+	mu                               sync.Mutex
+	start, wrote, firstByte, done    time.Time
+	dnsStart, connectStart, tlsStart time.Time
+	dns, connect, tls                time.Duration
+	reused                           bool
+}
+
+type tracerKey struct{}
+
+// Returns r traced for its Timing
+func withTracer(r *http.Request) *http.Request {
+	t := &tracer{start: time.Now()}
+	ctx := context.WithValue(r.Context(), tracerKey{}, t)
+	return r.WithContext(httptrace.WithClientTrace(ctx, t.clientTrace()))
+}
+
+func (t *tracer) clientTrace() *httptrace.ClientTrace {
+	at := func(f func(now time.Time)) {
+		now := time.Now()
+		t.mu.Lock()
+		f(now)
+		t.mu.Unlock()
+	}
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { at(func(now time.Time) { t.dnsStart = now }) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { at(func(now time.Time) { t.dns += now.Sub(t.dnsStart) }) },
+		ConnectStart: func(_, _ string) {
+			at(func(now time.Time) { t.connectStart = now })
+		},
+		ConnectDone: func(_, _ string, _ error) {
+			at(func(now time.Time) { t.connect += now.Sub(t.connectStart) })
+		},
+		TLSHandshakeStart: func() { at(func(now time.Time) { t.tlsStart = now }) },
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			at(func(now time.Time) { t.tls += now.Sub(t.tlsStart) })
+		},
+		GotConn:              func(i httptrace.GotConnInfo) { at(func(time.Time) { t.reused = i.Reused }) },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { at(func(now time.Time) { t.wrote = now }) },
+		GotFirstResponseByte: func() { at(func(now time.Time) { t.firstByte = now }) },
+	}
+}
+
+// Marks the end of the body, the first time
+func (t *tracer) finish() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.done.IsZero() {
+		t.done = time.Now()
+	}
+}
+
+// Returns the timing so far. Transfer and Total are 0 until the body is read.
+func (t *tracer) timing() *Timing {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tm := &Timing{DNS: t.dns, Connect: t.connect, TLS: t.tls, Reused: t.reused}
+	if !t.wrote.IsZero() && !t.firstByte.IsZero() {
+		tm.TTFB = t.firstByte.Sub(t.wrote)
+	}
+	if !t.done.IsZero() {
+		tm.Total = t.done.Sub(t.start)
+		if !t.firstByte.IsZero() {
+			tm.Transfer = t.done.Sub(t.firstByte)
+		}
+	}
+	return tm
+}
+
+// Returns the tracer of the request of resp, and has it finish when the body is read.
+func tracerOf(resp *http.Response) *tracer {
+	if resp == nil || resp.Request == nil {
+		return nil
+	}
+	t, ok := resp.Request.Context().Value(tracerKey{}).(*tracer)
+	if !ok {
+		return nil
+	}
+	if resp.Body == nil || resp.Body == http.NoBody {
+		t.finish()
+	} else if _, timed := resp.Body.(*timedBody); !timed {
+		resp.Body = &timedBody{resp.Body, t}
+	}
+	return t
+}
+
+// timedBody finishes its tracer when the body is read to the end or closed
+type timedBody struct {
+	io.ReadCloser
+	t *tracer
+}
+
+func (b *timedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.t.finish()
+	}
+	return n, err
+}
+
+func (b *timedBody) Close() error {
+	b.t.finish()
+	return b.ReadCloser.Close()
+}
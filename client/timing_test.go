@@ -0,0 +1,86 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTiming(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+	ca := filepath.Join(t.TempDir(), "ca.pem")
+	os.WriteFile(ca, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw}), 0600)
+	c, err := Transport{CACert: ca}.Client(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	send := func() *Timing {
+		req, _ := http.NewRequest("GET", ts.URL, nil)
+		br := NewBResponse()
+		br.CaptureResponse(c.do(req))
+		if br.Timing().Total != 0 {
+			t.Errorf("total before the body is read: got %v - want 0", br.Timing().Total)
+		}
+		br.StringResponseBody()
+		return br.Timing()
+	}
+	first := send()
+	if first.Reused || first.Connect <= 0 || first.TLS <= 0 {
+		t.Errorf("first: got %+v - want a new connection with connect and tls times", first)
+	}
+	if first.TTFB < 20*time.Millisecond || first.Total < first.TTFB+first.TLS {
+		t.Errorf("first: got %+v - want a ttfb of at least 20ms within the total", first)
+	}
+	if second := send(); !second.Reused || second.TLS != 0 {
+		t.Errorf("second: got %+v - want the connection reused", second)
+	}
+}
+
+func TestTimingOutput(t *testing.T) {
+	tm := Timing{DNS: time.Millisecond, Connect: 2 * time.Millisecond, TTFB: 30 * time.Millisecond, Total: 40 * time.Millisecond}
+	tests := map[string]struct {
+		format     string
+		showTiming bool
+		want       string
+	}{
+		"pretty":           {format: "pretty", showTiming: true, want: "---| Timing --- dns 1ms | connect 2ms | tls 0s | ttfb 30ms | transfer 10ms | total 40ms |---"},
+		"pretty no timing": {format: "pretty"},
+		"json":             {format: "json", want: `"ttfbMs": 30`},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "https://mysite.com", nil)
+			br := NewBResponse()
+			br.Response = &http.Response{StatusCode: 200, Header: http.Header{}, Request: req, Body: http.NoBody}
+			br.ShowTiming = tc.showTiming
+			br.trace = &tracer{dns: tm.DNS, connect: tm.Connect}
+			now := time.Now()
+			br.trace.start, br.trace.wrote = now, now
+			br.trace.firstByte, br.trace.done = now.Add(tm.TTFB), now.Add(tm.Total)
+			var b bytes.Buffer
+			w := bufio.NewWriter(&b)
+			br.writeTo(w, tc.format)
+			w.Flush()
+			if tc.want == "" {
+				if strings.Contains(b.String(), "Timing") {
+					t.Errorf("got timing - want none in:\n%s", b.String())
+				}
+				return
+			}
+			if !strings.Contains(b.String(), tc.want) {
+				t.Errorf("missing %q in:\n%s", tc.want, b.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/jerempy/brang/config"
+)
+
+// Transport holds the settings for how requests are sent. Set in config.yaml under transport:,
+// overridden by transport: of a group in requests.yaml, then by flags.
+//
+//	transport:
+//	  timeout: 30s # whole request including reading the body. 0 for none. default 10s
+//	  connectTimeout: 5s
+//	  tlsHandshakeTimeout: 5s
+//	  proxy: socks5://localhost:1080 # or http://proxy.corp:3128. default from HTTPS_PROXY|HTTP_PROXY
+//	  caCert: $HOME/certs/internal-ca.pem # trusted on top of the system's
+//	  clientCert: $HOME/certs/me.pem
+//	  clientKey: $HOME/certs/me-key.pem
+//	  insecure: false # skips checking the server's certificate
+//	  noFollow: false # returns redirects as they are instead of following them
+//	  maxRedirects: 10
+type Transport struct {
+	Timeout             string `yaml:"timeout,omitempty"`
+	ConnectTimeout      string `yaml:"connectTimeout,omitempty"`
+	TLSHandshakeTimeout string `yaml:"tlsHandshakeTimeout,omitempty"`
+	Proxy               string `yaml:"proxy,omitempty"`
+	CACert              string `yaml:"caCert,omitempty"`
+	ClientCert          string `yaml:"clientCert,omitempty"`
+	ClientKey           string `yaml:"clientKey,omitempty"`
+	// nil when not set, so a group or flag can turn off what config.yaml turned on
+	Insecure     *bool `yaml:"insecure,omitempty"`
+	NoFollow     *bool `yaml:"noFollow,omitempty"`
+	MaxRedirects int   `yaml:"maxRedirects,omitempty"`
+}
+
+const defaultTimeout = time.Second * 10
+
+// Returns the transport: settings of config.yaml
+func configTransport() Transport {
+	var t Transport
+	if err := config.Brang.UnmarshalKey("transport", &t); err != nil {
+		fmt.Printf("ignoring transport: in config.yaml: %v\n", err)
+	}
+	return t
+}
+
+// Returns t with the set values of o on top
+func (t Transport) merge(o Transport) Transport {
+	for _, f := range []struct{ to, from *string }{
+		{&t.Timeout, &o.Timeout},
+		{&t.ConnectTimeout, &o.ConnectTimeout},
+		{&t.TLSHandshakeTimeout, &o.TLSHandshakeTimeout},
+		{&t.Proxy, &o.Proxy},
+		{&t.CACert, &o.CACert},
+		{&t.ClientCert, &o.ClientCert},
+		{&t.ClientKey, &o.ClientKey},
+	} {
+		if *f.from != "" {
+			*f.to = *f.from
+		}
+	}
+	if o.Insecure != nil {
+		t.Insecure = o.Insecure
+	}
+	if o.NoFollow != nil {
+		t.NoFollow = o.NoFollow
+	}
+	if o.MaxRedirects > 0 {
+		t.MaxRedirects = o.MaxRedirects
+	}
+	return t
+}
+
+func (t Transport) insecure() bool {
+	return t.Insecure != nil && *t.Insecure
+}
+
+func (t Transport) noFollow() bool {
+	return t.NoFollow != nil && *t.NoFollow
+}
+
+// transportKey is a Transport by value, as the key of clients made from equal settings
+type transportKey struct {
+	Transport
+	insecure, noFollow bool
+}
+
+func (t Transport) key() transportKey {
+	k := transportKey{t, t.insecure(), t.noFollow()}
+	k.Insecure, k.NoFollow = nil, nil
+	return k
+}
+
+// ActiveTransport returns the transport settings for the request: config.yaml, then
+// its group's, then the flags. The group's are known once a SavedRequest is loaded.
+func (rset *RequestSet) ActiveTransport() Transport {
+	return configTransport().merge(rset.groupTransport).merge(rset.Transport)
+}
+
+// Client returns a *brangClient using the settings. conns is how many connections
+// per host are kept open for reuse, 0 for the default.
+func (t Transport) Client(conns int) (*brangClient, error) {
+	timeout, err := parseTimeout("timeout", t.Timeout, defaultTimeout)
+	if err != nil {
+		return nil, err
+	}
+	tr, err := t.transport(conns)
+	if err != nil {
+		return nil, err
+	}
+	return &brangClient{&http.Client{Timeout: timeout, Transport: tr, CheckRedirect: t.checkRedirect}}, nil
+}
+
+func (t Transport) transport(conns int) (*http.Transport, error) {
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	if conns > 0 {
+		tr.MaxIdleConns = conns
+		tr.MaxIdleConnsPerHost = conns
+	}
+	connect, err := parseTimeout("connectTimeout", t.ConnectTimeout, 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	tr.DialContext = (&net.Dialer{Timeout: connect, KeepAlive: 30 * time.Second}).DialContext
+	if tr.TLSHandshakeTimeout, err = parseTimeout("tlsHandshakeTimeout", t.TLSHandshakeTimeout, tr.TLSHandshakeTimeout); err != nil {
+		return nil, err
+	}
+	if t.Proxy != "" {
+		u, err := url.Parse(os.ExpandEnv(t.Proxy))
+		if err != nil {
+			return nil, fmt.Errorf("err reading proxy: %w", err)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return nil, fmt.Errorf("proxy needs to start with http|https|socks5:// - ex: socks5://localhost:1080. was given: %s", t.Proxy)
+		}
+		tr.Proxy = http.ProxyURL(u)
+	}
+	if tr.TLSClientConfig, err = t.tlsConfig(); err != nil {
+		return nil, err
+	}
+	return tr, nil
+}
+
+// Returns the tls settings for the CA bundle, client cert and insecure mode
+func (t Transport) tlsConfig() (*tls.Config, error) {
+	c := &tls.Config{InsecureSkipVerify: t.insecure()}
+	if t.CACert != "" {
+		pem, err := os.ReadFile(os.ExpandEnv(t.CACert))
+		if err != nil {
+			return nil, fmt.Errorf("err reading caCert: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no PEM certificates found in caCert %s", t.CACert)
+		}
+		c.RootCAs = pool
+	}
+	if (t.ClientCert == "") != (t.ClientKey == "") {
+		return nil, fmt.Errorf("clientCert and clientKey need to be set together")
+	}
+	if t.ClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(os.ExpandEnv(t.ClientCert), os.ExpandEnv(t.ClientKey))
+		if err != nil {
+			return nil, fmt.Errorf("err reading clientCert and clientKey: %w", err)
+		}
+		c.Certificates = []tls.Certificate{cert}
+	}
+	return c, nil
+}
+
+func parseTimeout(name, s string, def time.Duration) (time.Duration, error) {
+	if s == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%s needs to be a duration like 30s or 2m. was given: %s", name, s)
+	}
+	return d, nil
+}
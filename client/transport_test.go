@@ -0,0 +1,151 @@
+package client
+
+import (
+	"bytes"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jerempy/brang/config"
+)
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func TestTransportMerge(t *testing.T) {
+	base := Transport{Timeout: "30s", Proxy: "http://proxy:3128", CACert: "ca.pem", NoFollow: boolPtr(true)}
+	got := base.merge(Transport{Timeout: "2m", ClientCert: "me.pem", ClientKey: "me-key.pem"}).merge(Transport{Insecure: boolPtr(true)})
+	want := Transport{Timeout: "2m", Proxy: "http://proxy:3128", CACert: "ca.pem", ClientCert: "me.pem", ClientKey: "me-key.pem"}
+	if got.key() != (transportKey{want, true, true}) {
+		t.Errorf("got %+v - want %+v", got.key(), transportKey{want, true, true})
+	}
+	// a later layer turns off what an earlier one turned on
+	if got = got.merge(Transport{Insecure: boolPtr(false), NoFollow: boolPtr(false)}); got.insecure() || got.noFollow() {
+		t.Errorf("got %+v - want insecure and noFollow turned off", got.key())
+	}
+}
+
+func TestTransportClient(t *testing.T) {
+	tests := map[string]struct {
+		in          Transport
+		wantTimeout time.Duration
+		wantErr     string
+	}{
+		"default":         {in: Transport{}, wantTimeout: 10 * time.Second},
+		"timeout":         {in: Transport{Timeout: "2m"}, wantTimeout: 2 * time.Minute},
+		"no timeout":      {in: Transport{Timeout: "0"}, wantTimeout: 0},
+		"bad timeout":     {in: Transport{Timeout: "soon"}, wantErr: "timeout needs to be a duration"},
+		"bad connect":     {in: Transport{ConnectTimeout: "-1s"}, wantErr: "connectTimeout needs to be a duration"},
+		"socks5 proxy":    {in: Transport{Proxy: "socks5://localhost:1080"}, wantTimeout: 10 * time.Second},
+		"bad proxy":       {in: Transport{Proxy: "ftp://proxy"}, wantErr: "proxy needs to start with"},
+		"missing ca":      {in: Transport{CACert: "/no/such/ca.pem"}, wantErr: "err reading caCert"},
+		"cert but no key": {in: Transport{ClientCert: "me.pem"}, wantErr: "need to be set together"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			c, err := tc.in.Client(0)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("got err %v - want err with %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.Timeout != tc.wantTimeout {
+				t.Errorf("timeout: got %v - want %v", c.Timeout, tc.wantTimeout)
+			}
+		})
+	}
+}
+
+func TestTransportTLS(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+	ca := filepath.Join(t.TempDir(), "ca.pem")
+	os.WriteFile(ca, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw}), 0600)
+	notPem := filepath.Join(t.TempDir(), "ca.txt")
+	os.WriteFile(notPem, []byte("not a cert"), 0600)
+	tests := map[string]struct {
+		in      Transport
+		wantErr bool
+	}{
+		"untrusted": {in: Transport{}, wantErr: true},
+		"ca bundle": {in: Transport{CACert: ca}},
+		"insecure":  {in: Transport{Insecure: boolPtr(true)}},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			c, err := tc.in.Client(0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = c.Get(ts.URL)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("got err %v - want err %v", err, tc.wantErr)
+			}
+		})
+	}
+	if _, err := (Transport{CACert: notPem}).Client(0); err == nil {
+		t.Error("should err for a caCert without certificates")
+	}
+}
+
+func TestTransportProxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+	}))
+	defer proxy.Close()
+	c, err := Transport{Proxy: proxy.URL}.Client(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get("http://internal.example/users"); err != nil {
+		t.Fatal(err)
+	}
+	if proxied != "http://internal.example/users" {
+		t.Errorf("proxied: got %q - want http://internal.example/users", proxied)
+	}
+}
+
+func TestActiveTransport(t *testing.T) {
+	config.Requests.ReadConfig(bytes.NewBufferString(`
+testspace:
+  transport:
+    timeout: 2m
+    proxy: http://group-proxy:3128
+  requests:
+    home: https://mysite.com
+testspace2:
+  transport:
+    insecure: false
+  requests:
+    home: https://mysite.com
+`))
+	config.Brang.Set("transport", map[string]interface{}{"timeout": "30s", "connectTimeout": "5s", "insecure": true})
+	defer config.Brang.Set("transport", nil)
+	rset := &RequestSet{URL: "testspace.home", Transport: Transport{Proxy: "socks5://localhost:1080"}}
+	if _, err := LoadSavedRequest(rset); err != nil {
+		t.Fatal(err)
+	}
+	want := transportKey{Transport{Timeout: "2m", ConnectTimeout: "5s", Proxy: "socks5://localhost:1080"}, true, false}
+	if got := rset.ActiveTransport().key(); got != want {
+		t.Errorf("got %+v - want %+v", got, want)
+	}
+	// the group checks the certificate when config.yaml doesn't
+	rset = &RequestSet{URL: "testspace2.home"}
+	if _, err := LoadSavedRequest(rset); err != nil {
+		t.Fatal(err)
+	}
+	if rset.ActiveTransport().insecure() {
+		t.Error("insecure: false of the group should override config.yaml")
+	}
+}
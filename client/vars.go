@@ -0,0 +1,103 @@
+package client
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Vars holds the values for {{name}} placeholders in requests. They come from the
+// group's vars: in requests.yaml, overridden by --var name=value flags.
+type Vars map[string]string
+
+var (
+	placeholder = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.-]+)\s*(:-([^{}]*))?\}\}`)
+	envRef      = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+)
+
+// Replaces {{name}} with its var, falling back to an env variable of the same name, or the
+// default of {{name:-default}}, and ${VAR} or ${VAR:-default} with the env variable.
+// Values filled in aren't expanded again. Names that can't be found are added to missing.
+func (v Vars) expand(s string, missing map[string]bool) string {
+	return valueRef.ReplaceAllStringFunc(s, func(m string) string {
+		if strings.HasPrefix(m, "$") {
+			return expandEnv(m, missing)
+		}
+		sm := placeholder.FindStringSubmatch(m)
+		name := sm[1]
+		if val, ok := v[name]; ok {
+			return val
+		}
+		if val, ok := os.LookupEnv(name); ok {
+			return val
+		}
+		if sm[2] != "" {
+			return sm[3]
+		}
+		missing[name] = true
+		return m
+	})
+}
+
+// Replaces ${VAR} with the env variable, even when it is set empty, and ${VAR:-default}
+// with the default when it is unset or empty, like a shell does
+func expandEnv(s string, missing map[string]bool) string {
+	return envRef.ReplaceAllStringFunc(s, func(m string) string {
+		sm := envRef.FindStringSubmatch(m)
+		if val, ok := os.LookupEnv(sm[1]); ok && (val != "" || sm[2] == "") {
+			return val
+		}
+		if sm[2] != "" {
+			return sm[3]
+		}
+		missing["$"+sm[1]] = true
+		return m
+	})
+}
+
+// Parses the name=value pairs from --var flags
+func parseVarSlice(slice []string) (Vars, error) {
+	v := Vars{}
+	for _, s := range slice {
+		k, val, ok := strings.Cut(s, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("vars need to be <name>=<value> - ex: --var userId=27. was given: %s", s)
+		}
+		v[k] = val
+	}
+	return v, nil
+}
+
+// Fills in the {{name}} and ${VAR} placeholders of the url, params, body, headers and cred.
+// Errors with every name that couldn't be found, rather than sending empty values.
+func (rset *RequestSet) expandVars() error {
+	flagVars, err := parseVarSlice(rset.VarSlice)
+	if err != nil {
+		return err
+	}
+	v := Vars{}
+	for k, val := range rset.vars {
+		v[k] = val
+	}
+	for k, val := range flagVars {
+		v[k] = val
+	}
+	missing := map[string]bool{}
+	// a cred such as {{token:-}} that is empty until a login request captures it sends
+	// the request without auth
+	if rset.Cred = v.expand(rset.Cred, missing); rset.Cred == "" && (rset.AuthType == "Bearer" || rset.AuthType == "Token") {
+		rset.AuthType = ""
+	}
+	rset.URL = v.expand(rset.URL, missing)
+	rset.Params = v.expand(rset.Params, missing)
+	rset.Body = v.expand(rset.Body, missing)
+	for i, h := range rset.HeaderSlice {
+		rset.HeaderSlice[i] = v.expand(h, missing)
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	return fmt.Errorf("unresolved variables: %s. set them with --var name=value, in the group's vars: in requests.yaml, or as env variables",
+		strings.Join(sortedKeys(missing), ", "))
+}
@@ -0,0 +1,117 @@
+package client
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/jerempy/brang/config"
+)
+
+func TestVarsExpand(t *testing.T) {
+	os.Setenv("TESTVARSENV", "fromenv")
+	defer os.Unsetenv("TESTVARSENV")
+	t.Setenv("TESTVARSEMPTY", "")
+	v := Vars{"userId": "27", "org": "acme", "raw": "${TESTVARSENV} {{org}}"}
+	tests := map[string]struct {
+		in          string
+		want        string
+		wantMissing string
+	}{
+		"var":                      {in: "https://mysite.com/{{org}}/users/{{ userId }}", want: "https://mysite.com/acme/users/27"},
+		"env placeholder":          {in: "{{TESTVARSENV}}", want: "fromenv"},
+		"env ref":                  {in: "${TESTVARSENV}", want: "fromenv"},
+		"env default":              {in: "${WILLNOTFINDTHIS:-v1}", want: "v1"},
+		"var default":              {in: "{{nope:-v1}}/{{org:-x}}", want: "v1/acme"},
+		"missing var":              {in: "{{nope}}", want: "{{nope}}", wantMissing: "nope"},
+		"missing env":              {in: "${WILLNOTFINDTHIS}", want: "${WILLNOTFINDTHIS}", wantMissing: "$WILLNOTFINDTHIS"},
+		"empty env":                {in: "a${TESTVARSEMPTY}b", want: "ab"},
+		"empty env default":        {in: "${TESTVARSEMPTY:-v1}", want: "v1"},
+		"value not expanded again": {in: "{{raw}}", want: "${TESTVARSENV} {{org}}"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			missing := map[string]bool{}
+			got := v.expand(tc.in, missing)
+			if got != tc.want {
+				t.Errorf("got %v - want %v", got, tc.want)
+			}
+			if tc.wantMissing != "" && !missing[tc.wantMissing] {
+				t.Errorf("missing: got %v - want %v", missing, tc.wantMissing)
+			}
+		})
+	}
+}
+
+func TestExpandVars(t *testing.T) {
+	rset := mockRSet("https://mysite.com/users/{{userId}}")
+	rset.Body = `{"name": "{{name}}"}`
+	rset.HeaderSlice = []string{"X-Org:{{org}}"}
+	rset.VarSlice = []string{"userId=27", "name=joe"}
+	rset.vars = Vars{"org": "acme", "userId": "1"}
+	if err := rset.expandVars(); err != nil {
+		t.Fatal(err)
+	}
+	if rset.URL != "https://mysite.com/users/27" || rset.Body != `{"name": "joe"}` || rset.HeaderSlice[0] != "X-Org:acme" {
+		t.Errorf("got %v %v %v", rset.URL, rset.Body, rset.HeaderSlice)
+	}
+	rset = mockRSet("https://mysite.com/{{a}}/{{b}}/{{a}}")
+	err := rset.expandVars()
+	if err == nil || err.Error()[:27] != "unresolved variables: a, b." {
+		t.Errorf("should err listing a, b. got %v", err)
+	}
+	rset = mockRSet("https://mysite.com")
+	rset.Cred = "{{token}}"
+	if err := rset.expandVars(); err == nil || !strings.Contains(err.Error(), "token") {
+		t.Errorf("missing cred var should err. got %v", err)
+	}
+	rset = mockRSet("https://mysite.com")
+	rset.Cred = "{{token:-}}"
+	if err := rset.expandVars(); err != nil || rset.AuthType != "" {
+		t.Errorf("cred var defaulting to empty should send without auth. got %v %v", err, rset.AuthType)
+	}
+	rset.VarSlice = []string{"novalue"}
+	if err := rset.expandVars(); err == nil {
+		t.Error("should err on --var without '='")
+	}
+}
+
+func TestBuildRequestURLBody(t *testing.T) {
+	body := "const s = `${name} {{x}}`"
+	rset := mockRSet("https://mysite.com/{{x}}")
+	rset.Method = "POST"
+	rset.Body = body
+	req, err := rset.BuildRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(req.Body)
+	if string(b) != body || rset.URL != "https://mysite.com/{{x}}" {
+		t.Errorf("got %v %s - want the url and body as given", rset.URL, b)
+	}
+	// --var fills them in
+	rset = mockRSet("https://mysite.com/{{x}}")
+	rset.VarSlice = []string{"x=1"}
+	if _, err := rset.BuildRequest(); err != nil || rset.URL != "https://mysite.com/1" {
+		t.Errorf("got %v %v - want https://mysite.com/1", rset.URL, err)
+	}
+}
+
+func TestLoadGroupVars(t *testing.T) {
+	config.Requests.ReadConfig(bytes.NewBufferString(`
+testspace:
+  vars:
+    userId: 27
+  requests:
+    user: https://mysite.com/users/{{userId}}
+`))
+	got, err := LoadSavedRequest(mockRSet("testspace.user"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.URL.String() != "https://mysite.com/users/27" {
+		t.Errorf("got %v - want https://mysite.com/users/27", got.URL)
+	}
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jerempy/brang/client"
+	"github.com/spf13/cobra"
+)
+
+var authCmd = &cobra.Command{
+	Use:   "auth",
+	Short: "log in to or out of the OAuth2 auth of groups",
+	Long: `
+Groups with an OAuth2 auth: get their tokens when a request needs one, and cache them in the tokens folder.
+With grant: authorization_code or device_code the user logs in with 'brang auth login': brang prints the url to
+log in at and opens it in the browser. For authorization_code, brang receives the redirect on a loopback server
+for a short while. Requests then use the token, refreshing it when it expires, and ask to log in again when it
+can't be refreshed.`,
+}
+
+var authLoginCmd = &cobra.Command{
+	Use:     "login group",
+	Short:   "Get a new OAuth2 token for a group, logging in for the authorization_code and device_code grants",
+	Example: `'brang auth login mysite' or 'brang auth login mysite --env staging --no-browser'`,
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		noBrowser, _ := cmd.Flags().GetBool("no-browser")
+		if err := client.Login(&rset, args[0], noBrowser); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Printf("logged in to %s\n", args[0])
+	},
+}
+
+var authLogoutCmd = &cobra.Command{
+	Use:     "logout group",
+	Short:   "Remove the cached OAuth2 tokens of a group",
+	Example: `'brang auth logout mysite'`,
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := client.Logout(args[0]); err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Printf("logged out of %s\n", args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(authCmd)
+	authCmd.AddCommand(authLoginCmd, authLogoutCmd)
+	authLoginCmd.Flags().StringVar(&rset.Env, "env", "", `environment of the group to log in to, ex: staging. Defaults to env: in config.yaml`)
+	authLoginCmd.Flags().Bool("no-browser", false, "only print the url to log in at, without opening it")
+	transportFlags(authLoginCmd)
+}
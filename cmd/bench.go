@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jerempy/brang/client"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var benchCmd = &cobra.Command{
+	Use:   "bench {url|SavedRequest}",
+	Short: "Load test a url or saved request",
+	Long: `
+Sends the same request many times at once, and reports the throughput, p50|p90|p99 latency with a histogram,
+and breakdowns of the status codes and errors.
+Saved requests are sent with their saved method, urls with -X (default GET).
+With --duration and no -n, sends until the duration is over.`,
+	Example: `'brang bench mysite.users -n 1000 -c 20' or 'brang bench https://mysite.com/users -c 10 --rate 50/s --duration 30s --json'`,
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		m, _ := cmd.Flags().GetString("method")
+		rset.Method = strings.ToUpper(m)
+		rset.URL = args[0]
+		if file, _ := cmd.Flags().GetString("file"); file != "" {
+			if err := rset.BodyFile(file); err != nil {
+				fmt.Printf("err reading body file: %v", err)
+				return
+			}
+		}
+		b := &client.Bench{}
+		b.Requests, _ = cmd.Flags().GetInt("requests")
+		b.Concurrency, _ = cmd.Flags().GetInt("concurrency")
+		b.Duration, _ = cmd.Flags().GetDuration("duration")
+		if b.Duration > 0 && !cmd.Flags().Changed("requests") {
+			b.Requests = 0
+		}
+		rate, _ := cmd.Flags().GetString("rate")
+		var err error
+		if b.Rate, err = client.ParseRate(rate); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		req, err := rset.Prepare()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		b.Transport = rset.ActiveTransport()
+		b.UseAuth(&rset)
+		if b.Session, err = rset.ActiveSession(nil); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		rep, err := b.Run(req)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if b.Session != nil {
+			if err := b.Session.Save(); err != nil {
+				fmt.Printf("err saving session %s: %v\n", b.Session.Name, err)
+			}
+		}
+		if j, _ := cmd.Flags().GetBool("json"); j {
+			client.WriteBenchJSON(os.Stdout, rep)
+			return
+		}
+		client.WriteBench(os.Stdout, rep)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(benchCmd)
+	// -c is --concurrency here, so --cred has no shorthand
+	shared := &cobra.Command{}
+	requestCmdFlags(shared)
+	shared.Flags().VisitAll(func(f *pflag.Flag) {
+		if f.Name == "cred" {
+			f.Shorthand = ""
+		}
+		benchCmd.Flags().AddFlag(f)
+	})
+	benchCmd.Flags().StringP("method", "X", "", "HTTP method to use for a url, ex: POST. Defaults to the saved method of a SavedRequest, otherwise GET")
+	benchCmd.Flags().IntP("requests", "n", 200, "how many requests to send")
+	benchCmd.Flags().IntP("concurrency", "c", 10, "how many requests to have in flight at once")
+	benchCmd.Flags().String("rate", "", "most requests to send per second|minute, ex: 50/s or 600/m. Defaults to as fast as possible")
+	benchCmd.Flags().Duration("duration", 0, "stop sending after this long, ex: 30s")
+	benchCmd.Flags().Bool("json", false, "write the report as json")
+}
@@ -6,6 +6,7 @@ import (
 	"os/exec"
 	"runtime"
 
+	"github.com/jerempy/brang/client"
 	"github.com/jerempy/brang/config"
 	"github.com/spf13/cobra"
 )
@@ -30,12 +31,38 @@ var configListCmd = &cobra.Command{
 }
 
 var configWhereCmd = &cobra.Command{
-	Use:   "where",
+	Use:   "where [group]",
 	Short: "List locations of brang configurations",
+	Long: `
+List locations of brang configurations, and the default environment.
+Given a group of the requests.yaml, also shows which of its environments would be used.`,
+	Example: `'brang config where' or 'brang config where mysite --env staging'`,
+	Args:    cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		fmt.Println("brang path - where everything is located: ", config.BrangPath)
 		fmt.Println("config.yaml - main settings: ", config.ConfigFile)
 		fmt.Println("requests.yaml - saved requests: ", config.RequestsFile)
+		fmt.Println("state - values captured from responses: ", config.StatePath)
+		fmt.Println("sessions - cookies and sticky headers: ", config.SessionsPath)
+		fmt.Println("imports - requests as last imported from specs: ", config.ImportsPath)
+		fmt.Println("history - requests sent and their responses: ", config.HistoryPath)
+		fmt.Println("tokens - OAuth2 tokens got for groups: ", config.TokensPath)
+		fmt.Println("snapshots - responses recorded to check saved requests against: ", config.SnapshotsPath)
+		fmt.Println("env - default environment in config.yaml: ", config.Brang.GetString("env"))
+		if len(args) == 0 {
+			return
+		}
+		env, _ := cmd.Flags().GetString("env")
+		name, source, err := client.ActiveEnv(args[0], env)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if name == "" {
+			fmt.Printf("%s - environment used: none\n", args[0])
+			return
+		}
+		fmt.Printf("%s - environment used: %s (from %s)\n", args[0], name, source)
 	},
 }
 
@@ -74,6 +101,7 @@ var configOpenCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(configCmd)
 	configCmd.AddCommand(configListCmd, configWhereCmd, configOpenCmd)
+	configWhereCmd.Flags().String("env", "", "environment to check for the group, as with --env on a request")
 	configOpenCmd.Flags().StringP("editor", "e", "", "either alias name or path to executable for your editor")
 	config.Brang.BindPFlag("fileEditor", configOpenCmd.Flags().Lookup("editor"))
 }
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jerempy/brang/client"
+	"github.com/spf13/cobra"
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff a b",
+	Short: "Show how two responses differ",
+	Long: `
+Compares the status, headers and body of two responses. Each of a and b is one of:
+  a history id, as in 'brang history list'
+  a file of -o json output, of 'brang history show --json', or of only a body
+  a SavedRequest to send now, as name or name@env to send it in an environment
+Json bodies are compared value by value, with paths such as $.items[3].price, other bodies line by line.
+Leave out values that change on every response with --ignore, or diff: ignore: in config.yaml.
+The Date header is always left out. Exits with an error when the responses differ.`,
+	Example: `'brang diff 41 42' or 'brang diff mysite.users@staging mysite.users@prod --ignore '$..updatedAt' --ignore header:X-Request-Id'`,
+	Args:    cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		var sides []*client.DiffSide
+		for _, arg := range args {
+			s, err := client.LoadDiffSide(arg, &rset)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			sides = append(sides, s)
+		}
+		ignore, _ := cmd.Flags().GetStringArray("ignore")
+		d, err := client.DiffResponses(sides[0], sides[1], append(client.DiffIgnoreRules(), ignore...))
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+			client.WriteDiffJSON(os.Stdout, d)
+		} else {
+			color, _ := cmd.Flags().GetString("color")
+			client.WriteDiff(os.Stdout, d, useColor(color))
+		}
+		if !d.Same() {
+			os.Exit(1)
+		}
+	},
+}
+
+// Tells if output is in color for --color auto|always|never. auto is when writing to
+// a terminal and NO_COLOR isn't set
+func useColor(mode string) bool {
+	switch mode {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	fi, err := os.Stdout.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+	requestCmdFlags(diffCmd)
+	diffCmd.Flags().StringArray("ignore", []string{}, `leave out differences at a json path, or of a header as header:<name>, as many as needed.
+ex: --ignore '$..id' --ignore '$.items[*].createdAt' --ignore header:X-Request-Id`)
+	diffCmd.Flags().Bool("json", false, "write the differences as json, for scripts")
+	diffCmd.Flags().String("color", "auto", "color the output: auto|always|never")
+}
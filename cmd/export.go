@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jerempy/brang/client"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export {curl|httpie|go|python|javascript} {url|SavedRequest} | export postman <group>",
+	Short: "Write a request as curl, HTTPie, Go, Python or JavaScript, or a group as a Postman collection",
+	Long: `
+Writes the request as it would be sent, with vars, environment and auth filled in, as a command line or code
+to share in bug reports. Saved requests use their saved method, urls use -X (default GET).
+Creds such as the Authorization header and password fields of json or form bodies are REDACTED, unless --with-secrets is given.
+Targets: curl, httpie, go (net/http), python (requests) and javascript (fetch).
+postman writes the saved requests of a group as a Postman v2.1 collection, with the group's baseUrl, vars and auth as the collection's.
+$ENV references become {{ENV}} variables to fill in. Environments, captures and expects aren't exported.`,
+	Example:   `'brang export curl mysite.users' or 'brang export python https://mysite.com/users -X POST -b '{"name": "joe"}'' or 'brang export postman mysite > mysite.postman_collection.json'`,
+	Args:      cobra.ExactArgs(2),
+	ValidArgs: append(client.ExportTargets, "postman"),
+	Run: func(cmd *cobra.Command, args []string) {
+		secrets, _ := cmd.Flags().GetBool("with-secrets")
+		if args[0] == "postman" {
+			out, err := client.ExportPostman(args[1], secrets)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			fmt.Print(out)
+			return
+		}
+		m, _ := cmd.Flags().GetString("method")
+		rset.Method = strings.ToUpper(m)
+		rset.URL = args[1]
+		if file, _ := cmd.Flags().GetString("file"); file != "" {
+			if err := rset.BodyFile(file); err != nil {
+				fmt.Printf("err reading body file: %v", err)
+				os.Exit(1)
+			}
+		}
+		req, err := rset.Prepare()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		out, err := client.Export(args[0], req, secrets)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Print(out)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+	// only the flags that build the request, not the ones for how it is sent
+	shared := &cobra.Command{}
+	requestCmdFlags(shared)
+	shared.Flags().VisitAll(func(f *pflag.Flag) {
+		switch f.Name {
+		case "auth", "cred", "header", "params", "body", "file", "var", "env":
+			exportCmd.Flags().AddFlag(f)
+		}
+	})
+	exportCmd.Flags().StringP("method", "X", "", "HTTP method to use for a url, ex: POST. Defaults to the saved method of a SavedRequest, otherwise GET")
+	exportCmd.Flags().Bool("with-secrets", false, "include creds such as the Authorization header and password fields of the body instead of REDACTED")
+}
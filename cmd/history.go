@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jerempy/brang/client"
+	"github.com/spf13/cobra"
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "view and replay requests sent before",
+	Long: `
+Every request sent is recorded with its response, with creds in headers and the url redacted.
+Set how many are kept, for how long and how much of each body under history: in config.yaml.`,
+}
+
+var historyListCmd = &cobra.Command{
+	Use:     "list",
+	Short:   "List the requests sent, newest first",
+	Example: `'brang history list --name "mysite.*" --status 4xx'`,
+	Args:    cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
+		var f client.HistoryFilter
+		f.Name, _ = cmd.Flags().GetString("name")
+		f.Status, _ = cmd.Flags().GetString("status")
+		f.Limit, _ = cmd.Flags().GetInt("limit")
+		entries, err := client.ListHistory(f)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tTIME\tNAME\tMETHOD\tSTATUS\tTOTAL\tURL\t")
+		for _, e := range entries {
+			status, total := strconv.Itoa(e.Status), ""
+			if e.Status == 0 {
+				status = "err"
+			}
+			if e.Timing != nil {
+				total = fmt.Sprintf("%.0fms", e.Timing.TotalMs)
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t\n", e.ID, e.Time.Local().Format(time.DateTime), e.Name, e.Method, status, total, e.URL)
+		}
+		tw.Flush()
+	},
+}
+
+var historyShowCmd = &cobra.Command{
+	Use:     "show id",
+	Short:   "Show a request sent and its response",
+	Example: `'brang history show 42'`,
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		e, err := loadHistoryArg(args[0])
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+			b, _ := json.MarshalIndent(e, "", "  ")
+			fmt.Println(string(b))
+			return
+		}
+		fmt.Printf("#%d sent %s", e.ID, e.Time.Local().Format(time.DateTime))
+		if e.Name != "" {
+			fmt.Printf(" as %s", e.Name)
+		}
+		if e.Env != "" {
+			fmt.Printf(" in environment %s", e.Env)
+		}
+		fmt.Println()
+		fmt.Printf("\n%s %s\n", e.Method, e.URL)
+		printHistoryBody(e.Header, e.Body, e.BodyTruncated)
+		for _, r := range e.Redirects {
+			fmt.Printf("\nredirected: %d %s %s -> %s\n", r.Status, r.Method, r.URL, r.Location)
+		}
+		if e.Status != 0 {
+			fmt.Printf("\n%d %s\n", e.Status, http.StatusText(e.Status))
+			printHistoryBody(e.ResponseHeader, e.ResponseBody, e.ResponseBodyTruncated)
+		}
+		if t := e.Timing; t != nil {
+			fmt.Printf("\ntiming: dns %.1fms, connect %.1fms, tls %.1fms, first byte %.1fms, transfer %.1fms, total %.1fms\n",
+				t.DNSMs, t.ConnectMs, t.TLSMs, t.TTFBMs, t.TransferMs, t.TotalMs)
+		}
+		for _, err := range e.Errors {
+			fmt.Printf("err: %s\n", err)
+		}
+	},
+}
+
+var historyReplayCmd = &cobra.Command{
+	Use:   "replay id",
+	Short: "Send a request from the history again",
+	Long: `
+Sends the request again as it was recorded. Creds were redacted in the history, so for a saved request
+they are filled in from requests.yaml as it is now. For a url, set them again with -H, or -a and -c.`,
+	Example: `'brang history replay 42' or 'brang history replay 43 -a Bearer -c 123-456-ABC'`,
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		e, err := loadHistoryArg(args[0])
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		rset.Replay(e)
+	},
+}
+
+func loadHistoryArg(arg string) (*client.HistoryEntry, error) {
+	id, err := strconv.Atoi(arg)
+	if err != nil {
+		return nil, fmt.Errorf("history id needs to be a number, as in 'brang history list'. was given: %s", arg)
+	}
+	return client.LoadHistory(id)
+}
+
+func printHistoryBody(h http.Header, body string, truncated bool) {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range h[k] {
+			fmt.Printf("%s: %s\n", k, v)
+		}
+	}
+	if body != "" {
+		fmt.Printf("\n%s\n", body)
+	}
+	if truncated {
+		fmt.Println("(body cut to history: maxBodySize)")
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+	historyCmd.AddCommand(historyListCmd, historyShowCmd, historyReplayCmd)
+	historyListCmd.Flags().String("name", "", `only requests of saved requests matching this name, with * globs. ex: "mysite.*"`)
+	historyListCmd.Flags().String("status", "", "only responses of this status, ex: 404 or 4xx, or err for requests that got no response")
+	historyListCmd.Flags().IntP("limit", "n", 20, "most requests to list. 0 for all")
+	historyShowCmd.Flags().Bool("json", false, "show the entry as json, as it is stored")
+	c := historyReplayCmd
+	c.Flags().StringVarP(&rset.AuthType, "auth", "a", "", "set Auth type: Password|Token|Bearer|Digest. for a request of a url")
+	c.Flags().StringVarP(&rset.Cred, "cred", "c", "", "set token, or username:password, of --auth")
+	c.Flags().StringArrayVarP(&rset.HeaderSlice, "header", "H", []string{}, `set headers as key:value, replacing those recorded`)
+	c.Flags().StringVar(&rset.Env, "env", "", "environment of the saved request's group to use. Defaults to the one it was sent with")
+	c.Flags().StringVar(&rset.Session, "session", "", "keep cookies and sticky headers in this session, ex: mysite")
+	c.Flags().BoolVar(&rset.ShowTiming, "timing", false, "show where the time went")
+	transportFlags(c)
+}
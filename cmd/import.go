@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jerempy/brang/client"
+	"github.com/spf13/cobra"
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "import requests into requests.yaml from other tools",
+}
+
+var importCurlCmd = &cobra.Command{
+	Use:   "curl '<curl command>' --as SavedRequest",
+	Short: "Save a curl command line as a request",
+	Long: `
+Saves a curl command line, such as from "Copy as cURL" in browser devtools, to the requests.yaml under the name given with --as.
+Reads -X, -H, -d|--data-raw|--data-binary, -u, --url, -F, --compressed, -k, -G and query strings.
+Creds from -u or an Authorization header are saved to the group's auth as $ENV references, never as plain text.
+Flags that can't be saved are listed, and the request is saved without them.
+Quote the whole curl command, or put it after --`,
+	Example: `'brang import curl 'curl -X POST https://mysite.com/orders -H "Content-Type: application/json" -d "{\"id\": 1}"' --as mysite.orders.create'`,
+	Args:    cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name, _ := cmd.Flags().GetString("as")
+		var ci *client.CurlImport
+		var err error
+		if len(args) == 1 {
+			ci, err = client.ParseCurl(args[0])
+		} else {
+			ci, err = client.ParseCurlArgs(args)
+		}
+		if err != nil {
+			fmt.Println("err reading curl command: ", err)
+			os.Exit(1)
+		}
+		for _, n := range ci.Notes {
+			fmt.Println("note:", n)
+		}
+		if len(ci.Unsupported) > 0 {
+			fmt.Printf("not imported: %s\n", strings.Join(ci.Unsupported, ", "))
+		}
+		envs, notice, err := client.SaveRequest(ci.RequestSet, name)
+		if err != nil {
+			fmt.Println("err saving request: ", err)
+			os.Exit(1)
+		}
+		if notice != "" {
+			fmt.Println(notice)
+		}
+		fmt.Printf("saved request %s: %s %s\n", name, ci.Method, ci.URL)
+		if len(envs) > 0 {
+			fmt.Printf("creds are saved as env references. set these in your environment: %s\n", strings.Join(envs, ", "))
+		}
+	},
+}
+
+var importPostmanCmd = &cobra.Command{
+	Use:   "postman <collection.json>",
+	Short: "Save the requests of a Postman v2.1 collection as a group",
+	Long: `
+Saves the requests of a Postman collection, exported as Collection v2.1, to the requests.yaml as a group named after the collection,
+or the name given with --group. Folders become nested requests, the collection's variables become the group's vars, and {{vars}} stay vars.
+A {{baseUrl}} variable becomes the group's baseUrl. Bearer and basic auth of the collection become the group's auth.
+Creds are saved as $ENV references, never as plain text. Scripts and file bodies aren't imported, and are listed.
+Exported with 'brang export postman <group>'`,
+	Example: `'brang import postman ./mysite.postman_collection.json --group mysite'`,
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			fmt.Println("err reading collection: ", err)
+			os.Exit(1)
+		}
+		name, _ := cmd.Flags().GetString("group")
+		g, err := client.ParsePostman(data, name)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		saveImportedGroups(g)
+	},
+}
+
+var importInsomniaCmd = &cobra.Command{
+	Use:   "insomnia <export.json>",
+	Short: "Save the requests of an Insomnia v4 export as a group",
+	Long: `
+Saves the requests of an Insomnia export, exported as Insomnia v4 (JSON), to the requests.yaml as a group per workspace, named after it,
+or the name given with --group. Folders become nested requests, the base environment becomes the group's vars,
+and sub environments become the group's environments. {{ _.name }} becomes {{name}}.
+Creds are saved as $ENV references, never as plain text. Template tags such as {% response %} aren't supported, and are listed.`,
+	Example: `'brang import insomnia ./Insomnia_export.json --group mysite'`,
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			fmt.Println("err reading export: ", err)
+			os.Exit(1)
+		}
+		name, _ := cmd.Flags().GetString("group")
+		groups, err := client.ParseInsomnia(data, name)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		saveImportedGroups(groups...)
+	},
+}
+
+var importOpenAPICmd = &cobra.Command{
+	Use:   "openapi <spec.yaml|spec.json>",
+	Short: "Save the operations of an OpenAPI 3 or Swagger 2 spec as a group",
+	Long: `
+Saves a request per operation of an OpenAPI 3 or Swagger 2 spec to the requests.yaml as a group named after the spec's title,
+or the name given with --group. Requests are named by operationId, or by method and path like get-users-id.
+Path params and required query params and headers become {{vars}}, set from the spec's examples when it has them.
+Bodies are made from the examples of the spec, or from its schemas. The first server becomes the group's baseUrl.
+Security schemes become the group's auth, or headers for api keys, with creds as $ENV references.
+Importing the spec again updates the requests to it, but keeps the fields you edited since the last import.
+Requests no longer in the spec are listed and left as they are.`,
+	Example: `'brang import openapi ./payments.yaml --group payments'`,
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			fmt.Println("err reading spec: ", err)
+			os.Exit(1)
+		}
+		name, _ := cmd.Flags().GetString("group")
+		g, err := client.ParseOpenAPI(data, name)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		for _, n := range g.Notes {
+			fmt.Println("note:", n)
+		}
+		source, _ := filepath.Abs(args[0])
+		rep, err := g.SaveMerged(source)
+		if err != nil {
+			fmt.Println("err saving requests: ", err)
+			os.Exit(1)
+		}
+		fmt.Printf("%s: %d added, %d updated, %d unchanged\n", g.Name, len(rep.Added), len(rep.Updated), len(rep.Unchanged))
+		for _, c := range rep.Conflicts {
+			fmt.Printf("kept your edit of %s, which the spec also changed\n", c)
+		}
+		if len(rep.Gone) > 0 {
+			fmt.Printf("no longer in the spec, left as they are: %s\n", strings.Join(rep.Gone, ", "))
+		}
+		if len(g.Envs) > 0 {
+			fmt.Printf("creds are saved as env references. set these in your environment: %s\n", strings.Join(g.Envs, ", "))
+		}
+	},
+}
+
+func saveImportedGroups(groups ...*client.ImportedGroup) {
+	var envs []string
+	for _, g := range groups {
+		for _, n := range g.Notes {
+			fmt.Println("note:", n)
+		}
+		if err := g.Save(); err != nil {
+			fmt.Println("err saving requests: ", err)
+			os.Exit(1)
+		}
+		fmt.Printf("saved %d requests to %s\n", len(g.Requests), g.Name)
+		envs = append(envs, g.Envs...)
+	}
+	if len(envs) > 0 {
+		fmt.Printf("creds are saved as env references. set these in your environment: %s\n", strings.Join(envs, ", "))
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+	importCmd.AddCommand(importCurlCmd, importPostmanCmd, importInsomniaCmd, importOpenAPICmd)
+	importCurlCmd.Flags().String("as", "", "name to save the request as in dot.notation, ex: mysite.orders.create")
+	importCurlCmd.MarkFlagRequired("as")
+	importPostmanCmd.Flags().String("group", "", "group to save the requests to. Defaults to the name of the collection")
+	importInsomniaCmd.Flags().String("group", "", "group to save the requests to. Defaults to the name of the workspace")
+	importOpenAPICmd.Flags().String("group", "", "group to save the requests to. Defaults to the title of the spec")
+}
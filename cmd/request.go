@@ -2,6 +2,8 @@ package cmd
 
 import (
 	"fmt"
+	"os"
+	"strconv"
 	"strings"
 
 	"github.com/jerempy/brang/client"
@@ -10,72 +12,57 @@ import (
 
 var rset client.RequestSet
 
-var getCmd = &cobra.Command{
-	Use:   "get {url|SavedRequest}",
-	Short: "HTTP POST Request",
-	Long: `
-Gets the data and returns to terminal.
-Accepts 1 positional arg of either a valid URL or a request saved in the .brang.yml using dot.notation.`,
-	Example: `'brang get https://mysite.com/users' or using SavedRequests: 'brang get mysite.users'`,
-	Args:    cobra.ExactArgs(1),
-	Run:     processAndRunRequest,
-}
+var getCmd = methodCmd("get", "Gets the data and returns to terminal.")
 
-var postCmd = &cobra.Command{
-	Use:   "post {url|SavedRequest}",
-	Short: "HTTP POST Request",
-	Long: `
-Posts data and returns response to terminal.
-Accepts 1 positional arg of either a valid URL or a request saved in the .brang.yml using dot.notation.`,
-	Example: `'brang post https://mysite.com/users' or using SavedRequests: 'brang post mysite.users'`,
-	Args:    cobra.ExactArgs(1),
-	Run:     processAndRunRequest,
-}
+var postCmd = methodCmd("post", "Posts data and returns response to terminal.")
 
-var putCmd = &cobra.Command{
-	Use:   "put {url|SavedRequest}",
-	Short: "HTTP PUT Request",
-	Long: `
-Puts data and returns response to terminal.
-Accepts 1 positional arg of either a valid URL or a request saved in the .brang.yml using dot.notation.`,
-	Example: `'brang put https://mysite.com/users' or using SavedRequests: 'brang put mysite.users'`,
-	Args:    cobra.ExactArgs(1),
-	Run:     processAndRunRequest,
-}
+var putCmd = methodCmd("put", "Puts data and returns response to terminal.")
+
+var patchCmd = methodCmd("patch", "Patch data and returns response to terminal.")
+
+var deleteCmd = methodCmd("delete", "Delete data and returns response to terminal.")
 
-var patchCmd = &cobra.Command{
-	Use:   "patch {url|SavedRequest}",
-	Short: "HTTP PATCH Request",
+var headCmd = methodCmd("head", "Gets only the headers, such as Content-Length, and returns them to terminal. Can't send a body.")
+
+var optionsCmd = methodCmd("options", "Asks which methods/CORS rules are allowed and returns response to terminal.")
+
+var requestCmd = &cobra.Command{
+	Use:   "request {url|SavedRequest}",
+	Short: "HTTP Request using any method",
 	Long: `
-Patch data and returns response to terminal.
-Accepts 1 positional arg of either a valid URL or a request saved in the .brang.yml using dot.notation.`,
-	Example: `'brang patch https://mysite.com/users' or using SavedRequests: 'brang patch mysite.users'`,
+Sends a request using the method given with -X (default GET) and returns response to terminal.
+Use for methods without their own command, such as PROPFIND or REPORT.
+Accepts 1 positional arg of either a valid URL or a request saved in the requests.yaml using dot.notation.`,
+	Example: `'brang request -X PROPFIND https://mysite.com/dav/' or using SavedRequests: 'brang request -X REPORT mysite.calendar'`,
 	Args:    cobra.ExactArgs(1),
 	Run:     processAndRunRequest,
 }
 
-var deleteCmd = &cobra.Command{
-	Use:   "delete {url|SavedRequest}",
-	Short: "HTTP DELETE Request",
-	Long: `
-Delete data and returns response to terminal.
-Accepts 1 positional arg of either a valid URL or a request saved in the .brang.yml using dot.notation.`,
-	Example: `'brang delete https://mysite.com/users' or using SavedRequests: 'brang delete mysite.users'`,
-	Args:    cobra.ExactArgs(1),
-	Run:     processAndRunRequest,
+// Makes the command for a HTTP method, named after the method in lower case.
+func methodCmd(name, desc string) *cobra.Command {
+	return &cobra.Command{
+		Use:   name + " {url|SavedRequest}",
+		Short: "HTTP " + strings.ToUpper(name) + " Request",
+		Long: fmt.Sprintf(`
+%s
+Accepts 1 positional arg of either a valid URL or a request saved in the requests.yaml using dot.notation.`, desc),
+		Example: fmt.Sprintf(`'brang %[1]s https://mysite.com/users' or using SavedRequests: 'brang %[1]s mysite.users'`, name),
+		Args:    cobra.ExactArgs(1),
+		Run:     processAndRunRequest,
+	}
 }
 
 func init() {
-	rootCmd.AddCommand(getCmd, putCmd, postCmd, patchCmd, deleteCmd)
-	requestCmdFlags(getCmd)
-	requestCmdFlags(putCmd)
-	requestCmdFlags(postCmd)
-	requestCmdFlags(patchCmd)
-	requestCmdFlags(deleteCmd)
+	rootCmd.AddCommand(getCmd, putCmd, postCmd, patchCmd, deleteCmd, headCmd, optionsCmd, requestCmd)
+	for _, c := range []*cobra.Command{getCmd, putCmd, postCmd, patchCmd, deleteCmd, headCmd, optionsCmd, requestCmd} {
+		requestCmdFlags(c)
+		c.Flags().String("save", "", "also saves the request to requests.yaml under this name in dot.notation, ex: mysite.users.create")
+	}
+	requestCmd.Flags().StringP("method", "X", "", "HTTP method to use, ex: PROPFIND. Defaults to the saved method of a SavedRequest, otherwise GET")
 }
 
 func requestCmdFlags(cmd *cobra.Command) {
-	cmd.Flags().StringVarP(&rset.AuthType, "auth", "a", "", "set Auth type: Password|Token|Bearer")
+	cmd.Flags().StringVarP(&rset.AuthType, "auth", "a", "", "set Auth type: Password|Token|Bearer|Digest")
 	cmd.Flags().StringVarP(&rset.Cred, "cred", "c", "", `set token for auth types Token|Bearer ex: 123-456-ABC.
 or set username:password for type Password ex: john123:secretpass`)
 	cmd.Flags().StringArrayVarP(&rset.HeaderSlice, "header", "H", []string{}, `set headers as key:value, as many as needed. 
@@ -84,16 +71,76 @@ ex: -H "Content-Type:application/json" -H "Authorization:Bearer 123-456-ABC"`)
 -p ?title=MyTitle -> https://mysite.com/?title=Mytitle or -p 123 ->https://mysite.com/123`)
 	cmd.Flags().StringVarP(&rset.Body, "body", "b", "", `includes body in the request, such as json body for a post request.`)
 	cmd.Flags().StringP("file", "f", "", `path to a file for body of request`)
+	cmd.Flags().StringArrayVar(&rset.VarSlice, "var", []string{}, `set a value for {{name}} placeholders as name=value, as many as needed.
+ex: --var userId=27 -> https://mysite.com/users/{{userId}} becomes https://mysite.com/users/27.
+placeholders of a url request are only filled in with --var, so bodies with {{...}} or ${...} of their own are sent as they are`)
+	cmd.Flags().StringVar(&rset.Env, "env", "", `environment of the SavedRequest's group to use, ex: staging. Defaults to env: in config.yaml`)
+	cmd.Flags().StringVar(&rset.Session, "session", "", `keep cookies and sticky headers between runs in this session, ex: mysite. Defaults to session: of the SavedRequest's group`)
+	cmd.Flags().StringArrayVar(&rset.SessionHeaders, "session-header", []string{}, `a response header to send back on later requests of the session, as many as needed. ex: --session-header X-CSRF-Token`)
+	cmd.Flags().BoolVar(&rset.ShowTiming, "timing", false, "show where the time went: dns, connect, tls, time to first byte, transfer and total")
+	transportFlags(cmd)
+}
+
+// Flags overriding transport: of config.yaml and the SavedRequest's group
+func transportFlags(cmd *cobra.Command) {
+	t := &rset.Transport
+	cmd.Flags().StringVar(&t.Timeout, "timeout", "", "time allowed for the whole request, ex: 2m. 0 for no limit. Defaults to 10s")
+	cmd.Flags().StringVar(&t.ConnectTimeout, "connect-timeout", "", "time allowed to connect to the server, ex: 5s")
+	cmd.Flags().StringVar(&t.TLSHandshakeTimeout, "tls-timeout", "", "time allowed for the TLS handshake, ex: 5s")
+	cmd.Flags().StringVar(&t.Proxy, "proxy", "", "send through a HTTP or SOCKS5 proxy, ex: http://proxy.corp:3128 or socks5://localhost:1080")
+	cmd.Flags().StringVar(&t.CACert, "cacert", "", "path to a PEM bundle of CA certificates to trust, on top of the system's")
+	cmd.Flags().StringVar(&t.ClientCert, "cert", "", "path to a PEM client certificate for mTLS. needs --key")
+	cmd.Flags().StringVar(&t.ClientKey, "key", "", "path to the PEM private key of --cert")
+	cmd.Flags().VarPF(optBool{&t.Insecure}, "insecure", "k", "don't check the server's certificate. --insecure=false checks it when config.yaml or the group doesn't").NoOptDefVal = "true"
+	cmd.Flags().VarPF(optBool{&t.NoFollow}, "no-follow", "", "don't follow redirects. the redirect is the response. --no-follow=false follows them when config.yaml or the group doesn't").NoOptDefVal = "true"
+	cmd.Flags().IntVar(&t.MaxRedirects, "max-redirects", 0, "most redirects to follow before stopping. Defaults to 10")
+}
+
+// optBool is a bool flag that is nil until set, so setting it false overrides a true of config.yaml
+type optBool struct{ p **bool }
+
+func (b optBool) String() string {
+	return strconv.FormatBool(*b.p != nil && **b.p)
+}
+
+func (b optBool) Set(s string) error {
+	v, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	*b.p = &v
+	return nil
+}
+
+func (b optBool) Type() string {
+	return "bool"
 }
 
 func processAndRunRequest(cmd *cobra.Command, args []string) {
 	rset.Method = strings.ToUpper(cmd.Name())
+	if m, err := cmd.Flags().GetString("method"); err == nil {
+		rset.Method = strings.ToUpper(m)
+	}
+	runRequest(cmd, args)
+}
+
+// Sends the request for arg using rset.Method. When the method is empty
+// a saved request is sent with its saved method, otherwise GET.
+func runRequest(cmd *cobra.Command, args []string) {
 	rset.URL = args[0]
+	name, _ := cmd.Flags().GetString("save")
+	if name != "" && !client.IsURL(rset.URL) {
+		fmt.Printf("--save needs a url. %s is already a SavedRequest\n", rset.URL)
+		os.Exit(1)
+	}
 	if file, _ := cmd.Flags().GetString("file"); file != "" {
 		if err := rset.BodyFile(file); err != nil {
 			fmt.Printf("err reading body file: %v", err)
 			return
 		}
 	}
+	if name != "" {
+		saveRequest(name)
+	}
 	rset.Send()
 }
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jerempy/brang/client"
+	"github.com/spf13/cobra"
+)
+
+var runCmd = &cobra.Command{
+	Use:   "run {SavedRequest|group|glob}",
+	Short: "Send saved requests using their saved method",
+	Long: `
+Sends a request saved in the requests.yaml with the method saved alongside it (method: POST), and returns response to terminal.
+Saved requests without a method are sent as GET.
+Given a group or a glob, such as mysite or mysite.posts.*, sends every matching saved request in file order
+and finishes with a summary. A last key of * matches at any depth. Exits with an error if any request failed or got a 4xx|5xx status.
+Accepts 1 positional arg of a request saved in the requests.yaml using dot.notation.`,
+	Example: `'brang run mysite.posts.create' or for many: 'brang run mysite.posts.*' or 'brang run mysite --parallel 4'`,
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		rset.Method = ""
+		if !client.IsCollection(args[0]) {
+			runRequest(cmd, args)
+			return
+		}
+		if file, _ := cmd.Flags().GetString("file"); file != "" {
+			if err := rset.BodyFile(file); err != nil {
+				fmt.Printf("err reading body file: %v", err)
+				return
+			}
+		}
+		names, err := client.MatchSavedRequests(args[0])
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		parallel, _ := cmd.Flags().GetInt("parallel")
+		rn := &client.Runner{Base: &rset, Parallel: parallel}
+		results := rn.Run(names)
+		client.WriteSummary(os.Stdout, results)
+		for _, r := range results {
+			if r.Failed() {
+				os.Exit(1)
+			}
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(runCmd)
+	requestCmdFlags(runCmd)
+	runCmd.Flags().Int("parallel", 1, "how many requests of a group|glob to send at once. 1 sends them one at a time in file order")
+}
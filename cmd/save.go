@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jerempy/brang/client"
+	"github.com/spf13/cobra"
+)
+
+var saveCmd = &cobra.Command{
+	Use:   "save SavedRequest url",
+	Short: "Save a request to requests.yaml without sending it",
+	Long: `
+Saves a request built from the flags to the requests.yaml under the name given in dot.notation, without sending it.
+Comments and formatting in the requests.yaml are kept. Creds are saved as $ENV references, such as $MYSITE_TOKEN, never as plain text.
+To send and save at once use the --save flag on a request, ex: 'brang post https://mysite.com/users --save mysite.users.create'`,
+	Example: `'brang save mysite.users.create https://mysite.com/users -X POST -b '{"name": "joe"}' -a Bearer -c 123-456-ABC'`,
+	Args:    cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		m, _ := cmd.Flags().GetString("method")
+		rset.Method = strings.ToUpper(m)
+		rset.URL = args[1]
+		if file, _ := cmd.Flags().GetString("file"); file != "" {
+			if err := rset.BodyFile(file); err != nil {
+				fmt.Printf("err reading body file: %v", err)
+				os.Exit(1)
+			}
+		}
+		saveRequest(args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(saveCmd)
+	requestCmdFlags(saveCmd)
+	saveCmd.Flags().StringP("method", "X", "", "HTTP method to save, ex: POST. Defaults to GET")
+}
+
+// Saves rset to the requests.yaml and prints any env variables the creds need. Exits on failure.
+func saveRequest(name string) {
+	envs, notice, err := client.SaveRequest(&rset, name)
+	if err != nil {
+		fmt.Println("err saving request: ", err)
+		os.Exit(1)
+	}
+	if notice != "" {
+		fmt.Println(notice)
+	}
+	fmt.Printf("saved request %s\n", name)
+	if len(envs) > 0 {
+		fmt.Printf("creds are saved as env references. set these in your environment: %s\n", strings.Join(envs, ", "))
+	}
+}
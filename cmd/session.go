@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jerempy/brang/client"
+	"github.com/spf13/cobra"
+)
+
+var sessionCmd = &cobra.Command{
+	Use:   "session",
+	Short: "view or clear sessions of cookies and sticky headers",
+	Long: `
+Requests sent with --session name, or of a group with session: name, keep the cookies set by responses
+and send them on later requests, like a browser does. Sticky headers, such as X-CSRF-Token, are kept the same way.`,
+}
+
+var sessionListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the saved sessions",
+	Args:  cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
+		names, err := client.SessionNames()
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		for _, n := range names {
+			fmt.Println(n)
+		}
+	},
+}
+
+var sessionShowCmd = &cobra.Command{
+	Use:     "show name",
+	Short:   "Show the cookies and sticky headers of a session",
+	Example: `'brang session show mysite'`,
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		s, err := client.LoadSession(args[0])
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "COOKIE\tVALUE\tDOMAIN\tPATH\tEXPIRES\tFLAGS\t")
+		for _, c := range s.Cookies {
+			domain := c.Domain
+			if !c.HostOnly {
+				domain = "." + domain
+			}
+			expires := "session"
+			if !c.Expires.IsZero() {
+				expires = c.Expires.Local().Format(time.RFC3339)
+			}
+			flags := ""
+			if c.Secure {
+				flags += "Secure "
+			}
+			if c.HttpOnly {
+				flags += "HttpOnly"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t\n", c.Name, c.Value, domain, c.Path, expires, flags)
+		}
+		tw.Flush()
+		if len(s.Headers) == 0 {
+			return
+		}
+		fmt.Println("\nSticky headers:")
+		keys := make([]string, 0, len(s.Headers))
+		for k := range s.Headers {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			v := s.Headers[k]
+			if v == "" {
+				v = "(not set by a response yet)"
+			} else if h := s.HeaderHosts[k]; h != "" {
+				v += " (sent to " + h + ")"
+			}
+			fmt.Printf("%s: %s\n", k, v)
+		}
+	},
+}
+
+var sessionClearCmd = &cobra.Command{
+	Use:     "clear name",
+	Short:   "Remove all cookies and sticky headers of a session",
+	Example: `'brang session clear mysite'`,
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := client.ClearSession(args[0]); err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Printf("cleared session %s\n", args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(sessionCmd)
+	sessionCmd.AddCommand(sessionListCmd, sessionShowCmd, sessionClearCmd)
+}
@@ -110,29 +110,110 @@ func confirm(r *bufio.Reader, exit bool) (skipNext bool) {
 
 var configTmpl = []byte(`# Brang configuration options
 outWriter: stdout # stdout|file|tempFile
-outWriterFormat: pretty # pretty|basic|raw
+outWriterFormat: pretty # pretty|basic|raw|json
 deleteTempFileOnClose: true
+# env: dev # default environment for groups in requests.yaml that have it. override with --env
 # outWriterFileType: txt #full named path of file
 # outWriterFileName: brangoutput
 # outWriterFilePath: /usr
 # fileEditor: notepad #Either alias or direct path to executable
+# transport: # how requests are sent. override per group in requests.yaml, or with flags like --timeout
+#   timeout: 30s # whole request. 0 for no limit. default 10s
+#   connectTimeout: 5s
+#   tlsHandshakeTimeout: 5s
+#   proxy: http://proxy.corp:3128 # or socks5://localhost:1080
+#   caCert: /path/to/internal-ca.pem # trusted on top of the system's
+#   clientCert: /path/to/me.pem # for mTLS, with clientKey
+#   clientKey: /path/to/me-key.pem
+#   insecure: false # don't check the server's certificate
+#   noFollow: false # don't follow redirects
+#   maxRedirects: 10
+# history: # requests sent and their responses, see 'brang history'
+#   disabled: false
+#   maxEntries: 500 # the oldest are removed past this. default 500
+#   maxAge: 720h # entries older than this are removed. default no limit
+#   maxBodySize: 65536 # bytes kept of each request and response body. default 64KiB
+# diff: # see 'brang diff'
+#   ignore: ['$..updatedAt', 'header:X-Request-Id'] # values that change on every response
 `)
 
 var requestsTmpl = []byte(`# Saved requests
 # These requests are then accessed in cmd line like: mysite.posts.all or github.brangreadme
 # Anything can be a reference to a env variable like: $THE_VAR - just make sure its set in your environment.
+# Urls, headers and bodies of saved requests can have {{name}} placeholders, filled from --var name=value, the group's vars or env variables.
+# ${THE_VAR:-default} uses the env variable, or the default when it isn't set, and {{name:-default}} the var.
+# A missing var is an error. A token: of {{token:-}} sends requests without auth until a login request captures it.
 # Examples:
 # mysite:
+#   vars:
+#     userId: 27
+#     apiVersion: ${MYSITE_API_VERSION:-v1}
+#   baseUrl: https://mysite.com # urls starting with / are joined to it
+#   session: mysite # keeps cookies between runs. see 'brang session -h'. or with sticky headers:
+#   # session:
+#   #   name: mysite
+#   #   headers: [X-CSRF-Token] # sent back on later requests once a response sets them
+#   snapshot: # what 'brang snapshot' records of responses
+#     headers: [Content-Type, Cache-Control] # default Content-Type
+#     redact: ['$..id', '$.meta.generatedAt'] # values that change on every response
+#   transport: # overrides transport: of config.yaml, same settings
+#     caCert: $HOME/certs/mysite-ca.pem
+#     timeout: 2m
+#   environments: # select with --env staging, or env: in config.yaml
+#     staging:
+#       baseUrl: https://staging.mysite.com
+#       vars:
+#         userId: 1
+#       auth:
+#         token: $MYSITE_STAGING_TOKEN
 #   auth:
-#     authtype: Bearer
+#     authtype: Bearer # or Password for basic auth, or Digest, which answers the challenge of a 401 once per group
 #     token: ABC-456 # could use $MYSITE_TOKEN
 #     username: joe # could use $MYSITE_USERNAME
 #     password: secret # could use $MYSITE_PASSWORD
+#   # auth: # or get a token from an OAuth2 server, cached until it expires and got again on a 401
+#   #   authType: OAuth2
+#   #   tokenUrl: https://auth.mysite.com/oauth/token
+#   #   clientId: $MYSITE_CLIENT_ID
+#   #   clientSecret: $MYSITE_CLIENT_SECRET
+#   #   scopes: [read:orders, write:orders]
+#   #   audience: https://api.mysite.com
+#   #   refreshToken: $MYSITE_REFRESH_TOKEN # uses the refresh_token grant instead of client_credentials
+#   # auth: # or a token of a user, who logs in in the browser. see 'brang auth -h'
+#   #   authType: OAuth2
+#   #   grant: authorization_code # with PKCE. or device_code, with deviceUrl: to enter a code on any device
+#   #   authUrl: https://auth.mysite.com/authorize
+#   #   tokenUrl: https://auth.mysite.com/oauth/token
+#   #   clientId: $MYSITE_APP_ID
+#   #   redirectUrl: http://127.0.0.1:8400/callback # only when the server needs a set port. default a free one
+#   # auth: # or sign requests with AWS Signature Version 4, for API Gateway or S3 and MinIO
+#   #   authType: AWSSigV4
+#   #   accessKey: $AWS_ACCESS_KEY_ID
+#   #   secretKey: $AWS_SECRET_ACCESS_KEY
+#   #   sessionToken: $AWS_SESSION_TOKEN # only for temporary keys
+#   #   region: us-east-1
+#   #   service: execute-api # or s3
 #   requests:
+#     login:
+#       url: https://mysite.com/login
+#       method: POST
+#       capture: # saved per group for later requests to use as {{token}}. see 'brang state -h'
+#         token: $.data.access_token
+#         csrf: header:X-CSRF-Token
+#       expect: # checked by 'brang test mysite'
+#         status: 200
+#         json:
+#           - path: $.data.access_token
+#             exists: true
+#         maxLatency: 500ms
 #     users: https://mysite.com/users/
+#     user: https://mysite.com/{{apiVersion}}/users/{{userId}}
 #     posts:
 #       all:
 #         url: https://mysite.com/posts/
+#       create:
+#         url: https://mysite.com/posts/
+#         method: POST # sent with 'brang run mysite.posts.create'
 #         body: |
 #           {
 #             "test": "test", 
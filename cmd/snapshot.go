@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jerempy/brang/client"
+	"github.com/spf13/cobra"
+)
+
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "record responses of saved requests and check later ones against them",
+	Long: `
+A snapshot is the status, some headers and the body of the response of a saved request, kept in the
+snapshots folder next to requests.yaml. Checking sends the requests again and shows what changed.
+Values that change on every response, such as ids and times, are redacted with snapshot: redact: of the group
+in requests.yaml or --redact, so only whether they are there is checked. Headers recorded are Content-Type,
+or those of snapshot: headers: of the group and --record-header.`,
+}
+
+var snapshotRecordCmd = &cobra.Command{
+	Use:     "record {group|glob|SavedRequest}",
+	Short:   "Send saved requests and record their responses as snapshots",
+	Example: `'brang snapshot record mysite' or 'brang snapshot record mysite.users.* --redact '$..createdAt''`,
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runSnapshots(cmd, args[0], client.RecordSnapshots)
+	},
+}
+
+var snapshotCheckCmd = &cobra.Command{
+	Use:   "check {group|glob|SavedRequest}",
+	Short: "Send saved requests and check their responses against their snapshots",
+	Long: `
+Sends saved requests and compares each response to its snapshot, showing the differences of those that changed.
+Exits with an error if any changed or has no snapshot, so CI can gate on it. --update records them instead.`,
+	Example: `'brang snapshot check mysite' or 'brang snapshot check mysite --update'`,
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runSnapshots(cmd, args[0], client.CheckSnapshots)
+	},
+}
+
+func runSnapshots(cmd *cobra.Command, arg string, fn func(*client.Runner, []string, client.SnapshotOptions) []*client.SnapshotResult) {
+	names := []string{arg}
+	if client.IsCollection(arg) {
+		var err error
+		if names, err = client.MatchSavedRequests(arg); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+	var o client.SnapshotOptions
+	o.Headers, _ = cmd.Flags().GetStringArray("record-header")
+	o.Redact, _ = cmd.Flags().GetStringArray("redact")
+	o.Update, _ = cmd.Flags().GetBool("update")
+	parallel, _ := cmd.Flags().GetInt("parallel")
+	rn := &client.Runner{Base: &rset, Parallel: parallel, Quiet: true}
+	results := fn(rn, names, o)
+	color, _ := cmd.Flags().GetString("color")
+	client.WriteSnapshotResults(os.Stdout, results, useColor(color))
+	for _, r := range results {
+		if r.Failed() {
+			os.Exit(1)
+		}
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(snapshotCmd)
+	snapshotCmd.AddCommand(snapshotRecordCmd, snapshotCheckCmd)
+	for _, c := range []*cobra.Command{snapshotRecordCmd, snapshotCheckCmd} {
+		c.Flags().StringArrayVar(&rset.VarSlice, "var", []string{}, `set a value for {{name}} placeholders as name=value, as many as needed`)
+		c.Flags().StringVar(&rset.Env, "env", "", `environment of the groups to use, ex: staging. Defaults to env: in config.yaml`)
+		c.Flags().StringVar(&rset.Session, "session", "", `keep cookies and sticky headers between runs in this session`)
+		transportFlags(c)
+		c.Flags().Int("parallel", 1, "how many requests to send at once. 1 sends them one at a time in file order")
+		c.Flags().StringArray("record-header", []string{}, "also record this response header, as many as needed. ex: --record-header Cache-Control")
+		c.Flags().StringArray("redact", []string{}, `also redact the values at this json path, as many as needed. ex: --redact '$..id'`)
+		c.Flags().String("color", "auto", "color the differences: auto|always|never")
+	}
+	snapshotCheckCmd.Flags().Bool("update", false, "record the responses that changed, or have no snapshot yet, as their snapshots")
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/jerempy/brang/client"
+	"github.com/spf13/cobra"
+)
+
+var stateCmd = &cobra.Command{
+	Use:   "state",
+	Short: "view or clear values captured from responses",
+	Long: `
+Saved requests can capture values from their response with capture: rules, such as token: $.data.access_token.
+The values are kept per group and used in later requests as {{token}}.`,
+}
+
+var stateShowCmd = &cobra.Command{
+	Use:     "show [group]",
+	Short:   "Show the values captured for a group, or list groups with captured values",
+	Example: `'brang state show mysite'`,
+	Args:    cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			groups, err := client.StateGroups()
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			for _, g := range groups {
+				fmt.Println(g)
+			}
+			return
+		}
+		v, err := client.LoadState(args[0])
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Printf("%s: %s\n", k, v[k])
+		}
+	},
+}
+
+var stateClearCmd = &cobra.Command{
+	Use:     "clear group",
+	Short:   "Remove all values captured for a group",
+	Example: `'brang state clear mysite'`,
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := client.ClearState(args[0]); err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Printf("cleared state of %s\n", args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(stateCmd)
+	stateCmd.AddCommand(stateShowCmd, stateClearCmd)
+}
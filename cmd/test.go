@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jerempy/brang/client"
+	"github.com/spf13/cobra"
+)
+
+var testCmd = &cobra.Command{
+	Use:   "test {group|glob|SavedRequest}",
+	Short: "Check saved requests against their expect: assertions",
+	Long: `
+Sends saved requests and checks each response against the expect: of the saved request, printing pass|fail per assertion.
+expect: can check the status, headers (regex), json paths (equals|exists|matches) and maxLatency.
+Saved requests without expect: status are expected to not get a 4xx|5xx status.
+Exits with an error if any assertion failed, so CI can gate on it.`,
+	Example: `'brang test mysite' or 'brang test mysite.posts.* --junit report.xml' or 'brang test mysite --json'`,
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		names := []string{args[0]}
+		if client.IsCollection(args[0]) {
+			var err error
+			if names, err = client.MatchSavedRequests(args[0]); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+		}
+		parallel, _ := cmd.Flags().GetInt("parallel")
+		rn := &client.Runner{Base: &rset, Parallel: parallel, Quiet: true}
+		var results []*client.TestResult
+		passed := true
+		for _, r := range rn.Run(names) {
+			tr := client.CheckExpect(r)
+			passed = passed && tr.Passed()
+			results = append(results, tr)
+		}
+		jsonOut, _ := cmd.Flags().GetString("json")
+		if jsonOut == "-" {
+			client.WriteTestJSON(os.Stdout, results)
+		} else {
+			client.WriteTestResults(os.Stdout, results)
+			if jsonOut != "" {
+				if err := writeReport(jsonOut, func(w io.Writer) error { return client.WriteTestJSON(w, results) }); err != nil {
+					fmt.Println(err)
+					os.Exit(1)
+				}
+			}
+		}
+		if junit, _ := cmd.Flags().GetString("junit"); junit != "" {
+			if err := writeReport(junit, func(w io.Writer) error { return client.WriteJUnit(w, results) }); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+		}
+		if !passed {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(testCmd)
+	requestCmdFlags(testCmd)
+	testCmd.Flags().Int("parallel", 1, "how many requests to send at once. 1 sends them one at a time in file order")
+	testCmd.Flags().String("junit", "", "also write a JUnit xml report to this file, ex: report.xml")
+	testCmd.Flags().String("json", "", "write a json report to stdout instead, or to a file with --json=report.json")
+	testCmd.Flags().Lookup("json").NoOptDefVal = "-"
+}
+
+// Creates the report file and writes to it with fn
+func writeReport(file string, fn func(io.Writer) error) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return fmt.Errorf("err writing report: %w", err)
+	}
+	if err := fn(f); err != nil {
+		f.Close()
+		return fmt.Errorf("err writing report %s: %w", file, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("err writing report %s: %w", file, err)
+	}
+	return nil
+}
@@ -11,7 +11,7 @@ import (
 	"github.com/spf13/viper"
 )
 
-var Requests = viper.New()
+var Requests = NewRequestsStore()
 var Brang = viper.New()
 
 var (
@@ -20,6 +20,13 @@ var (
 	ConfigPath   = filepath.Join(BrangPath, "config")
 	ConfigFile   = filepath.Join(ConfigPath, "config.yaml")
 	RequestsFile = filepath.Join(ConfigPath, "requests.yaml")
+	StatePath    = filepath.Join(BrangPath, "state")
+	SessionsPath = filepath.Join(BrangPath, "sessions")
+	ImportsPath  = filepath.Join(BrangPath, "imports")
+	HistoryPath  = filepath.Join(BrangPath, "history")
+	TokensPath   = filepath.Join(BrangPath, "tokens")
+	// kept next to requests.yaml, so they can be shared with the saved requests
+	SnapshotsPath = filepath.Join(ConfigPath, "snapshots")
 )
 
 func LoadBrangConfig() error {
@@ -34,13 +41,9 @@ func LoadBrangConfig() error {
 }
 
 func LoadRequests() error {
+	// a missing file reads as empty. Will return error later if key:value not found
 	if err := Requests.ReadInConfig(); err != nil {
-		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
-			// ignore NotFound. Will return error later if key:value not found
-		} else {
-			// return all other errors
-			return fmt.Errorf("err reading requests: %w", err)
-		}
+		return fmt.Errorf("err reading requests: %w", err)
 	}
 	return nil
 }
@@ -0,0 +1,253 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RequestsStore holds the requests.yaml as yaml nodes. Unlike viper it keeps keys exactly
+// as written, and comments are kept when it is written back.
+type RequestsStore struct {
+	file string
+	doc  *yaml.Node
+	// a file with only comments (like the one from setup) parses to an empty node,
+	// so its text is kept to write back ahead of the new content
+	comments []byte
+}
+
+func NewRequestsStore() *RequestsStore {
+	return &RequestsStore{doc: emptyDoc()}
+}
+
+func emptyDoc() *yaml.Node {
+	return &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+}
+
+func (s *RequestsStore) SetConfigFile(f string) {
+	s.file = f
+}
+
+func (s *RequestsStore) ConfigFileUsed() string {
+	return s.file
+}
+
+// ReadInConfig reads the file set with SetConfigFile. A missing file reads as empty
+// and is created on WriteConfig. Does nothing when no file is set.
+func (s *RequestsStore) ReadInConfig() error {
+	if s.file == "" {
+		return nil
+	}
+	f, err := os.Open(s.file)
+	if errors.Is(err, fs.ErrNotExist) {
+		s.doc, s.comments = emptyDoc(), nil
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return s.ReadConfig(f)
+}
+
+// ReadConfig reads requests yaml from r
+func (s *RequestsStore) ReadConfig(r io.Reader) error {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return fmt.Errorf("%s: %w", s.name(), err)
+	}
+	s.comments = nil
+	if doc.Kind == 0 {
+		s.comments = bytes.TrimRight(b, "\n")
+		doc = *emptyDoc()
+	}
+	if doc.Content[0].Kind != yaml.MappingNode {
+		return s.Errorf(doc.Content[0], "requests need to be a map of <group>: {auth, requests}")
+	}
+	s.doc = &doc
+	return nil
+}
+
+// Lookup returns the node at path, or nil when it doesn't exist. Keys are matched
+// exactly, falling back to a case-insensitive match when only one key matches that way.
+func (s *RequestsStore) Lookup(path ...string) *yaml.Node {
+	n := s.doc.Content[0]
+	for _, k := range path {
+		_, n = mappingValue(n, k, true)
+		if n == nil {
+			return nil
+		}
+	}
+	return n
+}
+
+// Keys returns the keys of the map at path in file order
+func (s *RequestsStore) Keys(path ...string) []string {
+	n := s.Lookup(path...)
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil
+	}
+	var keys []string
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		keys = append(keys, n.Content[i].Value)
+	}
+	return keys
+}
+
+// Set encodes v and sets it at path, adding any maps missing along the way. Keys are
+// matched exactly, so setting Posts doesn't replace posts. Comments on a replaced value are kept.
+func (s *RequestsStore) Set(v interface{}, path ...string) error {
+	var val yaml.Node
+	if err := val.Encode(v); err != nil {
+		return err
+	}
+	n := s.doc.Content[0]
+	for i, k := range path {
+		if n.Kind != yaml.MappingNode {
+			return s.Errorf(n, "can't set %s - it is inside a value that isn't a map", k)
+		}
+		_, next := mappingValue(n, k, false)
+		if i == len(path)-1 {
+			if next != nil {
+				val.HeadComment, val.LineComment, val.FootComment = next.HeadComment, next.LineComment, next.FootComment
+				*next = val
+			} else {
+				n.Content = append(n.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: k}, &val)
+			}
+			break
+		}
+		if next == nil {
+			next = &yaml.Node{Kind: yaml.MappingNode}
+			n.Content = append(n.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: k}, next)
+		} else if next.Kind == yaml.ScalarNode && next.Tag == "!!null" {
+			// an empty key such as 'mysite:' becomes a map
+			next.Kind, next.Tag, next.Value = yaml.MappingNode, "", ""
+		}
+		n = next
+	}
+	return nil
+}
+
+// WriteConfig writes the requests back to the file set with SetConfigFile
+func (s *RequestsStore) WriteConfig() error {
+	if s.file == "" {
+		return fmt.Errorf("no requests file set to write to")
+	}
+	var b bytes.Buffer
+	if len(s.comments) > 0 {
+		b.Write(s.comments)
+		b.WriteString("\n\n")
+	}
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode(s.doc); err != nil {
+		return fmt.Errorf("err writing requests: %w", err)
+	}
+	enc.Close()
+	return os.WriteFile(s.file, b.Bytes(), 0644)
+}
+
+// Errorf makes an error that starts with the file and line of n, like requests.yaml:12: <msg>
+func (s *RequestsStore) Errorf(n *yaml.Node, format string, a ...interface{}) error {
+	return fmt.Errorf("%s:%d: %s", s.name(), n.Line, fmt.Sprintf(format, a...))
+}
+
+func (s *RequestsStore) name() string {
+	if s.file == "" {
+		return "requests.yaml"
+	}
+	return s.file
+}
+
+// Returns the key and value nodes for k in a mapping node. With fold, a key matching
+// k ignoring case is found when it is the only one.
+func mappingValue(n *yaml.Node, k string, fold bool) (*yaml.Node, *yaml.Node) {
+	if n.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	found := -1
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == k {
+			return n.Content[i], n.Content[i+1]
+		}
+		if fold && strings.EqualFold(n.Content[i].Value, k) {
+			if found >= 0 {
+				// more than one key matches ignoring case. only an exact match will do
+				found = len(n.Content)
+			} else {
+				found = i
+			}
+		}
+	}
+	if found < 0 || found == len(n.Content) {
+		return nil, nil
+	}
+	return n.Content[found], n.Content[found+1]
+}
+
+// ParseRequestPath splits a saved request name in dot.notation into its keys.
+// Keys containing dots can be quoted: mysite."v1.2".users -> [mysite v1.2 users]
+func ParseRequestPath(name string) ([]string, error) {
+	var path []string
+	var key strings.Builder
+	var quote byte
+	quoted := false
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				key.WriteByte(c)
+			}
+		case c == '"' || c == '\'':
+			if key.Len() > 0 || quoted {
+				return nil, fmt.Errorf("quotes need to wrap a whole key in %s", name)
+			}
+			quote, quoted = c, true
+		case c == '.':
+			if key.Len() == 0 {
+				return nil, fmt.Errorf("empty key in %s", name)
+			}
+			path = append(path, key.String())
+			key.Reset()
+			quoted = false
+		default:
+			if quoted {
+				return nil, fmt.Errorf("quoted key needs to be followed by '.' in %s", name)
+			}
+			key.WriteByte(c)
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("missing closing %c in %s", quote, name)
+	}
+	if key.Len() == 0 {
+		return nil, fmt.Errorf("empty key in %s", name)
+	}
+	return append(path, key.String()), nil
+}
+
+// RequestName joins keys into dot.notation, quoting keys that contain dots.
+// It is the reverse of ParseRequestPath
+func RequestName(path []string) string {
+	keys := make([]string, len(path))
+	for i, k := range path {
+		if strings.Contains(k, ".") {
+			k = `"` + k + `"`
+		}
+		keys[i] = k
+	}
+	return strings.Join(keys, ".")
+}
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var mockRequestsYml = []byte(`# my requests
+mysite:
+  auth:
+    authType: Bearer
+  requests:
+    getUserByID: https://mysite.com/users/1 # keep me
+    "v1.2":
+      users: https://mysite.com/v1.2/users
+    Posts: https://mysite.com/posts
+`)
+
+func TestParseRequestPath(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		want    []string
+		wantErr bool
+	}{
+		"plain":          {in: "mysite.posts.all", want: []string{"mysite", "posts", "all"}},
+		"double quoted":  {in: `mysite."v1.2".users`, want: []string{"mysite", "v1.2", "users"}},
+		"single quoted":  {in: `mysite.'a.b'`, want: []string{"mysite", "a.b"}},
+		"empty key":      {in: "mysite..users", wantErr: true},
+		"trailing dot":   {in: "mysite.", wantErr: true},
+		"missing quote":  {in: `mysite."v1.2.users`, wantErr: true},
+		"text after key": {in: `mysite."v1"x`, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseRequestPath(tc.in)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("%v: got err %v - want err %v", tc.in, err, tc.wantErr)
+			}
+			if strings.Join(got, "|") != strings.Join(tc.want, "|") {
+				t.Errorf("%v: got %v - want %v", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRequestsStoreLookup(t *testing.T) {
+	s := NewRequestsStore()
+	if err := s.ReadConfig(bytes.NewBuffer(mockRequestsYml)); err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]struct {
+		in   []string
+		want string
+	}{
+		"keeps case":    {in: []string{"mysite", "requests", "getUserByID"}, want: "https://mysite.com/users/1"},
+		"dotted key":    {in: []string{"mysite", "requests", "v1.2", "users"}, want: "https://mysite.com/v1.2/users"},
+		"ignoring case": {in: []string{"mysite", "requests", "posts"}, want: "https://mysite.com/posts"},
+		"not found":     {in: []string{"mysite", "requests", "nope"}, want: ""},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var got string
+			if n := s.Lookup(tc.in...); n != nil {
+				got = n.Value
+			}
+			if got != tc.want {
+				t.Errorf("got %v - want %v", got, tc.want)
+			}
+		})
+	}
+	if got := s.Keys("mysite", "requests"); strings.Join(got, ",") != "getUserByID,v1.2,Posts" {
+		t.Errorf("keys: got %v", got)
+	}
+	err := s.Errorf(s.Lookup("mysite", "requests", "Posts"), "bad")
+	if err.Error() != "requests.yaml:9: bad" {
+		t.Errorf("got %v - want requests.yaml:9: bad", err)
+	}
+}
+
+func TestRequestsStoreWrite(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "requests.yaml")
+	os.WriteFile(f, mockRequestsYml, 0644)
+	s := NewRequestsStore()
+	s.SetConfigFile(f)
+	if err := s.ReadInConfig(); err != nil {
+		t.Fatal(err)
+	}
+	s.Set("https://mysite.com/users/2", "mysite", "requests", "getUserByID")
+	s.Set("https://other.com", "other", "requests", "home")
+	s.Set("https://mysite.com/posts/new", "mysite", "requests", "posts")
+	if err := s.WriteConfig(); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := os.ReadFile(f)
+	for _, want := range []string{"# my requests", "getUserByID: https://mysite.com/users/2 # keep me", "authType: Bearer", "home: https://other.com",
+		"Posts: https://mysite.com/posts\n", "posts: https://mysite.com/posts/new"} {
+		if !bytes.Contains(b, []byte(want)) {
+			t.Errorf("written file missing %q:\n%s", want, b)
+		}
+	}
+}